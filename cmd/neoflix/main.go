@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 
@@ -11,11 +16,20 @@ import (
 	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
 	"github.com/neo4j-graphacademy/neoflix/pkg/routes"
 	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+	"github.com/neo4j-graphacademy/neoflix/public"
 )
 
 func main() {
+	normalizeRatings := flag.Bool("normalize-ratings", false,
+		"normalize existing ratings to the configured rating scale, then exit")
+	normalizeEmails := flag.Bool("normalize-emails", false,
+		"lower-case the emails of existing users, then exit")
+	flag.Parse()
+
 	settings, err := config.ReadConfig("config.json")
 	ioutils.PanicOnError(err)
+	scale := ratingScale(settings)
+	ioutils.PanicOnError(scale.Check())
 	// tag::useDriver[]
 	// tag::driver[]
 	driver, err := config.NewDriver(settings)
@@ -25,32 +39,78 @@ func main() {
 		ioutils.PanicOnError(driver.Close())
 	}()
 
+	if *normalizeRatings {
+		migration, err := services.NormalizeRatings(context.Background(), driver, scale)
+		ioutils.PanicOnError(err)
+		fmt.Printf("Normalized %d ratings of %d movies: %d changed, %d quarantined\n",
+			migration.Ratings, migration.Movies, migration.Changed, migration.Quarantined)
+		return
+	}
+
+	if *normalizeEmails {
+		migration, err := services.NormalizeEmails(context.Background(), driver)
+		ioutils.PanicOnError(err)
+		fmt.Printf("Normalized the emails of %d users: %d changed\n", migration.Users, migration.Changed)
+		for _, email := range migration.Conflicts {
+			fmt.Printf("Several users share the email %s, merge them by hand\n", email)
+		}
+		return
+	}
+
+	schemaCtx, cancelSchema := context.WithTimeout(context.Background(), time.Minute)
+	ioutils.PanicOnError(services.EnsureConstraints(schemaCtx, driver))
+	cancelSchema()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go services.NewTrendingWorker(driver, services.TrendingSettings{
+		HalfLife:        settings.TrendingHalfLife(),
+		RefreshInterval: settings.TrendingRefreshInterval(),
+	}).Run(ctx)
+
 	fixtureLoader := &fixtures.FixtureLoader{Prefix: "."}
+	indexes := services.NewFullTextIndexes(driver)
+	movieService := services.NewMovieService(fixtureLoader, driver, indexes, similarityWeights(settings), scale)
+	genreService := services.NewGenreService(fixtureLoader, driver)
 
 	allRoutes := allRoutes(
-		services.NewMovieService(fixtureLoader, driver),
-		services.NewGenreService(fixtureLoader, driver),
-		services.NewRatingService(fixtureLoader, driver),
+		movieService,
+		genreService,
+		services.NewRatingService(fixtureLoader, driver, scale),
 		services.NewPeopleService(fixtureLoader, driver),
-		services.NewAuthService(fixtureLoader, driver, settings.JwtSecret, settings.SaltRounds),
-		services.NewFavoriteService(fixtureLoader, driver))
+		services.NewAuthService(fixtureLoader, driver, settings.JwtSecret, settings.SaltRounds, services.TokenSettings{
+			AccessTokenTtl:  settings.AccessTokenTtl(),
+			RefreshTokenTtl: settings.RefreshTokenTtl(),
+		}),
+		services.NewFavoriteService(fixtureLoader, driver),
+		services.NewSearchService(driver, indexes),
+		services.NewSuggestService(genreService, movieService, driver, indexes, settings.SuggestBudget()),
+		services.NewRecommendationService(driver, scale),
+		services.NewCatalogueService(driver),
+		services.NewWatchlistService(driver),
+		services.NewReviewService(driver),
+		services.NewGraphService(driver),
+		services.NewSocialService(driver))
 	// end::useDriver[]
 
-	server := newHttpServer()
+	server := http.NewServeMux()
+	routes.NewStaticRoutes(staticAssets(settings)).Register(server)
 	for _, route := range allRoutes {
 		route.Register(server)
 	}
 
 	fmt.Printf("Server listening on http://localhost:%d\n", settings.Port)
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", settings.Port), server); err != nil {
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", settings.Port),
+		routes.WithRequestContext(server, settings.RequestTimeout())); err != nil {
 		ioutils.PanicOnError(err)
 	}
 }
 
-func newHttpServer() *http.ServeMux {
-	server := http.NewServeMux()
-	server.Handle("/", http.FileServer(http.Dir("public")))
-	return server
+func staticAssets(settings *config.Config) fs.FS {
+	if settings.DevMode {
+		return os.DirFS("public")
+	}
+	return public.Assets
 }
 
 func allRoutes(
@@ -59,13 +119,65 @@ func allRoutes(
 	ratingService services.RatingService,
 	peopleService services.PeopleService,
 	authService services.AuthService,
-	favoriteService services.FavoriteService) []routes.Routable {
+	favoriteService services.FavoriteService,
+	searchService services.SearchService,
+	suggestService services.SuggestService,
+	recommendationService services.RecommendationService,
+	catalogueService services.CatalogueService,
+	watchlistService services.WatchlistService,
+	reviewService services.ReviewService,
+	graphService services.GraphService,
+	socialService services.SocialService) []routes.Routable {
 
 	return []routes.Routable{
 		routes.NewGenreRoutes(genreService, movieService, authService),
-		routes.NewMovieRoutes(movieService, ratingService, authService),
+		routes.NewMovieRoutes(movieService, ratingService, reviewService, authService),
 		routes.NewPeopleRoutes(peopleService, movieService, authService),
 		routes.NewAuthRoutes(authService),
-		routes.NewAccountRoutes(ratingService, authService, favoriteService),
+		routes.NewAccountRoutes(ratingService, authService, favoriteService, recommendationService, watchlistService, reviewService, socialService),
+		routes.NewListRoutes(watchlistService, authService),
+		routes.NewSearchRoutes(searchService, suggestService),
+		routes.NewAdminMovieRoutes(catalogueService, authService),
+		routes.NewAdminPeopleRoutes(catalogueService, authService),
+		routes.NewAdminGenreRoutes(catalogueService, authService),
+		routes.NewAdminAuditRoutes(catalogueService, authService),
+		routes.NewGraphRoutes(graphService),
+		routes.NewUserRoutes(socialService, authService),
+	}
+}
+
+// similarityWeights overrides the default similarity weights with the
+// configured ones
+func similarityWeights(settings *config.Config) services.SimilarityWeights {
+	weights := services.DefaultSimilarityWeights()
+	for _, override := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{settings.SimilarityActorWeight, &weights.Actor},
+		{settings.SimilarityDirectorWeight, &weights.Director},
+		{settings.SimilarityGenreWeight, &weights.Genre},
+		{settings.SimilarityCoRatingWeight, &weights.CoRating},
+	} {
+		if override.value != nil {
+			*override.target = *override.value
+		}
+	}
+	return weights
+}
+
+// ratingScale overrides the default rating scale with the configured bounds
+// and step
+func ratingScale(settings *config.Config) services.RatingScale {
+	scale := services.DefaultRatingScale()
+	if settings.RatingMin != nil {
+		scale.Min = *settings.RatingMin
+	}
+	if settings.RatingMax != nil {
+		scale.Max = *settings.RatingMax
+	}
+	if settings.RatingStep != nil {
+		scale.Step = *settings.RatingStep
 	}
+	return scale
 }
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -26,32 +27,35 @@ func TestMovieList(outer *testing.T) {
 
 	service := services.NewMovieService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver)
+		driver,
+		services.NewFullTextIndexes(driver),
+		services.DefaultSimilarityWeights(),
+		services.DefaultRatingScale())
 
 	limit := 1
 
-	output, err := service.FindAll("", paging.NewPaging("", "title", "ASC", 0, limit))
+	output, err := service.FindAll(context.Background(), "", paging.NewPaging("", "title", "ASC", 0, limit))
 	assertNilError(outer, err)
 
 	assertEquals(outer, len(output), limit)
 
 	// Test Pagination
-	next, err := service.FindAll("", paging.NewPaging("", "title", "ASC", 1, limit))
+	next, err := service.FindAll(context.Background(), "", paging.NewPaging("", "title", "ASC", 1, limit))
 
 	assertNilError(outer, err)
 	assertEquals(outer, len(output), limit)
-	assertNotEquals(outer, next[0]["title"], output[0]["title"])
+	assertNotEquals(outer, next[0].Title, output[0].Title)
 
 	// Test Ordering
-	ordered, err := service.FindAll("", paging.NewPaging("", "imdbRating", "DESC", 0, limit))
+	ordered, err := service.FindAll(context.Background(), "", paging.NewPaging("", "imdbRating", "DESC", 0, limit))
 
 	assertNilError(outer, err)
 	assertEquals(outer, len(output), limit)
-	assertNotEquals(outer, ordered[0]["title"], output[0]["title"])
+	assertNotEquals(outer, ordered[0].Title, output[0].Title)
 
 	fmt.Println("Here is the answer to the quiz question on the lesson:")
 	fmt.Println("What is the title of the highest rated movie in the recommendations dataset?")
 	fmt.Println("Copy and paste the following answer into the text box:")
 
-	fmt.Println(ordered[0]["title"])
+	fmt.Println(ordered[0].Title)
 }
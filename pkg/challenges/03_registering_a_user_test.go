@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
 
@@ -25,20 +26,20 @@ func TestRegisterUser(outer *testing.T) {
 	// Create Service
 	service := services.NewAuthService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver, "secret", 10)
+		driver, "secret", 10, services.DefaultTokenSettings())
 
 	email := "graphacademy@neo4j.com"
 	password := "notletmein"
 	name := "Graph Academy"
 
-	user, err := service.Save(email, password, name)
+	user, err := service.Save(context.Background(), email, password, name)
 
 	assertNilError(outer, err)
 
 	// Check return properties
-	assertEquals(outer, email, user["email"])
-	assertEquals(outer, name, user["name"])
-	assertNil(outer, user["password"])
+	assertEquals(outer, email, user.Email)
+	assertEquals(outer, name, user.Name)
+	assertEquals(outer, "", user.Password)
 
 	// Check user in database
 	session := driver.NewSession(neo4j.SessionConfig{})
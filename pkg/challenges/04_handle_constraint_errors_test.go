@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
 
@@ -22,6 +23,9 @@ func TestHandleUniqueConstraints(t *testing.T) {
 		assertNilError(t, driver.Close())
 	}()
 
+	// Create the constraints, as the server does at startup
+	assertNilError(t, services.EnsureConstraints(context.Background(), driver))
+
 	session := driver.NewSession(neo4j.SessionConfig{})
 
 	// Check Constraint exists
@@ -48,16 +52,23 @@ func TestHandleUniqueConstraints(t *testing.T) {
 	// Create Service
 	service := services.NewAuthService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver, "secret", 10)
+		driver, "secret", 10, services.DefaultTokenSettings())
 
 	// Create the user
-	user, err := service.Save(email, password, name)
+	user, err := service.Save(context.Background(), email, password, name)
 
 	assertNilError(t, err)
 	assertFalse(t, user == nil)
 
 	// Attempt to create the user again
-	other, err := service.Save(email, password, name)
+	other, err := service.Save(context.Background(), email, password, name)
+	assertTrue(t, other == nil)
+	assertNotNil(t, err)
+
+	assertContains(t, err.Error(), "already exists")
+
+	// Attempt to create the user again with another case
+	other, err = service.Save(context.Background(), " GraphAcademy@Neo4j.com", password, name)
 	assertTrue(t, other == nil)
 	assertNotNil(t, err)
 
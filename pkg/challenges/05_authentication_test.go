@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
 
@@ -25,7 +26,7 @@ func TestAuthentication(t *testing.T) {
 	// Create Service
 	service := services.NewAuthService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver, "secret", 10)
+		driver, "secret", 10, services.DefaultTokenSettings())
 
 	email := "authenticated@neo4j.com"
 	password := "AuthenticateM3!"
@@ -36,28 +37,34 @@ func TestAuthentication(t *testing.T) {
 	session.Run("MATCH (u:User {email: $email}) DETACH DELETE u", map[string]interface{}{"email": email})
 
 	// Create User
-	user, err := service.Save(email, password, name)
+	user, err := service.Save(context.Background(), email, password, name)
 
 	assertNilError(t, err)
-	assertEquals(t, email, user["email"])
+	assertEquals(t, email, user.Email)
 
 	// Incorrect Username
-	incorrectUsername, err := service.FindOneByEmailAndPassword("unknown", "password")
+	incorrectUsername, err := service.FindOneByEmailAndPassword(context.Background(), "unknown", "password")
 	assertTrue(t, incorrectUsername == nil)
 	assertNotNil(t, err)
 
 	// Incorrect Password
-	incorrectPassword, err := service.FindOneByEmailAndPassword(email, "incorrectpassword")
+	incorrectPassword, err := service.FindOneByEmailAndPassword(context.Background(), email, "incorrectpassword")
 	assertTrue(t, incorrectPassword == nil)
 	assertNotNil(t, err)
 
 	// Correct
-	correct, err := service.FindOneByEmailAndPassword(email, password)
+	correct, err := service.FindOneByEmailAndPassword(context.Background(), email, password)
 
 	assertNilError(t, err)
-	assertEquals(t, correct["email"], email)
-	assertEquals(t, correct["name"], name)
-	assertNotNil(t, correct["token"])
+	assertEquals(t, correct.Email, email)
+	assertEquals(t, correct.Name, name)
+	assertStringNotEmpty(t, correct.Token)
+
+	// Correct, with another case
+	otherCase, err := service.FindOneByEmailAndPassword(context.Background(), "Authenticated@Neo4j.com", password)
+
+	assertNilError(t, err)
+	assertEquals(t, otherCase.Email, email)
 
 	// GA: set a timestamp to verify that the tests have passed
 	session.Run("MATCH (u:User {email: $email}) SET u.authenticatedAt = datetime()", map[string]interface{}{"email": email})
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
 
@@ -25,21 +26,22 @@ func TestRatingMovies(t *testing.T) {
 	// Create Services
 	service := services.NewRatingService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver)
+		driver,
+		services.DefaultRatingScale())
 
 	movieId := "769"
 	userId := "1185150b-9e81-46a2-a1d3-eb649544b9c4"
 	email := "graphacademy.reviewer@neo4j.com"
-	rating := 5
+	rating := 5.0
 
 	// Create the User
 	session := driver.NewSession(neo4j.SessionConfig{})
 	session.Run("MERGE (u:User {userId: $userId}) SET u.email = $email", map[string]interface{}{"userId": userId, "email": email})
 
 	// Create the rating
-	output, err := service.Save(rating, movieId, userId)
+	output, err := service.Save(context.Background(), rating, movieId, userId)
 
 	assertNilError(t, err)
-	assertEquals(t, movieId, output["tmdbId"])
-	assertEquals(t, int64(rating), output["rating"])
+	assertEquals(t, movieId, output.TmdbId)
+	assertEquals(t, rating, *output.Rating)
 }
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
@@ -44,49 +45,49 @@ func TestMyFavoritesList(t *testing.T) {
 	`, map[string]interface{}{"userId": userId, "email": email})
 
 	// Should throw an error if user or movie do not exist
-	unknown, err := service.Save("unknown", "x999")
+	unknown, err := service.Save(context.Background(), "unknown", "x999")
 	assertFalse(t, unknown != nil)
 	assertNotNil(t, err)
 
-	unknownMovie, err := service.Save(userId, "x999")
+	unknownMovie, err := service.Save(context.Background(), userId, "x999")
 	assertFalse(t, unknownMovie != nil)
 	assertNotNil(t, err)
 
-	unknownUser, err := service.Save("unknown", toyStory)
+	unknownUser, err := service.Save(context.Background(), "unknown", toyStory)
 	assertFalse(t, unknownUser != nil)
 	assertNotNil(t, err)
 
 	// Add to list
-	saved, err := service.Save(userId, toyStory)
+	saved, err := service.Save(context.Background(), userId, toyStory)
 	assertNilError(t, err)
-	assertEquals(t, toyStory, saved["tmdbId"])
-	assertEquals(t, true, saved["favorite"])
+	assertEquals(t, toyStory, saved.TmdbId)
+	assertEquals(t, true, *saved.Favorite)
 
-	all, err := service.FindAllByUserId(userId, paging.NewPaging("", "createdAt", "desc", 0, 1))
+	all, err := service.FindAllByUserId(context.Background(), userId, paging.NewPaging("", "createdAt", "desc", 0, 1))
 
 	assertNilError(t, err)
 	assertEquals(t, len(all), 1)
-	assertEquals(t, all[0]["tmdbId"], toyStory)
+	assertEquals(t, all[0].TmdbId, toyStory)
 
-	remove, err := service.Delete(userId, toyStory)
+	remove, err := service.Delete(context.Background(), userId, toyStory)
 	assertNilError(t, err)
-	assertEquals(t, toyStory, remove["tmdbId"])
-	assertEquals(t, false, remove["favorite"])
+	assertEquals(t, toyStory, remove.TmdbId)
+	assertEquals(t, false, *remove.Favorite)
 
 	// Add & Remove from list
-	add, err := service.Save(userId, goodfellas)
+	add, err := service.Save(context.Background(), userId, goodfellas)
 
 	assertNilError(t, err)
-	assertEquals(t, goodfellas, add["tmdbId"])
-	assertEquals(t, true, add["favorite"])
+	assertEquals(t, goodfellas, add.TmdbId)
+	assertEquals(t, true, *add.Favorite)
 
-	removeGoodfellas, err := service.Delete(userId, goodfellas)
+	removeGoodfellas, err := service.Delete(context.Background(), userId, goodfellas)
 	assertNilError(t, err)
-	assertEquals(t, goodfellas, removeGoodfellas["tmdbId"])
-	assertEquals(t, false, removeGoodfellas["favorite"])
+	assertEquals(t, goodfellas, removeGoodfellas.TmdbId)
+	assertEquals(t, false, *removeGoodfellas.Favorite)
 
 	// Re-add the Toy Story Favorite for test
-	readd, err := service.Save(userId, toyStory)
+	readd, err := service.Save(context.Background(), userId, toyStory)
 	assertNilError(t, err)
 	assertNotNil(t, readd)
 }
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
 
@@ -30,7 +31,10 @@ func TestFavoritesFlag(t *testing.T) {
 		driver)
 	movieService := services.NewMovieService(
 		fixtureLoader,
-		driver)
+		driver,
+		services.NewFullTextIndexes(driver),
+		services.DefaultSimilarityWeights(),
+		services.DefaultRatingScale())
 
 	assertNotNil(t, favoriteService)
 	assertNotNil(t, movieService)
@@ -46,28 +50,28 @@ func TestFavoritesFlag(t *testing.T) {
 	`, map[string]interface{}{"userId": userId, "email": email})
 
 	// Get the most popular movie
-	firstCall, err := movieService.FindAll(userId, paging.NewPaging("", "imdbRating", "DESC", 0, 1))
+	firstCall, err := movieService.FindAll(context.Background(), userId, paging.NewPaging("", "imdbRating", "DESC", 0, 1))
 
 	assertNilError(t, err)
 	assertNotNil(t, firstCall)
 
-	movieId := firstCall[0]["tmdbId"].(string)
-	assertEquals(t, false, firstCall[0]["favorite"])
+	movieId := firstCall[0].TmdbId
+	assertEquals(t, false, *firstCall[0].Favorite)
 
 	// Add it to user favorites
-	favorite, err := favoriteService.Save(userId, movieId)
+	favorite, err := favoriteService.Save(context.Background(), userId, movieId)
 
 	assertNilError(t, err)
 
-	assertEquals(t, movieId, favorite["tmdbId"])
-	assertEquals(t, true, favorite["favorite"])
+	assertEquals(t, movieId, favorite.TmdbId)
+	assertEquals(t, true, *favorite.Favorite)
 
 	// Get most popular movie again
-	secondCall, err := movieService.FindAll(userId, paging.NewPaging("", "imdbRating", "DESC", 0, 1))
+	secondCall, err := movieService.FindAll(context.Background(), userId, paging.NewPaging("", "imdbRating", "DESC", 0, 1))
 
 	assertNilError(t, err)
 	assertNotNil(t, secondCall)
 
-	assertEquals(t, movieId, secondCall[0]["tmdbId"])
-	assertEquals(t, true, secondCall[0]["favorite"])
+	assertEquals(t, movieId, secondCall[0].TmdbId)
+	assertEquals(t, true, *secondCall[0].Favorite)
 }
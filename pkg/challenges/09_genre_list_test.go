@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"sort"
@@ -28,17 +29,17 @@ func TestGenreList(t *testing.T) {
 		driver)
 
 	// Should retrieve a list of genres
-	output, err := service.FindAll()
+	output, err := service.FindAll(context.Background())
 
 	assertNilError(t, err)
 
 	assertEquals(t, len(output), 19)
-	assertEquals(t, "Action", output[0]["name"])
-	assertEquals(t, "Western", output[18]["name"])
+	assertEquals(t, "Action", output[0].Name)
+	assertEquals(t, "Western", output[18].Name)
 
 	// Get Genre with the most movies
 	sort.Slice(output, func(i, j int) bool {
-		return output[i]["movies"].(int64) > output[j]["movies"].(int64)
+		return *output[i].Movies > *output[j].Movies
 	})
 
 	// Answer to question
@@ -47,7 +48,7 @@ func TestGenreList(t *testing.T) {
 	fmt.Println("Which genre has the highest movie count?")
 	fmt.Println("Copy and paste the following answer into the text box:")
 	fmt.Println("")
-	fmt.Println(output[0]["name"])
+	fmt.Println(output[0].Name)
 	fmt.Println("")
 
 }
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
@@ -30,12 +31,12 @@ func TestGenreDetails(t *testing.T) {
 	// Get Genre by Name
 	name := "Action"
 
-	genre, err := service.FindOneByName(name)
+	genre, err := service.FindOneByName(context.Background(), name)
 
 	assertNilError(t, err)
 	assertNotNil(t, genre)
 
-	assertEquals(t, name, genre["name"])
+	assertEquals(t, name, genre.Name)
 
 	fmt.Println()
 	fmt.Println()
@@ -45,7 +46,7 @@ func TestGenreDetails(t *testing.T) {
 	fmt.Println("Copy and paste the following answer into the text box:")
 
 	fmt.Println()
-	fmt.Println(genre["movies"])
+	fmt.Println(*genre.Movies)
 
 	fmt.Println()
 
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -26,7 +27,10 @@ func TestMoviePagination(t *testing.T) {
 
 	service := services.NewMovieService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver)
+		driver,
+		services.NewFullTextIndexes(driver),
+		services.DefaultSimilarityWeights(),
+		services.DefaultRatingScale())
 	assertNotNil(t, service)
 
 	tomHanks := "31"
@@ -36,73 +40,73 @@ func TestMoviePagination(t *testing.T) {
 	movieLimit := 10
 
 	// return a paginated list of movies by Genre
-	firstByGenre, err := service.FindAllByGenre(genre, "", paging.NewPaging("", "title", "ASC", 0, movieLimit))
+	firstByGenre, err := service.FindAllByGenre(context.Background(), genre, "", paging.NewPaging("", "title", "ASC", 0, movieLimit))
 
 	assertNilError(t, err)
 	assertNotNil(t, firstByGenre)
 	assertEquals(t, movieLimit, len(firstByGenre))
 
 	// Second Page
-	secondByGenre, err := service.FindAllByGenre(genre, "", paging.NewPaging("", "title", "ASC", movieLimit, movieLimit))
+	secondByGenre, err := service.FindAllByGenre(context.Background(), genre, "", paging.NewPaging("", "title", "ASC", movieLimit, movieLimit))
 
 	assertNilError(t, err)
 	assertNotNil(t, secondByGenre)
 	assertEquals(t, movieLimit, len(secondByGenre))
-	assertNotEquals(t, firstByGenre[0]["title"], secondByGenre[0]["title"])
+	assertNotEquals(t, firstByGenre[0].Title, secondByGenre[0].Title)
 
 	// Reordered
-	reorderedByGenre, err := service.FindAllByGenre(genre, "", paging.NewPaging("", "released", "ASC", movieLimit, movieLimit))
+	reorderedByGenre, err := service.FindAllByGenre(context.Background(), genre, "", paging.NewPaging("", "released", "ASC", movieLimit, movieLimit))
 
 	assertNilError(t, err)
 	assertEquals(t, movieLimit, len(reorderedByGenre))
-	assertNotEquals(t, firstByGenre[0]["title"], reorderedByGenre[0]["title"])
+	assertNotEquals(t, firstByGenre[0].Title, reorderedByGenre[0].Title)
 
 	// return a paginated list of movies by Actor
 	actorLimit := 2
 
-	firstByActor, err := service.FindAllByActorId(tomHanks, "", paging.NewPaging("", "title", "ASC", 0, actorLimit))
+	firstByActor, err := service.FindAllByActorId(context.Background(), tomHanks, "", paging.NewPaging("", "title", "ASC", 0, actorLimit))
 
 	assertNilError(t, err)
 	assertNotNil(t, firstByActor)
 	assertEquals(t, actorLimit, len(firstByActor))
 
-	secondByActor, err := service.FindAllByActorId(tomHanks, "", paging.NewPaging("", "title", "ASC", actorLimit, actorLimit))
+	secondByActor, err := service.FindAllByActorId(context.Background(), tomHanks, "", paging.NewPaging("", "title", "ASC", actorLimit, actorLimit))
 
 	assertNotNil(t, secondByActor)
 	assertEquals(t, actorLimit, len(firstByActor))
-	assertNotEquals(t, firstByActor[0]["title"], secondByActor[0]["title"])
+	assertNotEquals(t, firstByActor[0].Title, secondByActor[0].Title)
 
 	// Reordered
-	reorderedByActor, err := service.FindAllByActorId(tomHanks, "", paging.NewPaging("", "released", "ASC", 0, actorLimit))
+	reorderedByActor, err := service.FindAllByActorId(context.Background(), tomHanks, "", paging.NewPaging("", "released", "ASC", 0, actorLimit))
 
 	assertNilError(t, err)
 	assertEquals(t, actorLimit, len(reorderedByActor))
-	assertNotEquals(t, firstByActor[0]["title"], reorderedByActor[0]["title"])
+	assertNotEquals(t, firstByActor[0].Title, reorderedByActor[0].Title)
 
 	// return a paginated list of movies by Director
 	directorLimit := 1
 
-	firstByDirector, err := service.FindAllByDirectorId(tomHanks, "", paging.NewPaging("", "title", "ASC", 0, directorLimit))
+	firstByDirector, err := service.FindAllByDirectorId(context.Background(), tomHanks, "", paging.NewPaging("", "title", "ASC", 0, directorLimit))
 
 	assertNilError(t, err)
 	assertNotNil(t, firstByDirector)
 	assertEquals(t, directorLimit, len(firstByDirector))
 
-	secondByDirector, err := service.FindAllByDirectorId(tomHanks, "", paging.NewPaging("", "title", "ASC", directorLimit, directorLimit))
+	secondByDirector, err := service.FindAllByDirectorId(context.Background(), tomHanks, "", paging.NewPaging("", "title", "ASC", directorLimit, directorLimit))
 
 	assertNotNil(t, secondByDirector)
 	assertEquals(t, directorLimit, len(firstByDirector))
-	assertNotEquals(t, firstByDirector[0]["title"], secondByDirector[0]["title"])
+	assertNotEquals(t, firstByDirector[0].Title, secondByDirector[0].Title)
 
 	// Reordered
-	reorderedByDirector, err := service.FindAllByDirectorId(tomHanks, "", paging.NewPaging("", "released", "ASC", 0, directorLimit))
+	reorderedByDirector, err := service.FindAllByDirectorId(context.Background(), tomHanks, "", paging.NewPaging("", "released", "ASC", 0, directorLimit))
 
 	assertNilError(t, err)
 	assertEquals(t, directorLimit, len(reorderedByDirector))
-	assertNotEquals(t, firstByDirector[0]["title"], reorderedByDirector[0]["title"])
+	assertNotEquals(t, firstByDirector[0].Title, reorderedByDirector[0].Title)
 
 	// find films directed by Francis Ford Coppola
-	copollaFilms, err := service.FindAllByDirectorId(coppola, "", paging.NewPaging("", "title", "ASC", 0, 100))
+	copollaFilms, err := service.FindAllByDirectorId(context.Background(), coppola, "", paging.NewPaging("", "title", "ASC", 0, 100))
 
 	assertEquals(t, 16, len(copollaFilms))
 
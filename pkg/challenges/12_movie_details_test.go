@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
@@ -28,29 +29,32 @@ func TestMovieDetails(t *testing.T) {
 
 	service := services.NewMovieService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver)
+		driver,
+		services.NewFullTextIndexes(driver),
+		services.DefaultSimilarityWeights(),
+		services.DefaultRatingScale())
 	assertNotNil(t, service)
 
-	movieById, err := service.FindOneById(lockStock, "")
+	movieById, err := service.FindOneById(context.Background(), lockStock, "")
 
 	assertNilError(t, err)
-	assertEquals(t, movieById["tmdbId"], lockStock)
-	assertEquals(t, movieById["title"], "Lock, Stock & Two Smoking Barrels")
+	assertEquals(t, movieById.TmdbId, lockStock)
+	assertEquals(t, movieById.Title, "Lock, Stock & Two Smoking Barrels")
 
 	// get similar movies ordered by similarity score
 	limit := 1
 
-	output, err := service.FindAllBySimilarity(lockStock, "", paging.NewPaging("", "title", "ASC", 0, limit))
+	output, err := service.FindAllBySimilarity(context.Background(), lockStock, "", paging.NewPaging("", "title", "ASC", 0, limit))
 
 	assertNilError(t, err)
 
-	paginated, err := service.FindAllBySimilarity(lockStock, "", paging.NewPaging("", "title", "ASC", 1, limit))
+	paginated, err := service.FindAllBySimilarity(context.Background(), lockStock, "", paging.NewPaging("", "title", "ASC", 1, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, output)
 	assertEquals(t, limit, len(output))
 	assertEquals(t, limit, len(paginated))
-	assertNotEquals(t, paginated[0]["tmdbId"], output[0]["tmdbId"])
+	assertNotEquals(t, paginated[0].TmdbId, output[0].TmdbId)
 
 	fmt.Println()
 	fmt.Println("Here is the answer to the quiz question on the lesson:")
@@ -58,6 +62,6 @@ func TestMovieDetails(t *testing.T) {
 	fmt.Println("Copy and paste the following answer into the text box:")
 	fmt.Println()
 
-	fmt.Println(output[0]["title"])
+	fmt.Println(output[0].Title)
 
 }
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
@@ -29,27 +30,28 @@ func TestListingRatings(t *testing.T) {
 
 	service := services.NewRatingService(
 		&fixtures.FixtureLoader{Prefix: "../.."},
-		driver)
+		driver,
+		services.DefaultRatingScale())
 	assertNotNil(t, service)
 
-	first, err := service.FindAllByMovieId(pulpFiction, paging.NewPaging("", "timestamp", "ASC", 0, limit))
+	first, err := service.FindAllByMovieId(context.Background(), pulpFiction, paging.NewPaging("", "timestamp", "ASC", 0, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, first)
 	assertEquals(t, limit, len(first))
 
-	paginated, err := service.FindAllByMovieId(pulpFiction, paging.NewPaging("", "timestamp", "ASC", limit, limit))
+	paginated, err := service.FindAllByMovieId(context.Background(), pulpFiction, paging.NewPaging("", "timestamp", "ASC", limit, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, paginated)
 	assertEquals(t, limit, len(paginated))
 
-	assertNotEquals(t, first[0]["rating"], paginated[0]["rating"])
+	assertNotEquals(t, first[0].Rating, paginated[0].Rating)
 
 	// apply an ordering and pagination to the query
-	latest, err := service.FindAllByMovieId(pulpFiction, paging.NewPaging("", "timestamp", "DESC", 0, limit))
+	latest, err := service.FindAllByMovieId(context.Background(), pulpFiction, paging.NewPaging("", "timestamp", "DESC", 0, limit))
 
-	assertNotEquals(t, latest[0]["rating"], first[0]["rating"])
+	assertNotEquals(t, latest[0].Rating, first[0].Rating)
 
 	fmt.Println()
 	fmt.Println("Here is the answer to the quiz question on the lesson:")
@@ -58,8 +60,8 @@ func TestListingRatings(t *testing.T) {
 	fmt.Println()
 
 	firstReview := first[0]
-	firstUser := firstReview["user"].(services.Rating)
+	firstUser := firstReview.User
 
-	fmt.Println(firstUser["name"])
+	fmt.Println(firstUser.Name)
 
 }
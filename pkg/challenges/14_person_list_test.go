@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
@@ -31,36 +32,36 @@ func TestPersonList(t *testing.T) {
 	// retrieve a paginated list people from the database
 	limit := 10
 
-	output, err := service.FindAll(paging.NewPaging("", "name", "asc", 0, limit))
+	output, err := service.FindAll(context.Background(), paging.NewPaging("", "name", "asc", 0, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, output)
 	assertEquals(t, limit, len(output))
 
-	paginated, err := service.FindAll(paging.NewPaging("", "name", "asc", limit, limit))
+	paginated, err := service.FindAll(context.Background(), paging.NewPaging("", "name", "asc", limit, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, paginated)
 	assertEquals(t, limit, len(paginated))
 
-	assertNotEquals(t, output[0]["name"], paginated[0]["name"])
+	assertNotEquals(t, output[0].Name, paginated[0].Name)
 
 	// apply a filter, ordering and pagination to the query
 	q := "A"
 
-	filteredFirst, err := service.FindAll(paging.NewPaging(q, "name", "asc", 0, 1))
+	filteredFirst, err := service.FindAll(context.Background(), paging.NewPaging(q, "name", "asc", 0, 1))
 
 	assertNilError(t, err)
 	assertNotNil(t, filteredFirst)
 	assertEquals(t, 1, len(filteredFirst))
 
-	filteredLast, err := service.FindAll(paging.NewPaging(q, "name", "desc", 0, 1))
+	filteredLast, err := service.FindAll(context.Background(), paging.NewPaging(q, "name", "desc", 0, 1))
 
 	assertNilError(t, err)
 	assertNotNil(t, filteredLast)
 	assertEquals(t, 1, len(filteredLast))
 
-	assertNotEquals(t, filteredLast[0]["name"], filteredFirst[0]["name"])
+	assertNotEquals(t, filteredLast[0].Name, filteredFirst[0].Name)
 
 	// Quiz answer
 	fmt.Println()
@@ -69,5 +70,5 @@ func TestPersonList(t *testing.T) {
 	fmt.Println("What is the name of the first person in the database in alphabetical order?")
 	fmt.Println("Copy and paste the following answer into the text box:")
 
-	fmt.Println(output[0]["name"])
+	fmt.Println(output[0].Name)
 }
@@ -1,6 +1,7 @@
 package challenges_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 	"testing"
@@ -32,31 +33,31 @@ func TestPersonProfile(t *testing.T) {
 
 	// find a person by their ID
 
-	output, err := service.FindOneById(coppola)
+	output, err := service.FindOneById(context.Background(), coppola)
 
 	assertNilError(t, err)
 	assertNotNil(t, output)
-	assertEquals(t, coppola, output["tmdbId"])
-	assertEquals(t, "Francis Ford Coppola", output["name"])
-	assertEquals(t, int64(16), output["directedCount"].(int64))
-	assertEquals(t, int64(2), output["actedCount"].(int64))
+	assertEquals(t, coppola, output.TmdbId)
+	assertEquals(t, "Francis Ford Coppola", output.Name)
+	assertEquals(t, int64(16), *output.DirectedCount)
+	assertEquals(t, int64(2), *output.ActedCount)
 
 	// return a paginated list of similar people to a person by their ID
 
 	limit := 2
 
-	first, err := service.FindAllBySimilarity(coppola, paging.NewPaging("", "", "", 0, limit))
+	first, err := service.FindAllBySimilarity(context.Background(), coppola, paging.NewPaging("", "", "", 0, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, first)
 	assertEquals(t, limit, len(first))
 
-	second, err := service.FindAllBySimilarity(coppola, paging.NewPaging("", "", "", limit, limit))
+	second, err := service.FindAllBySimilarity(context.Background(), coppola, paging.NewPaging("", "", "", limit, limit))
 
 	assertNilError(t, err)
 	assertNotNil(t, second)
 	assertEquals(t, limit, len(second))
-	assertNotEquals(t, first[0]["name"], second[0]["name"])
+	assertNotEquals(t, first[0].Name, second[0].Name)
 
 	fmt.Println()
 	fmt.Println("Here is the answer to the quiz question on the lesson:")
@@ -64,6 +65,6 @@ func TestPersonProfile(t *testing.T) {
 	fmt.Println("Copy and paste the following answer into the text box:")
 	fmt.Println()
 
-	fmt.Println(first[0]["name"])
+	fmt.Println(first[0].Name)
 
 }
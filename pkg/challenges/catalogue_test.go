@@ -0,0 +1,74 @@
+package challenges_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/config"
+	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func TestDeleteMovieDeletesDependents(t *testing.T) {
+	// Load Settings
+	settings, err := config.ReadConfig("../../config.json")
+	assertNilError(t, err)
+
+	// Init Driver
+	driver, err := config.NewDriver(settings)
+	assertNilError(t, err)
+
+	defer func() {
+		assertNilError(t, driver.Close())
+	}()
+
+	// Create Services
+	catalogue := services.NewCatalogueService(driver)
+	ratings := services.NewRatingService(&fixtures.FixtureLoader{Prefix: "../.."}, driver, services.DefaultRatingScale())
+	reviews := services.NewReviewService(driver)
+
+	movieId := "catalogue-test-movie"
+	userId := "c5f4fd6a-7c1d-4b8e-9d43-0c9a3b1e2f10"
+
+	// Delete any existing movie, and create the User
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	_, err = session.Run("MATCH (m:Movie {tmdbId: $movieId}) DETACH DELETE m", map[string]interface{}{"movieId": movieId})
+	assertNilError(t, err)
+	_, err = session.Run("MERGE (u:User {userId: $userId}) SET u.email = 'graphacademy.catalogue@neo4j.com'", map[string]interface{}{"userId": userId})
+	assertNilError(t, err)
+
+	// Create the movie, then rate and review it
+	movie, err := catalogue.SaveMovie(context.Background(), userId, services.MovieInput{TmdbId: movieId, Title: "Catalogue Test"})
+	assertNilError(t, err)
+	_, err = ratings.Save(context.Background(), 4, movieId, userId)
+	assertNilError(t, err)
+	review, err := reviews.Save(context.Background(), userId, movieId, services.ReviewInput{
+		Title: "Deleted with its movie",
+		Body:  "This review must not outlive the movie it reviews.",
+	})
+	assertNilError(t, err)
+
+	// Delete the movie
+	_, err = catalogue.DeleteMovie(context.Background(), userId, movieId, *movie.Version)
+	assertNilError(t, err)
+
+	// Its review and rating aggregates are gone
+	result, err := session.Run(`
+		OPTIONAL MATCH (review:Review {id: $reviewId})
+		WITH count(review) AS reviews
+		OPTIONAL MATCH (aggregate)
+		WHERE (aggregate:RatingAggregate OR aggregate:RatingMonth)
+			AND NOT (:Movie)-[:HAS_RATING_AGGREGATE|HAS_MONTHLY_RATINGS]->(aggregate)
+		RETURN reviews, count(aggregate) AS aggregates`,
+		map[string]interface{}{"reviewId": review.Id})
+	assertNilError(t, err)
+	record, err := result.Single()
+	assertNilError(t, err)
+
+	reviewCount, _ := record.Get("reviews")
+	aggregateCount, _ := record.Get("aggregates")
+	assertEquals(t, int64(0), reviewCount)
+	assertEquals(t, int64(0), aggregateCount)
+}
@@ -0,0 +1,70 @@
+package challenges_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/config"
+	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+func TestGenreStats(outer *testing.T) {
+	// Load Settings
+	settings, err := config.ReadConfig("../../config.json")
+	assertNilError(outer, err)
+
+	// Init Driver
+	driver, err := config.NewDriver(settings)
+	assertNilError(outer, err)
+
+	defer func() {
+		assertNilError(outer, driver.Close())
+	}()
+
+	outer.Run("matches all genres more strictly than any genre", func(t *testing.T) {
+		service := services.NewMovieService(
+			&fixtures.FixtureLoader{Prefix: "../.."},
+			driver,
+			services.NewFullTextIndexes(driver),
+			services.DefaultSimilarityWeights(),
+			services.DefaultRatingScale())
+
+		genres := []string{"Action", "Comedy"}
+		page := paging.NewPaging("", "title", "ASC", 0, 10000)
+
+		all, err := service.FindAllByGenres(context.Background(), genres, services.AllGenres, "", page)
+		assertNilError(t, err)
+		anyGenre, err := service.FindAllByGenres(context.Background(), genres, services.AnyGenre, "", page)
+		assertNilError(t, err)
+
+		assertTrue(t, len(all) > 0)
+		assertTrue(t, len(all) < len(anyGenre))
+		for _, movie := range all {
+			names := map[string]bool{}
+			for _, genre := range movie.Genres {
+				names[genre.Name] = true
+			}
+			assertTrue(t, names["Action"] && names["Comedy"])
+		}
+	})
+
+	outer.Run("describes a genre", func(t *testing.T) {
+		service := services.NewGenreService(&fixtures.FixtureLoader{Prefix: "../.."}, driver)
+
+		stats, err := service.FindStatsByName(context.Background(), "Action")
+		assertNilError(t, err)
+
+		assertEquals(t, "Action", stats.Genre.Name)
+		assertNotNil(t, stats.AverageImdbRating)
+		assertTrue(t, len(stats.Decades) > 0)
+		assertTrue(t, len(stats.TopActors) > 0)
+		assertTrue(t, len(stats.TopDirectors) > 0)
+		assertTrue(t, len(stats.CoOccurringGenres) > 0)
+		for _, actor := range stats.TopActors {
+			assertStringNotEmpty(t, actor.Person.Name)
+			assertTrue(t, actor.Movies > 0)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package challenges_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/config"
+	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func TestRatingsVisibility(t *testing.T) {
+	// Load Settings
+	settings, err := config.ReadConfig("../../config.json")
+	assertNilError(t, err)
+
+	// Init Driver
+	driver, err := config.NewDriver(settings)
+	assertNilError(t, err)
+
+	defer func() {
+		assertNilError(t, driver.Close())
+	}()
+
+	// Create Services
+	social := services.NewSocialService(driver)
+	ratings := services.NewRatingService(&fixtures.FixtureLoader{Prefix: "../.."}, driver, services.DefaultRatingScale())
+	reviews := services.NewReviewService(driver)
+
+	authorId := "3d1f6b8e-4a2c-4e9d-b7f0-6c5a2e8d1b43"
+	followerId := "8a4e2c6f-1b3d-4f7a-9e5c-2d6b8f0a3c17"
+	strangerId := "e6b0d4a2-7f9c-4b1e-a3d5-9c7e1f5b0d28"
+	pulpFiction := "680"
+
+	// Create the users, and drop the reviews and follows of previous runs
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	_, err = session.Run(`
+		UNWIND [$authorId, $followerId, $strangerId] AS userId
+		MERGE (u:User {userId: userId})
+		SET u.name = 'Visibility Test'
+		WITH u
+		OPTIONAL MATCH (u)-[:WROTE]->(review:Review)
+		DETACH DELETE review
+		WITH DISTINCT u
+		OPTIONAL MATCH (u)-[follows:FOLLOWS]->()
+		DELETE follows`,
+		map[string]interface{}{"authorId": authorId, "followerId": followerId, "strangerId": strangerId})
+	assertNilError(t, err)
+
+	// The author rates and reviews a movie, and is followed by the follower
+	_, err = ratings.Save(context.Background(), 4, pulpFiction, authorId)
+	assertNilError(t, err)
+	_, err = reviews.Save(context.Background(), authorId, pulpFiction, services.ReviewInput{
+		Title: "Visible to some",
+		Body:  "The rating of this review is only shown to whom the author allows.",
+	})
+	assertNilError(t, err)
+	_, err = social.Follow(context.Background(), followerId, authorId)
+	assertNilError(t, err)
+
+	for _, visibility := range []string{services.PublicRatings, services.FollowersRatings} {
+		_, err = social.SavePrivacySettings(context.Background(), authorId, services.PrivacySettings{RatingsVisibility: visibility})
+		assertNilError(t, err)
+
+		for viewerId, expected := range map[string]bool{
+			authorId:   true,
+			followerId: true,
+			strangerId: visibility == services.PublicRatings,
+			"":         visibility == services.PublicRatings,
+		} {
+			// Activity decides in Go whether the ratings are visible
+			activity, err := social.FindAllActivityByUserId(context.Background(), authorId, viewerId, paging.NewPaging("", "", "", 0, 10))
+			assertNilError(t, err)
+			ratedInActivity := false
+			for _, entry := range activity {
+				if entry.Type == services.RatingActivity {
+					ratedInActivity = true
+				}
+			}
+
+			// Reviews decide it in Cypher
+			movieReviews, err := reviews.FindAllByMovieId(context.Background(), pulpFiction, viewerId, paging.NewPaging("", "", "", 0, 100))
+			assertNilError(t, err)
+			ratedInReview := false
+			for _, review := range movieReviews {
+				if review.User.Id == authorId {
+					ratedInReview = review.Rating != nil
+				}
+			}
+
+			if ratedInActivity != expected || ratedInReview != expected {
+				t.Fatalf("expected %q ratings visible to %q to be %v, got %v in activity and %v in reviews",
+					visibility, viewerId, expected, ratedInActivity, ratedInReview)
+			}
+		}
+	}
+}
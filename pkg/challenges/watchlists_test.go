@@ -0,0 +1,132 @@
+package challenges_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/config"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func TestWatchlists(outer *testing.T) {
+	// Load Settings
+	settings, err := config.ReadConfig("../../config.json")
+	assertNilError(outer, err)
+
+	// Init Driver
+	driver, err := config.NewDriver(settings)
+	assertNilError(outer, err)
+
+	defer func() {
+		assertNilError(outer, driver.Close())
+	}()
+
+	// Create Service
+	service := services.NewWatchlistService(driver)
+
+	ownerId := "0e7c2a4f-5d6b-4f1e-8a3c-9b2d1f0e6a71"
+	viewerId := "5b9d3e1a-2c4f-4a6b-8d7e-1f0c3a5b7d92"
+	toyStory := "862"
+	goodfellas := "769"
+	pulpFiction := "680"
+	page := paging.NewPaging("", "", "", 0, 10)
+
+	// Create the users, and drop the lists of previous runs
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	_, err = session.Run(`
+		UNWIND [$ownerId, $viewerId] AS userId
+		MERGE (u:User {userId: userId})
+		SET u.name = 'Watchlist Test'
+		WITH u
+		OPTIONAL MATCH (u)-[:OWNS]->(l:Watchlist)
+		DETACH DELETE l`,
+		map[string]interface{}{"ownerId": ownerId, "viewerId": viewerId})
+	assertNilError(outer, err)
+
+	outer.Run("shifts positions when inserting, moving and removing movies", func(t *testing.T) {
+		list, err := service.Save(context.Background(), ownerId, services.WatchlistInput{Title: "Ordering"})
+		assertNilError(t, err)
+
+		for _, movieId := range []string{toyStory, goodfellas} {
+			list, err = service.AddMovie(context.Background(), ownerId, list.Id, movieId, nil, page)
+			assertNilError(t, err)
+		}
+		first := int64(1)
+		list, err = service.AddMovie(context.Background(), ownerId, list.Id, pulpFiction, &first, page)
+		assertNilError(t, err)
+		assertWatchlistOrder(t, list, pulpFiction, toyStory, goodfellas)
+
+		// Moving a movie already in the list keeps the positions contiguous
+		last := int64(3)
+		list, err = service.AddMovie(context.Background(), ownerId, list.Id, pulpFiction, &last, page)
+		assertNilError(t, err)
+		assertWatchlistOrder(t, list, toyStory, goodfellas, pulpFiction)
+
+		list, err = service.RemoveMovie(context.Background(), ownerId, list.Id, toyStory, page)
+		assertNilError(t, err)
+		assertWatchlistOrder(t, list, goodfellas, pulpFiction)
+		assertEquals(t, int64(2), list.MovieCount)
+	})
+
+	outer.Run("only shows private lists to their owner", func(t *testing.T) {
+		list, err := service.Save(context.Background(), ownerId, services.WatchlistInput{
+			Title:      "Private",
+			Visibility: services.PrivateList,
+		})
+		assertNilError(t, err)
+		_, err = service.AddMovie(context.Background(), ownerId, list.Id, goodfellas, nil, page)
+		assertNilError(t, err)
+
+		// The owner rated the movie, which flags it for them
+		_, err = session.Run(`
+			MATCH (u:User {userId: $ownerId}), (m:Movie {tmdbId: $movieId})
+			MERGE (u)-[r:RATED]->(m)
+			SET r.rating = 4.0, r.timestamp = timestamp()`,
+			map[string]interface{}{"ownerId": ownerId, "movieId": goodfellas})
+		assertNilError(t, err)
+
+		found, err := service.FindOneById(context.Background(), list.Id, ownerId, page)
+		assertNilError(t, err)
+		assertEquals(t, 1, len(found.Movies))
+		assertNotNil(t, found.Movies[0].Movie.Rating)
+		assertEquals(t, 4.0, *found.Movies[0].Movie.Rating)
+
+		for _, other := range []string{viewerId, ""} {
+			_, err = service.FindOneById(context.Background(), list.Id, other, page)
+			assertWatchlistNotFound(t, err)
+		}
+		_, err = service.AddMovie(context.Background(), viewerId, list.Id, toyStory, nil, page)
+		assertWatchlistNotFound(t, err)
+
+		// Unlisted lists are visible to others, flagged for the viewer
+		list, err = service.Update(context.Background(), ownerId, list.Id, services.WatchlistInput{
+			Title:      "Unlisted",
+			Visibility: services.UnlistedList,
+		})
+		assertNilError(t, err)
+		found, err = service.FindOneById(context.Background(), list.Id, viewerId, page)
+		assertNilError(t, err)
+		assertEquals(t, 1, len(found.Movies))
+		assertTrue(t, found.Movies[0].Movie.Rating == nil)
+		assertNotNil(t, found.Movies[0].Movie.Favorite)
+	})
+}
+
+func assertWatchlistOrder(t *testing.T, list *services.Watchlist, movieIds ...string) {
+	t.Helper()
+	assertEquals(t, len(movieIds), len(list.Movies))
+	for i, movieId := range movieIds {
+		assertEquals(t, movieId, list.Movies[i].Movie.TmdbId)
+		assertEquals(t, int64(i+1), list.Movies[i].Position)
+	}
+}
+
+func assertWatchlistNotFound(t *testing.T, err error) {
+	t.Helper()
+	domainError, ok := err.(*services.DomainError)
+	assertTrue(t, ok)
+	assertEquals(t, 404, domainError.StatusCode())
+}
@@ -4,6 +4,7 @@ package config
 import (
 	"encoding/json"
 	"io/ioutil"
+	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
@@ -36,6 +37,95 @@ type Config struct {
 	Port       int    `json:"APP_PORT"`
 	JwtSecret  string `json:"JWT_SECRET"`
 	SaltRounds int    `json:"SALT_ROUNDS"`
+
+	// AccessTokenTtlMinutes bounds the lifetime of access tokens, renewed
+	// with refresh tokens living RefreshTokenTtlDays
+	AccessTokenTtlMinutes int `json:"ACCESS_TOKEN_TTL_MINUTES"`
+	RefreshTokenTtlDays   int `json:"REFRESH_TOKEN_TTL_DAYS"`
+
+	// RequestTimeoutMs bounds the time spent serving a single API request,
+	// database transactions included
+	RequestTimeoutMs int `json:"REQUEST_TIMEOUT_MS"`
+
+	// SuggestBudgetMs bounds the time typeahead suggestions may spend on the
+	// database before answering from the cache alone
+	SuggestBudgetMs int `json:"SUGGEST_BUDGET_MS"`
+
+	// Similarity weights of shared actors, directors, genres and high ratings,
+	// defaulting to services.DefaultSimilarityWeights when omitted
+	SimilarityActorWeight    *float64 `json:"SIMILARITY_ACTOR_WEIGHT"`
+	SimilarityDirectorWeight *float64 `json:"SIMILARITY_DIRECTOR_WEIGHT"`
+	SimilarityGenreWeight    *float64 `json:"SIMILARITY_GENRE_WEIGHT"`
+	SimilarityCoRatingWeight *float64 `json:"SIMILARITY_CO_RATING_WEIGHT"`
+
+	// TrendingHalfLifeHours is the time after which user activity weighs half
+	// as much in the trending score of movies, refreshed every
+	// TrendingRefreshMinutes
+	TrendingHalfLifeHours  int `json:"TRENDING_HALF_LIFE_HOURS"`
+	TrendingRefreshMinutes int `json:"TRENDING_REFRESH_MINUTES"`
+
+	// Rating scale bounds and step, defaulting to
+	// services.DefaultRatingScale when omitted
+	RatingMin  *float64 `json:"RATING_MIN"`
+	RatingMax  *float64 `json:"RATING_MAX"`
+	RatingStep *float64 `json:"RATING_STEP"`
+
+	// DevMode serves the frontend from the public directory on disk
+	// instead of the copy embedded in the binary
+	DevMode bool `json:"APP_DEV_MODE"`
+}
+
+// RequestTimeout returns the configured request timeout, 10 seconds by default
+func (c *Config) RequestTimeout() time.Duration {
+	if c.RequestTimeoutMs <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.RequestTimeoutMs) * time.Millisecond
+}
+
+// SuggestBudget returns the configured suggestion latency budget,
+// 150 milliseconds by default
+func (c *Config) SuggestBudget() time.Duration {
+	if c.SuggestBudgetMs <= 0 {
+		return 150 * time.Millisecond
+	}
+	return time.Duration(c.SuggestBudgetMs) * time.Millisecond
+}
+
+// TrendingHalfLife returns the configured half-life of user activity,
+// 72 hours by default
+func (c *Config) TrendingHalfLife() time.Duration {
+	if c.TrendingHalfLifeHours <= 0 {
+		return 72 * time.Hour
+	}
+	return time.Duration(c.TrendingHalfLifeHours) * time.Hour
+}
+
+// TrendingRefreshInterval returns the configured refresh interval of the
+// trending scores, 15 minutes by default
+func (c *Config) TrendingRefreshInterval() time.Duration {
+	if c.TrendingRefreshMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.TrendingRefreshMinutes) * time.Minute
+}
+
+// AccessTokenTtl returns the configured lifetime of access tokens,
+// 15 minutes by default
+func (c *Config) AccessTokenTtl() time.Duration {
+	if c.AccessTokenTtlMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.AccessTokenTtlMinutes) * time.Minute
+}
+
+// RefreshTokenTtl returns the configured lifetime of refresh tokens, 30 days
+// by default
+func (c *Config) RefreshTokenTtl() time.Duration {
+	if c.RefreshTokenTtlDays <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(c.RefreshTokenTtlDays) * 24 * time.Hour
 }
 
 /**
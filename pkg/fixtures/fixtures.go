@@ -1,6 +1,7 @@
 package fixtures
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 
@@ -11,34 +12,18 @@ type FixtureLoader struct {
 	Prefix string
 }
 
-// ReadArray reads the content of a JSON array fixture file
+// Read decodes the content of a JSON fixture file into target
 // Note: error handling is a bit brutal here since fixtures will gradually be
 // replaced by data coming from a Neo4j instance directly
-func (fl *FixtureLoader) ReadArray(fixture string) (_ []map[string]interface{}, err error) {
+func (fl *FixtureLoader) Read(fixture string, target interface{}) (err error) {
 	newPath := filepath.Join(fl.Prefix, fixture)
 
 	file, err := os.Open(newPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() {
 		err = ioutils.DeferredClose(file, err)
 	}()
-	return ioutils.ReadJsonArray(file)
-}
-
-// ReadObject reads the content of a JSON object fixture file
-// Note: error handling is a bit brutal here since fixtures will gradually be
-// replaced by data coming from a Neo4j instance directly
-func (fl *FixtureLoader) ReadObject(fixture string) (_ map[string]interface{}, err error) {
-	newPath := filepath.Join(fl.Prefix, fixture)
-
-	file, err := os.Open(newPath)
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		err = ioutils.DeferredClose(file, err)
-	}()
-	return ioutils.ReadJson(file)
+	return json.NewDecoder(file).Decode(target)
 }
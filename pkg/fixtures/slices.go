@@ -1,14 +1,11 @@
 package fixtures
 
-func Slice(slice []map[string]interface{}, skip, limit int) []map[string]interface{} {
-	start, end := sliceBounds(slice, skip, limit)
-	return slice[start:end]
-}
-
-func sliceBounds(slice []map[string]interface{}, skip, limit int) (int, int) {
-	maxIndex := len(slice) - 1
-	return minInt(skip, minInt(skip, maxIndex)),
-		minInt(skip+limit, minInt(skip+limit, maxIndex+1))
+// Bounds returns the start and end indices of the page of a slice of the given
+// length, skipping `skip` elements and keeping at most `limit` of them
+func Bounds(length, skip, limit int) (int, int) {
+	start := minInt(maxInt(skip, 0), length)
+	end := minInt(start+maxInt(limit, 0), length)
+	return start, end
 }
 
 func minInt(a, b int) int {
@@ -17,3 +14,10 @@ func minInt(a, b int) int {
 	}
 	return b
 }
+
+func maxInt(a, b int) int {
+	if a >= b {
+		return a
+	}
+	return b
+}
@@ -29,3 +29,10 @@ func ReadJsonArray(r io.Reader) ([]map[string]interface{}, error) {
 	}
 	return results, nil
 }
+
+// ReadJsonInto decodes a JSON document into target, rejecting unknown fields
+func ReadJsonInto(r io.Reader, target interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
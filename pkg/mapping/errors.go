@@ -0,0 +1,37 @@
+package mapping
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DecodeError reports a value that cannot be decoded into its target.
+// Path names the record column, followed by the map keys and list indices
+// leading to the offending value, e.g. movie.actors[2].name
+type DecodeError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: expected %s, got %s", describe(e.Path), e.Expected, e.Actual)
+}
+
+func mismatch(path string, expected reflect.Type, value interface{}) error {
+	return &DecodeError{Path: path, Expected: expected.String(), Actual: describeValue(value)}
+}
+
+func describe(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return fmt.Sprintf("column %q", path)
+}
+
+func describeValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	return reflect.TypeOf(value).String()
+}
@@ -0,0 +1,362 @@
+// Package mapping decodes values returned by the Neo4j driver (records, nodes,
+// relationships, maps, lists and scalars) into tagged Go structs.
+//
+// Struct fields are matched by the name given in their `neo4j` tag, falling back
+// to the name of their `json` tag and finally to the field name itself.
+// A field tagged `neo4j:"-"`, or `json:"-"` without a `neo4j` tag, is ignored.
+// Fields are optional: missing and null values leave them untouched, unless
+// they are tagged with the `required` option, e.g. `neo4j:"tmdbId,required"`.
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	dateLayout          = "2006-01-02"
+	localDateTimeLayout = "2006-01-02T15:04:05.999999999"
+	localTimeLayout     = "15:04:05.999999999"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(neo4j.Duration{})
+)
+
+// DecodeRecord decodes the columns of record into the fields of the struct
+// pointed to by target
+func DecodeRecord(record *neo4j.Record, target interface{}) error {
+	if record == nil {
+		return fmt.Errorf("cannot decode nil record")
+	}
+	values := make(map[string]interface{}, len(record.Keys))
+	for i, key := range record.Keys {
+		values[key] = record.Values[i]
+	}
+	return decodeInto(values, target, "")
+}
+
+// DecodeColumn decodes the value of the given column of record into target
+func DecodeColumn(record *neo4j.Record, column string, target interface{}) error {
+	if record == nil {
+		return fmt.Errorf("cannot decode nil record")
+	}
+	value, found := record.Get(column)
+	if !found {
+		return &DecodeError{Path: column, Expected: "a value", Actual: "no such column"}
+	}
+	return decodeInto(value, target, column)
+}
+
+// Decode decodes a single value returned by the driver, e.g. a neo4j.Node or a
+// map projection, into target
+func Decode(value interface{}, target interface{}) error {
+	return decodeInto(value, target, "")
+}
+
+func decodeInto(value interface{}, target interface{}, path string) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", target)
+	}
+	return decode(value, targetValue.Elem(), path)
+}
+
+func decode(value interface{}, target reflect.Value, path string) error {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	switch target.Type() {
+	case timeType:
+		return decodeTime(value, target, path)
+	case durationType:
+		duration, ok := value.(neo4j.Duration)
+		if !ok {
+			return mismatch(path, target.Type(), value)
+		}
+		target.Set(reflect.ValueOf(duration))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		element := reflect.New(target.Type().Elem())
+		if err := decode(value, element.Elem(), path); err != nil {
+			return err
+		}
+		target.Set(element)
+		return nil
+	case reflect.Interface:
+		rawValue := reflect.ValueOf(value)
+		if !rawValue.Type().AssignableTo(target.Type()) {
+			return mismatch(path, target.Type(), value)
+		}
+		target.Set(rawValue)
+		return nil
+	case reflect.String:
+		return decodeString(value, target, path)
+	case reflect.Bool:
+		boolean, ok := value.(bool)
+		if !ok {
+			return mismatch(path, target.Type(), value)
+		}
+		target.SetBool(boolean)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return decodeInt(value, target, path)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return decodeUint(value, target, path)
+	case reflect.Float32, reflect.Float64:
+		return decodeFloat(value, target, path)
+	case reflect.Slice:
+		return decodeSlice(value, target, path)
+	case reflect.Map:
+		return decodeMap(value, target, path)
+	case reflect.Struct:
+		return decodeStruct(value, target, path)
+	}
+	return fmt.Errorf("%s: unsupported target type %s", describe(path), target.Type())
+}
+
+func decodeTime(value interface{}, target reflect.Value, path string) error {
+	var result time.Time
+	switch typedValue := value.(type) {
+	case time.Time:
+		result = typedValue
+	case neo4j.Date:
+		result = typedValue.Time()
+	case neo4j.LocalDateTime:
+		result = typedValue.Time()
+	case neo4j.Time:
+		result = typedValue.Time()
+	case neo4j.LocalTime:
+		result = typedValue.Time()
+	default:
+		return mismatch(path, target.Type(), value)
+	}
+	target.Set(reflect.ValueOf(result))
+	return nil
+}
+
+// decodeString also accepts temporal values, formatted as ISO-8601 strings
+func decodeString(value interface{}, target reflect.Value, path string) error {
+	var result string
+	switch typedValue := value.(type) {
+	case string:
+		result = typedValue
+	case neo4j.Date:
+		result = typedValue.Time().Format(dateLayout)
+	case neo4j.LocalDateTime:
+		result = typedValue.Time().Format(localDateTimeLayout)
+	case neo4j.LocalTime:
+		result = typedValue.Time().Format(localTimeLayout)
+	case neo4j.Time:
+		result = typedValue.Time().Format(localTimeLayout + "Z07:00")
+	case time.Time:
+		result = typedValue.Format(time.RFC3339Nano)
+	case neo4j.Duration:
+		result = typedValue.String()
+	default:
+		return mismatch(path, target.Type(), value)
+	}
+	target.SetString(result)
+	return nil
+}
+
+// decodeInt also accepts floats without fractional part, since JSON and some
+// Cypher functions produce those for integral values
+func decodeInt(value interface{}, target reflect.Value, path string) error {
+	var result int64
+	switch typedValue := value.(type) {
+	case int64:
+		result = typedValue
+	case int:
+		result = int64(typedValue)
+	case float64:
+		if typedValue != math.Trunc(typedValue) {
+			return &DecodeError{Path: path, Expected: target.Type().String(), Actual: fmt.Sprintf("float64 %v", typedValue)}
+		}
+		result = int64(typedValue)
+	default:
+		return mismatch(path, target.Type(), value)
+	}
+	if target.OverflowInt(result) {
+		return &DecodeError{Path: path, Expected: target.Type().String(), Actual: fmt.Sprintf("out of range value %d", result)}
+	}
+	target.SetInt(result)
+	return nil
+}
+
+func decodeUint(value interface{}, target reflect.Value, path string) error {
+	signed := reflect.New(reflect.TypeOf(int64(0))).Elem()
+	if err := decodeInt(value, signed, path); err != nil {
+		return &DecodeError{Path: path, Expected: target.Type().String(), Actual: describeValue(value)}
+	}
+	if signed.Int() < 0 || target.OverflowUint(uint64(signed.Int())) {
+		return &DecodeError{Path: path, Expected: target.Type().String(), Actual: fmt.Sprintf("out of range value %d", signed.Int())}
+	}
+	target.SetUint(uint64(signed.Int()))
+	return nil
+}
+
+func decodeFloat(value interface{}, target reflect.Value, path string) error {
+	var result float64
+	switch typedValue := value.(type) {
+	case float64:
+		result = typedValue
+	case int64:
+		result = float64(typedValue)
+	case int:
+		result = float64(typedValue)
+	default:
+		return mismatch(path, target.Type(), value)
+	}
+	target.SetFloat(result)
+	return nil
+}
+
+func decodeSlice(value interface{}, target reflect.Value, path string) error {
+	rawValue := reflect.ValueOf(value)
+	if rawValue.Kind() != reflect.Slice {
+		return mismatch(path, target.Type(), value)
+	}
+	if rawValue.Type().AssignableTo(target.Type()) {
+		target.Set(rawValue)
+		return nil
+	}
+	result := reflect.MakeSlice(target.Type(), rawValue.Len(), rawValue.Len())
+	for i := 0; i < rawValue.Len(); i++ {
+		elementPath := fmt.Sprintf("%s[%d]", path, i)
+		if err := decode(rawValue.Index(i).Interface(), result.Index(i), elementPath); err != nil {
+			return err
+		}
+	}
+	target.Set(result)
+	return nil
+}
+
+func decodeMap(value interface{}, target reflect.Value, path string) error {
+	if target.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%s: unsupported target type %s, map keys must be strings", describe(path), target.Type())
+	}
+	values, ok := properties(value)
+	if !ok {
+		return mismatch(path, target.Type(), value)
+	}
+	result := reflect.MakeMapWithSize(target.Type(), len(values))
+	for key, rawElement := range values {
+		element := reflect.New(target.Type().Elem()).Elem()
+		if err := decode(rawElement, element, join(path, key)); err != nil {
+			return err
+		}
+		result.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), element)
+	}
+	target.Set(result)
+	return nil
+}
+
+func decodeStruct(value interface{}, target reflect.Value, path string) error {
+	values, ok := properties(value)
+	if !ok {
+		return mismatch(path, target.Type(), value)
+	}
+	for _, field := range fieldsOf(target.Type()) {
+		fieldPath := join(path, field.name)
+		rawField, found := values[field.name]
+		if !found || rawField == nil {
+			if field.required {
+				return &DecodeError{Path: fieldPath, Expected: target.Type().Field(field.index).Type.String(), Actual: "missing value"}
+			}
+			continue
+		}
+		if err := decode(rawField, target.Field(field.index), fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// properties returns the key/value pairs of a map, or the properties of a node
+// or relationship
+func properties(value interface{}) (map[string]interface{}, bool) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return typedValue, true
+	case neo4j.Node:
+		return typedValue.Props, true
+	case *neo4j.Node:
+		return typedValue.Props, true
+	case neo4j.Relationship:
+		return typedValue.Props, true
+	case *neo4j.Relationship:
+		return typedValue.Props, true
+	}
+	return nil, false
+}
+
+type structField struct {
+	index    int
+	name     string
+	required bool
+}
+
+func fieldsOf(structType reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, options, found := tagOf(field, "neo4j")
+		if !found {
+			name, _, found = tagOf(field, "json")
+			options = ""
+		}
+		if found && name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, structField{
+			index:    i,
+			name:     name,
+			required: hasOption(options, "required"),
+		})
+	}
+	return fields
+}
+
+func tagOf(field reflect.StructField, key string) (name string, options string, found bool) {
+	tag, found := field.Tag.Lookup(key)
+	if !found {
+		return "", "", false
+	}
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:], true
+	}
+	return tag, "", true
+}
+
+func hasOption(options string, option string) bool {
+	for _, candidate := range strings.Split(options, ",") {
+		if candidate == option {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,154 @@
+package mapping_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+type person struct {
+	TmdbId string    `json:"tmdbId" neo4j:"tmdbId,required"`
+	Name   string    `json:"name"`
+	Born   string    `json:"born,omitempty"`
+	Died   time.Time `neo4j:"died"`
+	Secret string    `json:"-"`
+}
+
+type movie struct {
+	Title      string            `json:"title"`
+	Year       int               `json:"year"`
+	ImdbRating float64           `json:"imdbRating"`
+	Votes      *int64            `json:"imdbVotes,omitempty"`
+	Languages  []string          `json:"languages"`
+	Actors     []person          `json:"actors"`
+	Roles      map[string]string `json:"roles"`
+	Runtime    neo4j.Duration
+}
+
+func TestDecodeRecord(outer *testing.T) {
+	born := time.Date(1940, 4, 25, 0, 0, 0, 0, time.UTC)
+	record := &neo4j.Record{
+		Keys: []string{"title", "year", "imdbRating", "languages", "actors", "roles", "Runtime"},
+		Values: []interface{}{
+			"The Godfather",
+			int64(1972),
+			int64(9),
+			[]interface{}{"English", "Italian"},
+			[]interface{}{
+				neo4j.Node{Props: map[string]interface{}{
+					"tmdbId": "1158",
+					"name":   "Al Pacino",
+					"born":   neo4j.DateOf(born),
+					"Secret": "ignored",
+				}},
+			},
+			map[string]interface{}{"1158": "Michael Corleone"},
+			neo4j.Duration{Seconds: 175 * 60},
+		},
+	}
+
+	var result movie
+	if err := mapping.DecodeRecord(record, &result); err != nil {
+		outer.Fatal(err)
+	}
+
+	outer.Run("coerces numbers", func(t *testing.T) {
+		if result.Year != 1972 || result.ImdbRating != 9.0 {
+			t.Fatalf("unexpected numbers %d %f", result.Year, result.ImdbRating)
+		}
+	})
+	outer.Run("leaves optional fields untouched", func(t *testing.T) {
+		if result.Votes != nil {
+			t.Fatalf("expected missing imdbVotes to stay nil")
+		}
+		if !result.Actors[0].Died.IsZero() {
+			t.Fatalf("expected missing died to stay zero")
+		}
+	})
+	outer.Run("decodes nested lists, nodes and maps", func(t *testing.T) {
+		if len(result.Languages) != 2 || result.Languages[1] != "Italian" {
+			t.Fatalf("unexpected languages %v", result.Languages)
+		}
+		actor := result.Actors[0]
+		if actor.TmdbId != "1158" || actor.Name != "Al Pacino" || actor.Secret != "" {
+			t.Fatalf("unexpected actor %+v", actor)
+		}
+		if result.Roles["1158"] != "Michael Corleone" {
+			t.Fatalf("unexpected roles %v", result.Roles)
+		}
+	})
+	outer.Run("decodes temporal values", func(t *testing.T) {
+		if result.Actors[0].Born != "1940-04-25" {
+			t.Fatalf("unexpected born %q", result.Actors[0].Born)
+		}
+		if result.Runtime.Seconds != 175*60 {
+			t.Fatalf("unexpected runtime %v", result.Runtime)
+		}
+	})
+}
+
+func TestDecodeColumnErrors(outer *testing.T) {
+	testCases := []struct {
+		name    string
+		value   interface{}
+		message string
+	}{
+		{
+			name:    "type mismatch",
+			value:   map[string]interface{}{"title": int64(42)},
+			message: `column "movie.title": expected string, got int64`,
+		},
+		{
+			name: "nested type mismatch",
+			value: map[string]interface{}{"actors": []interface{}{
+				map[string]interface{}{"tmdbId": "1158"},
+				map[string]interface{}{"tmdbId": "1159", "name": true},
+			}},
+			message: `column "movie.actors[1].name": expected string, got bool`,
+		},
+		{
+			name: "missing required field",
+			value: map[string]interface{}{"actors": []interface{}{
+				map[string]interface{}{"name": "Al Pacino"},
+			}},
+			message: `column "movie.actors[0].tmdbId": expected string, got missing value`,
+		},
+		{
+			name:    "lossy float",
+			value:   map[string]interface{}{"year": 1972.5},
+			message: `column "movie.year": expected int, got float64 1972.5`,
+		},
+		{
+			name:    "not a map",
+			value:   "The Godfather",
+			message: `column "movie": expected mapping_test.movie, got string`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.name, func(t *testing.T) {
+			record := &neo4j.Record{Keys: []string{"movie"}, Values: []interface{}{testCase.value}}
+			var result movie
+			err := mapping.DecodeColumn(record, "movie", &result)
+			var decodeErr *mapping.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("expected a DecodeError, got %v", err)
+			}
+			if err.Error() != testCase.message {
+				t.Fatalf("expected %q, got %q", testCase.message, err.Error())
+			}
+		})
+	}
+
+	outer.Run("missing column", func(t *testing.T) {
+		var result movie
+		err := mapping.DecodeColumn(&neo4j.Record{}, "movie", &result)
+		if err == nil || err.Error() != `column "movie": expected a value, got no such column` {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
@@ -1,9 +1,8 @@
 package routes
 
 import (
-	"fmt"
+	"context"
 	"net/http"
-	"reflect"
 	"strconv"
 	"strings"
 
@@ -13,18 +12,30 @@ import (
 )
 
 type accountRoutes struct {
-	ratings   services.RatingService
-	auth      services.AuthService
-	favorites services.FavoriteService
+	ratings         services.RatingService
+	auth            services.AuthService
+	favorites       services.FavoriteService
+	recommendations services.RecommendationService
+	watchlists      services.WatchlistService
+	reviews         services.ReviewService
+	social          services.SocialService
 }
 
 func NewAccountRoutes(ratings services.RatingService,
 	auth services.AuthService,
-	favorites services.FavoriteService) Routable {
+	favorites services.FavoriteService,
+	recommendations services.RecommendationService,
+	watchlists services.WatchlistService,
+	reviews services.ReviewService,
+	social services.SocialService) Routable {
 	return &accountRoutes{
-		ratings:   ratings,
-		auth:      auth,
-		favorites: favorites,
+		ratings:         ratings,
+		auth:            auth,
+		favorites:       favorites,
+		recommendations: recommendations,
+		watchlists:      watchlists,
+		reviews:         reviews,
+		social:          social,
 	}
 }
 
@@ -33,9 +44,24 @@ func (a *accountRoutes) Register(server *http.ServeMux) {
 		func(writer http.ResponseWriter, request *http.Request) {
 			path := strings.TrimPrefix(request.URL.Path, "/api/account/")
 			switch {
+			case strings.HasPrefix(path, "ratings/") && strings.HasSuffix(path, "/review"):
+				movieId := strings.TrimSuffix(strings.TrimPrefix(path, "ratings/"), "/review")
+				switch request.Method {
+				case "PUT":
+					a.SaveReview(movieId, request, writer)
+				case "DELETE":
+					a.DeleteReview(movieId, request, writer)
+				}
 			case strings.HasPrefix(path, "ratings/"):
 				movieId := strings.TrimPrefix(path, "ratings/")
-				a.SaveRating(movieId, request, writer)
+				switch request.Method {
+				case "POST":
+					a.SaveRating(movieId, request, writer)
+				case "DELETE":
+					a.DeleteRating(movieId, request, writer)
+				}
+			case path == "ratings":
+				a.FindAllRatings(request, writer)
 			case strings.HasPrefix(path, "favorites/"):
 				movieId := strings.TrimPrefix(path, "favorites/")
 				switch request.Method {
@@ -46,83 +72,235 @@ func (a *accountRoutes) Register(server *http.ServeMux) {
 				}
 
 			case path == "favorites":
-				page := paging.ParsePaging(request, paging.MovieSortableAttributes())
-				a.FindAllFavorites(page, request, writer)
+				a.FindAllFavorites(request, writer)
+			case path == "recommendations":
+				a.FindAllRecommendations(request, writer)
+			case path == "feed":
+				a.FindFeed(request, writer)
+			case path == "privacy" && request.Method == "GET":
+				a.FindPrivacySettings(request, writer)
+			case path == "privacy" && request.Method == "PUT":
+				a.SavePrivacySettings(request, writer)
+			case path == "lists" || strings.HasPrefix(path, "lists/"):
+				a.routeWatchlists(strings.TrimPrefix(strings.TrimPrefix(path, "lists"), "/"), request, writer)
 			}
 		})
 }
 
 func (a *accountRoutes) SaveRating(movieId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.SaveRating")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
 	ratingData, err := ioutils.ReadJson(request.Body)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	userId, err := extractUserId(request, a.auth)
+	rating, err := parseRating(ratingData["rating"])
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	rating, err := parseIntRating(ratingData["rating"])
+	movie, err := a.ratings.Save(ctx, rating, movieId, userId)
+	serializeJson(writer, movie, err)
+}
+
+func (a *accountRoutes) DeleteRating(movieId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.DeleteRating")
+	userId, err := requireUserId(ctx, request, a.auth)
 	if err != nil {
-		writer.WriteHeader(400)
-		writer.Header().Set("Content-Type", "text/plain")
-		_, _ = writer.Write([]byte(err.Error()))
+		serializeError(writer, err)
 		return
 	}
-	movie, err := a.ratings.Save(rating, movieId, userId)
+	movie, err := a.ratings.Delete(ctx, movieId, userId)
 	serializeJson(writer, movie, err)
 }
 
+func (a *accountRoutes) FindAllRatings(request *http.Request, writer http.ResponseWriter) {
+	page, err := parseProjectedPaging(request, paging.RatingSortableAttributes())
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.FindAllRatings")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ratings, err := a.ratings.FindAllByUserId(ctx, userId, page)
+	serializeJson(writer, ratings, err)
+}
+
+func (a *accountRoutes) SaveReview(movieId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.SaveReview")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.ReviewInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	review, err := a.reviews.Save(ctx, userId, movieId, input)
+	serializeJson(writer, review, err)
+}
+
+func (a *accountRoutes) DeleteReview(movieId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.DeleteReview")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	review, err := a.reviews.Delete(ctx, userId, movieId)
+	serializeJson(writer, review, err)
+}
+
 func (a *accountRoutes) SaveFavorite(movieId string, request *http.Request, writer http.ResponseWriter) {
-	userId, err := extractUserId(request, a.auth)
+	ctx := routeContext(request, "account.SaveFavorite")
+	userId, err := extractUserId(ctx, request, a.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movie, err := a.favorites.Save(userId, movieId)
+	movie, err := a.favorites.Save(ctx, userId, movieId)
 	serializeJson(writer, movie, err)
 }
 
-func (a *accountRoutes) FindAllFavorites(page *paging.Paging, request *http.Request, writer http.ResponseWriter) {
-	userId, err := extractUserId(request, a.auth)
+func (a *accountRoutes) FindAllFavorites(request *http.Request, writer http.ResponseWriter) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.FindAllFavorites")
+	userId, err := extractUserId(ctx, request, a.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movies, err := a.favorites.FindAllByUserId(userId, page)
+	movies, err := a.favorites.FindAllByUserId(ctx, userId, page)
 	serializeJson(writer, movies, err)
 }
 
+func (a *accountRoutes) FindAllRecommendations(request *http.Request, writer http.ResponseWriter) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.FindAllRecommendations")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	recommendations, err := a.recommendations.FindAllByUserId(ctx, userId, page)
+	serializeJson(writer, recommendations, err)
+}
+
 func (a *accountRoutes) DeleteFavorite(movieId string, request *http.Request, writer http.ResponseWriter) {
-	userId, err := extractUserId(request, a.auth)
+	ctx := routeContext(request, "account.DeleteFavorite")
+	userId, err := extractUserId(ctx, request, a.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movie, err := a.favorites.Delete(userId, movieId)
+	movie, err := a.favorites.Delete(ctx, userId, movieId)
 	serializeJson(writer, movie, err)
 }
 
-func extractUserId(request *http.Request, auth services.AuthService) (string, error) {
+func extractUserId(ctx context.Context, request *http.Request, auth services.AuthService) (string, error) {
+	return auth.ExtractUserId(ctx, bearerToken(request))
+}
+
+// requireUserId extracts the user ID of the request, failing with a 401 error
+// for anonymous requests
+func requireUserId(ctx context.Context, request *http.Request, auth services.AuthService) (string, error) {
+	userId, err := extractUserId(ctx, request, auth)
+	if err != nil {
+		return "", err
+	}
+	if userId == "" {
+		return "", services.NewDomainError(401, "Authentication required", nil)
+	}
+	return userId, nil
+}
+
+// FindFeed returns the latest ratings, favorites and reviews of the users
+// followed by the current user
+func (a *accountRoutes) FindFeed(request *http.Request, writer http.ResponseWriter) {
+	page, err := parseProjectedPaging(request, paging.ActivitySortableAttributes())
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.FindFeed")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	activity, err := a.social.FindFeed(ctx, userId, page)
+	serializeJson(writer, activity, err)
+}
+
+func (a *accountRoutes) FindPrivacySettings(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.FindPrivacySettings")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	settings, err := a.social.FindPrivacySettings(ctx, userId)
+	serializeJson(writer, settings, err)
+}
+
+func (a *accountRoutes) SavePrivacySettings(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.SavePrivacySettings")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.PrivacySettings
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	settings, err := a.social.SavePrivacySettings(ctx, userId, input)
+	serializeJson(writer, settings, err)
+}
+
+func bearerToken(request *http.Request) string {
 	bearer := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
 	// FIXME remove once frontend bug fixed
 	if bearer == "undefined" {
 		bearer = ""
 	}
-	return auth.ExtractUserId(bearer)
+	return bearer
 }
 
-// FIXME remove once frontend bug fixed - rating should always be a number
-func parseIntRating(rating interface{}) (int, error) {
-	if ratingStr, ok := rating.(string); ok {
-		return strconv.Atoi(ratingStr)
-	}
-	if ratingNumber, ok := rating.(float64); ok {
-		return int(ratingNumber), nil
+// parseRating reads a rating sent as a JSON number, or as a numeric string
+// by older frontends.
+// Malformed ratings are rejected with a 422 DomainError, the range and steps
+// are checked by the rating service.
+func parseRating(rating interface{}) (float64, error) {
+	switch typedRating := rating.(type) {
+	case float64:
+		return typedRating, nil
+	case string:
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(typedRating), 64); err == nil {
+			return parsed, nil
+		}
 	}
-	return -1, fmt.Errorf(
-		"unsupported rating type: %s, cannot parse",
-		reflect.TypeOf(rating),
-	)
+	return 0, services.NewDomainError(422, "Invalid rating", map[string]interface{}{
+		"rating": "must be a number",
+	})
 }
@@ -0,0 +1,341 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+type adminMovieRoutes struct {
+	catalogue services.CatalogueService
+	auth      services.AuthService
+}
+
+// NewAdminMovieRoutes manages movies and their actors, directors and genres.
+// Like all admin routes, it requires a token granting the admin role.
+func NewAdminMovieRoutes(catalogue services.CatalogueService, auth services.AuthService) Routable {
+	return &adminMovieRoutes{catalogue: catalogue, auth: auth}
+}
+
+func (a *adminMovieRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/admin/movies/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			path := strings.TrimPrefix(request.URL.Path, "/api/admin/movies/")
+			segments := strings.Split(path, "/")
+			switch {
+			case path == "" && request.Method == "POST":
+				a.SaveMovie(request, writer)
+			case len(segments) == 1 && request.Method == "PUT":
+				a.UpdateMovie(segments[0], request, writer)
+			case len(segments) == 1 && request.Method == "DELETE":
+				a.DeleteMovie(segments[0], request, writer)
+			case len(segments) == 3:
+				a.WriteRelationship(segments[0], segments[1], segments[2], request, writer)
+			default:
+				writer.WriteHeader(http.StatusNotFound)
+			}
+		})
+}
+
+func (a *adminMovieRoutes) SaveMovie(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.SaveMovie")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.MovieInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	movie, err := a.catalogue.SaveMovie(ctx, adminId, input)
+	serializeJson(writer, movie, err)
+}
+
+func (a *adminMovieRoutes) UpdateMovie(id string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.UpdateMovie")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.MovieInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	movie, err := a.catalogue.UpdateMovie(ctx, adminId, id, input)
+	serializeJson(writer, movie, err)
+}
+
+func (a *adminMovieRoutes) DeleteMovie(id string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.DeleteMovie")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	version, err := parseVersion(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	movie, err := a.catalogue.DeleteMovie(ctx, adminId, id, version)
+	serializeJson(writer, movie, err)
+}
+
+// relationshipTypes maps the collections of /api/admin/movies/{id}/ to the
+// relationship types they manage
+var relationshipTypes = map[string]string{
+	"actors":    services.ActedIn,
+	"directors": services.Directed,
+	"genres":    services.InGenre,
+}
+
+// WriteRelationship links (POST) or unlinks (DELETE) the movie and the
+// person or genre, e.g. POST /api/admin/movies/{id}/actors/{personId} with a
+// `role` in the body
+func (a *adminMovieRoutes) WriteRelationship(movieId, collection, otherId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.WriteRelationship")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	relationshipType, found := relationshipTypes[collection]
+	if !found {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	input := services.RelationshipInput{Type: relationshipType, From: otherId, To: movieId}
+	if relationshipType == services.InGenre {
+		input.From, input.To = movieId, otherId
+	}
+
+	var movie *services.Movie
+	switch request.Method {
+	case "POST":
+		if relationshipType == services.ActedIn && request.ContentLength != 0 {
+			var body struct {
+				Role string `json:"role"`
+			}
+			if err := readInput(request, &body); err != nil {
+				serializeError(writer, err)
+				return
+			}
+			input.Role = body.Role
+		}
+		movie, err = a.catalogue.SaveRelationship(ctx, adminId, input)
+	case "DELETE":
+		movie, err = a.catalogue.DeleteRelationship(ctx, adminId, input)
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	serializeJson(writer, movie, err)
+}
+
+type adminPeopleRoutes struct {
+	catalogue services.CatalogueService
+	auth      services.AuthService
+}
+
+func NewAdminPeopleRoutes(catalogue services.CatalogueService, auth services.AuthService) Routable {
+	return &adminPeopleRoutes{catalogue: catalogue, auth: auth}
+}
+
+func (a *adminPeopleRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/admin/people/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			id := strings.TrimPrefix(request.URL.Path, "/api/admin/people/")
+			switch {
+			case id == "" && request.Method == "POST":
+				a.SavePerson(request, writer)
+			case id != "" && request.Method == "PUT":
+				a.UpdatePerson(id, request, writer)
+			case id != "" && request.Method == "DELETE":
+				a.DeletePerson(id, request, writer)
+			default:
+				writer.WriteHeader(http.StatusNotFound)
+			}
+		})
+}
+
+func (a *adminPeopleRoutes) SavePerson(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.SavePerson")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.PersonInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	person, err := a.catalogue.SavePerson(ctx, adminId, input)
+	serializeJson(writer, person, err)
+}
+
+func (a *adminPeopleRoutes) UpdatePerson(id string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.UpdatePerson")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.PersonInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	person, err := a.catalogue.UpdatePerson(ctx, adminId, id, input)
+	serializeJson(writer, person, err)
+}
+
+func (a *adminPeopleRoutes) DeletePerson(id string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.DeletePerson")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	version, err := parseVersion(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	person, err := a.catalogue.DeletePerson(ctx, adminId, id, version)
+	serializeJson(writer, person, err)
+}
+
+type adminGenreRoutes struct {
+	catalogue services.CatalogueService
+	auth      services.AuthService
+}
+
+func NewAdminGenreRoutes(catalogue services.CatalogueService, auth services.AuthService) Routable {
+	return &adminGenreRoutes{catalogue: catalogue, auth: auth}
+}
+
+func (a *adminGenreRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/admin/genres/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			name := strings.TrimPrefix(request.URL.Path, "/api/admin/genres/")
+			switch {
+			case name == "" && request.Method == "POST":
+				a.SaveGenre(request, writer)
+			case name != "" && request.Method == "PUT":
+				a.UpdateGenre(name, request, writer)
+			case name != "" && request.Method == "DELETE":
+				a.DeleteGenre(name, request, writer)
+			default:
+				writer.WriteHeader(http.StatusNotFound)
+			}
+		})
+}
+
+func (a *adminGenreRoutes) SaveGenre(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.SaveGenre")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.GenreInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	genre, err := a.catalogue.SaveGenre(ctx, adminId, input)
+	serializeJson(writer, genre, err)
+}
+
+func (a *adminGenreRoutes) UpdateGenre(name string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.UpdateGenre")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.GenreInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	genre, err := a.catalogue.UpdateGenre(ctx, adminId, name, input)
+	serializeJson(writer, genre, err)
+}
+
+func (a *adminGenreRoutes) DeleteGenre(name string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "admin.DeleteGenre")
+	adminId, err := requireAdmin(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	version, err := parseVersion(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	genre, err := a.catalogue.DeleteGenre(ctx, adminId, name, version)
+	serializeJson(writer, genre, err)
+}
+
+type adminAuditRoutes struct {
+	catalogue services.CatalogueService
+	auth      services.AuthService
+}
+
+func NewAdminAuditRoutes(catalogue services.CatalogueService, auth services.AuthService) Routable {
+	return &adminAuditRoutes{catalogue: catalogue, auth: auth}
+}
+
+func (a *adminAuditRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/admin/audit", a.FindAllAuditEntries)
+}
+
+func (a *adminAuditRoutes) FindAllAuditEntries(writer http.ResponseWriter, request *http.Request) {
+	ctx := routeContext(request, "admin.FindAllAuditEntries")
+	if _, err := requireAdmin(ctx, request, a.auth); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	page := paging.ParsePaging(request, paging.AuditSortableAttributes())
+	entries, err := a.catalogue.FindAllAuditEntries(ctx, page)
+	serializeJson(writer, entries, err)
+}
+
+// requireAdmin returns the ID of the user behind the request, or an error
+// unless their token grants the admin role
+func requireAdmin(ctx context.Context, request *http.Request, auth services.AuthService) (string, error) {
+	principal, err := auth.ExtractPrincipal(ctx, bearerToken(request))
+	if err != nil {
+		return "", err
+	}
+	if principal == nil {
+		return "", services.NewDomainError(401, "Authentication required", nil)
+	}
+	if !principal.HasRole(services.AdminRole) {
+		return "", services.NewDomainError(403, "Admin role required", nil)
+	}
+	return principal.UserId, nil
+}
+
+// parseVersion reads the mandatory `version` query parameter of deletions
+func parseVersion(request *http.Request) (int64, error) {
+	rawVersion := request.URL.Query().Get("version")
+	version, err := strconv.ParseInt(rawVersion, 10, 64)
+	if err != nil {
+		return 0, services.NewDomainError(400, "Expected an integer version", map[string]interface{}{
+			"version": rawVersion,
+		})
+	}
+	return version, nil
+}
@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
 	"github.com/neo4j-graphacademy/neoflix/pkg/services"
 	"net/http"
 	"strings"
@@ -24,33 +23,106 @@ func (a *authRoutes) Register(server *http.ServeMux) {
 				a.Save(request, writer)
 			case strings.HasSuffix(path, "/login"):
 				a.Login(request, writer)
+			case strings.HasSuffix(path, "/refresh") && request.Method == "POST":
+				a.Refresh(request, writer)
+			case strings.HasSuffix(path, "/logout") && request.Method == "POST":
+				a.Logout(request, writer)
 			}
 		})
 }
 
+// Save registers a user from the `email`, `password` and `name` of the body
 func (a *authRoutes) Save(request *http.Request, writer http.ResponseWriter) {
-	userData, err := ioutils.ReadJson(request.Body)
-	if err != nil {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Name     string `json:"name"`
+	}
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	if err := requireFields(map[string]string{
+		"email":    input.Email,
+		"password": input.Password,
+		"name":     input.Name,
+	}); err != nil {
 		serializeError(writer, err)
 		return
 	}
-	user, err := a.auth.Save(
-		userData["email"].(string),
-		userData["password"].(string),
-		userData["name"].(string),
-	)
+	ctx := routeContext(request, "auth.Save")
+	user, err := a.auth.Save(ctx, input.Email, input.Password, input.Name)
 	serializeJson(writer, user, err)
 }
 
+// Login authenticates the user from the `email` and `password` of the body
 func (a *authRoutes) Login(request *http.Request, writer http.ResponseWriter) {
-	userData, err := ioutils.ReadJson(request.Body)
-	if err != nil {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	if err := requireFields(map[string]string{
+		"email":    input.Email,
+		"password": input.Password,
+	}); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "auth.Login")
+	user, err := a.auth.FindOneByEmailAndPassword(ctx, input.Email, input.Password)
+	serializeJson(writer, user, err)
+}
+
+// requireFields reports the blank fields of a body with a 422 DomainError
+func requireFields(fields map[string]string) error {
+	details := map[string]interface{}{}
+	for name, value := range fields {
+		if strings.TrimSpace(value) == "" {
+			details[name] = "is required"
+		}
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	return services.NewDomainError(422, "Missing fields", details)
+}
+
+// Refresh exchanges the `refreshToken` of the body for new access and refresh
+// tokens
+func (a *authRoutes) Refresh(request *http.Request, writer http.ResponseWriter) {
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := readInput(request, &input); err != nil {
 		serializeError(writer, err)
 		return
 	}
-	user, err := a.auth.FindOneByEmailAndPassword(
-		userData["email"].(string),
-		userData["password"].(string),
-	)
+	if input.RefreshToken == "" {
+		serializeError(writer, services.NewDomainError(400, "Missing refresh token", map[string]interface{}{
+			"refreshToken": "is required",
+		}))
+		return
+	}
+	ctx := routeContext(request, "auth.Refresh")
+	user, err := a.auth.Refresh(ctx, input.RefreshToken)
 	serializeJson(writer, user, err)
 }
+
+// Logout revokes the bearer token of the request and its refresh tokens
+func (a *authRoutes) Logout(request *http.Request, writer http.ResponseWriter) {
+	bearer := bearerToken(request)
+	if bearer == "" {
+		serializeError(writer, services.NewDomainError(401, "Authentication required", nil))
+		return
+	}
+	ctx := routeContext(request, "auth.Logout")
+	if err := a.auth.Logout(ctx, bearer); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+// unreachableAuthService panics, failing the test, when a request reaches the
+// service
+type unreachableAuthService struct {
+	services.AuthService
+}
+
+func TestAuthRoutesRejectIncompleteBodies(t *testing.T) {
+	server := http.NewServeMux()
+	routes.NewAuthRoutes(unreachableAuthService{}).Register(server)
+
+	for path, bodies := range map[string]map[string]int{
+		"/api/auth/register": {
+			`{}`: 422,
+			`{"email": "graphacademy@neo4j.com", "password": "letmein"}`:           422,
+			`{"email": "graphacademy@neo4j.com", "password": 42, "name": "Graph"}`: 400,
+		},
+		"/api/auth/login": {
+			`{"password": "letmein"}`:               422,
+			`{"email": " ", "password": "letmein"}`: 422,
+			`{"email": ["graphacademy@neo4j.com"]}`: 400,
+			`not json`:                              400,
+		},
+	} {
+		for body, expected := range bodies {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("POST", path, strings.NewReader(body)))
+			if recorder.Code != expected {
+				t.Errorf("expected %d for %s to %s, got %d %s", expected, body, path, recorder.Code, recorder.Body.String())
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
 	"github.com/neo4j-graphacademy/neoflix/pkg/services"
 	"net/http"
 	"strings"
@@ -30,37 +29,59 @@ func (g *genreRoutes) Register(server *http.ServeMux) {
 			path := strings.TrimPrefix(request.URL.Path, "/api/genres/")
 			switch {
 			case path == "":
-				g.FindAllGenres(writer)
+				g.FindAllGenres(request, writer)
+			case strings.HasSuffix(path, "/stats"):
+				genre := strings.TrimSuffix(path, "/stats")
+				g.FindGenreStatsByName(genre, request, writer)
 			case strings.HasSuffix(path, "/movies"):
 				genre := strings.TrimSuffix(path, "/movies")
-				pagingParams := paging.ParsePaging(request, paging.MovieSortableAttributes())
-				g.FindAllMoviesByGenre(genre, pagingParams, request, writer)
+				g.FindAllMoviesByGenre(genre, request, writer)
 			default:
-				g.FindOneGenreByName(path, writer)
+				g.FindOneGenreByName(path, request, writer)
 			}
 		})
 }
 
-func (g *genreRoutes) FindAllGenres(writer http.ResponseWriter) {
-	genres, err := g.genres.FindAll()
+func (g *genreRoutes) FindAllGenres(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "genres.FindAllGenres")
+	genres, err := g.genres.FindAll(ctx)
 	serializeJson(writer, genres, err)
 }
 
+// FindAllMoviesByGenre lists the movies of a genre, or of several
+// comma-separated genres: in all of them by default, or in any of them with
+// `match=any`
 func (g *genreRoutes) FindAllMoviesByGenre(genre string,
-	page *paging.Paging,
 	request *http.Request,
 	writer http.ResponseWriter) {
 
-	userId, err := extractUserId(request, g.auth)
+	page, err := parseMoviePaging(request)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movies, err := g.movies.FindAllByGenre(genre, userId, page)
+	ctx := routeContext(request, "genres.FindAllMoviesByGenre")
+	userId, err := extractUserId(ctx, request, g.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	match := request.URL.Query().Get("match")
+	if match == "" {
+		match = services.AllGenres
+	}
+	movies, err := g.movies.FindAllByGenres(ctx, strings.Split(genre, ","), match, userId, page)
 	serializeJson(writer, movies, err)
 }
 
-func (g *genreRoutes) FindOneGenreByName(name string, writer http.ResponseWriter) {
-	genre, err := g.genres.FindOneByName(name)
+func (g *genreRoutes) FindGenreStatsByName(name string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "genres.FindGenreStatsByName")
+	stats, err := g.genres.FindStatsByName(ctx, name)
+	serializeJson(writer, stats, err)
+}
+
+func (g *genreRoutes) FindOneGenreByName(name string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "genres.FindOneGenreByName")
+	genre, err := g.genres.FindOneByName(ctx, name)
 	serializeJson(writer, genre, err)
 }
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+type graphRoutes struct {
+	graph services.GraphService
+}
+
+func NewGraphRoutes(graph services.GraphService) Routable {
+	return &graphRoutes{graph: graph}
+}
+
+// graphCollections maps the collections of the API to the labels of their
+// nodes
+var graphCollections = map[string]string{
+	"movies": "Movie",
+	"people": "Person",
+	"genres": "Genre",
+}
+
+func (g *graphRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/graph/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			segments := strings.SplitN(strings.TrimPrefix(request.URL.Path, "/api/graph/"), "/", 2)
+			label, found := graphCollections[segments[0]]
+			if !found || len(segments) < 2 || segments[1] == "" {
+				writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+			g.FindNeighbourhood(label, segments[1], request, writer)
+		})
+}
+
+// FindNeighbourhood returns the nodes and edges around a movie, person or
+// genre, up to `hops` relationships away, restricted to the `labels` and
+// relationship `types` given as comma-separated lists, and capped to `limit`
+// nodes
+func (g *graphRoutes) FindNeighbourhood(label, id string, request *http.Request, writer http.ResponseWriter) {
+	options := services.NeighbourhoodOptions{Labels: listParameter(request, "labels")}
+	for _, relationshipType := range listParameter(request, "types") {
+		options.RelationshipTypes = append(options.RelationshipTypes, strings.ToUpper(relationshipType))
+	}
+	var err error
+	if options.Hops, err = intParameter(request, "hops"); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	if options.MaxNodes, err = intParameter(request, "limit"); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "graph.FindNeighbourhood")
+	graph, err := g.graph.FindNeighbourhood(ctx, label, id, options)
+	serializeJson(writer, graph, err)
+}
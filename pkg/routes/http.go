@@ -1,8 +1,18 @@
 package routes
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
 )
 
 type withStatusCode interface {
@@ -33,7 +43,79 @@ func serializeError(writer http.ResponseWriter, err error) {
 func writeStatusCode(writer http.ResponseWriter, err error) {
 	if errWithCode, ok := err.(withStatusCode); ok {
 		writer.WriteHeader(errWithCode.StatusCode())
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		writer.WriteHeader(504)
 	} else {
 		writer.WriteHeader(500)
 	}
 }
+
+// WithRequestContext bounds every request handled by handler with the given
+// timeout and tags its context with a request ID, reused from the X-Request-Id
+// header when the client provides one.
+func WithRequestContext(handler http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestId := request.Header.Get("X-Request-Id")
+		if requestId == "" {
+			requestId = newRequestId()
+		}
+		writer.Header().Set("X-Request-Id", requestId)
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		defer cancel()
+		ctx = services.WithRequestId(ctx, requestId)
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// routeContext returns the request context, tagged with the name of the route
+// serving it
+func routeContext(request *http.Request, routeName string) context.Context {
+	return services.WithRouteName(request.Context(), routeName)
+}
+
+// readInput decodes the JSON body of request into input, reporting malformed
+// bodies as 400 errors
+func readInput(request *http.Request, input interface{}) error {
+	if err := ioutils.ReadJsonInto(request.Body, input); err != nil {
+		return services.NewDomainError(400, "Invalid JSON body", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return nil
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// intParameter returns the integer query parameter of request, zero when
+// absent, reporting malformed values as 400 errors
+func intParameter(request *http.Request, name string) (int, error) {
+	rawValue := request.URL.Query().Get(name)
+	if rawValue == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(rawValue)
+	if err != nil {
+		return 0, services.NewDomainError(400, "Expected an integer", map[string]interface{}{
+			name: rawValue,
+		})
+	}
+	return value, nil
+}
+
+// listParameter splits the comma-separated query parameter of request,
+// ignoring blank entries
+func listParameter(request *http.Request, name string) []string {
+	var values []string
+	for _, value := range strings.Split(request.URL.Query().Get(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+// routeWatchlists dispatches /api/account/lists requests, path being the rest
+// of the URL path after lists/
+func (a *accountRoutes) routeWatchlists(path string, request *http.Request, writer http.ResponseWriter) {
+	segments := strings.Split(path, "/")
+	switch {
+	case path == "" && request.Method == "GET":
+		a.FindAllWatchlists(request, writer)
+	case path == "" && request.Method == "POST":
+		a.SaveWatchlist(request, writer)
+	case len(segments) == 1 && request.Method == "GET":
+		a.FindOneWatchlist(segments[0], request, writer)
+	case len(segments) == 1 && request.Method == "PUT":
+		a.UpdateWatchlist(segments[0], request, writer)
+	case len(segments) == 1 && request.Method == "DELETE":
+		a.DeleteWatchlist(segments[0], request, writer)
+	case len(segments) == 3 && segments[1] == "movies" && request.Method == "POST":
+		a.AddWatchlistMovie(segments[0], segments[2], request, writer)
+	case len(segments) == 3 && segments[1] == "movies" && request.Method == "DELETE":
+		a.RemoveWatchlistMovie(segments[0], segments[2], request, writer)
+	default:
+		writer.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (a *accountRoutes) FindAllWatchlists(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.FindAllWatchlists")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	page := paging.ParsePaging(request, paging.MovieSortableAttributes())
+	lists, err := a.watchlists.FindAllByUserId(ctx, userId, page)
+	serializeJson(writer, lists, err)
+}
+
+func (a *accountRoutes) FindOneWatchlist(listId string, request *http.Request, writer http.ResponseWriter) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.FindOneWatchlist")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	list, err := a.watchlists.FindOneById(ctx, listId, userId, page)
+	serializeJson(writer, list, err)
+}
+
+func (a *accountRoutes) SaveWatchlist(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.SaveWatchlist")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.WatchlistInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	list, err := a.watchlists.Save(ctx, userId, input)
+	serializeJson(writer, list, err)
+}
+
+func (a *accountRoutes) UpdateWatchlist(listId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.UpdateWatchlist")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var input services.WatchlistInput
+	if err := readInput(request, &input); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	list, err := a.watchlists.Update(ctx, userId, listId, input)
+	serializeJson(writer, list, err)
+}
+
+func (a *accountRoutes) DeleteWatchlist(listId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "account.DeleteWatchlist")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	list, err := a.watchlists.Delete(ctx, userId, listId)
+	serializeJson(writer, list, err)
+}
+
+// AddWatchlistMovie adds the movie to the list, at the 1-based `position`
+// query parameter when given, at the end otherwise
+func (a *accountRoutes) AddWatchlistMovie(listId, movieId string, request *http.Request, writer http.ResponseWriter) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.AddWatchlistMovie")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var position *int64
+	if rawPosition := request.URL.Query().Get("position"); rawPosition != "" {
+		value, err := strconv.ParseInt(rawPosition, 10, 64)
+		if err != nil || value < 1 {
+			serializeError(writer, services.NewDomainError(400, "Expected a positive integer", map[string]interface{}{
+				"position": rawPosition,
+			}))
+			return
+		}
+		position = &value
+	}
+	list, err := a.watchlists.AddMovie(ctx, userId, listId, movieId, position, page)
+	serializeJson(writer, list, err)
+}
+
+func (a *accountRoutes) RemoveWatchlistMovie(listId, movieId string, request *http.Request, writer http.ResponseWriter) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "account.RemoveWatchlistMovie")
+	userId, err := requireUserId(ctx, request, a.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	list, err := a.watchlists.RemoveMovie(ctx, userId, listId, movieId, page)
+	serializeJson(writer, list, err)
+}
+
+type listRoutes struct {
+	watchlists services.WatchlistService
+	auth       services.AuthService
+}
+
+// NewListRoutes serves the lists shared by their owners: public lists are
+// listed, while unlisted ones are only found through their ID
+func NewListRoutes(watchlists services.WatchlistService, auth services.AuthService) Routable {
+	return &listRoutes{watchlists: watchlists, auth: auth}
+}
+
+func (l *listRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/lists/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			listId := strings.TrimPrefix(request.URL.Path, "/api/lists/")
+			if listId == "" {
+				l.FindAllPublicLists(request, writer)
+				return
+			}
+			l.FindOneList(listId, request, writer)
+		})
+}
+
+func (l *listRoutes) FindAllPublicLists(request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "lists.FindAllPublicLists")
+	page := paging.ParsePaging(request, paging.MovieSortableAttributes())
+	lists, err := l.watchlists.FindAllPublic(ctx, page)
+	serializeJson(writer, lists, err)
+}
+
+// FindOneList returns a shared list, flagging its movies with the favorites
+// of the viewer when authenticated
+func (l *listRoutes) FindOneList(listId string, request *http.Request, writer http.ResponseWriter) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "lists.FindOneList")
+	userId, err := extractUserId(ctx, request, l.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	list, err := l.watchlists.FindOneById(ctx, listId, userId, page)
+	serializeJson(writer, list, err)
+}
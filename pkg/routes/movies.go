@@ -11,15 +11,18 @@ import (
 type movieRoutes struct {
 	movies  services.MovieService
 	ratings services.RatingService
+	reviews services.ReviewService
 	auth    services.AuthService
 }
 
 func NewMovieRoutes(movies services.MovieService,
 	ratings services.RatingService,
+	reviews services.ReviewService,
 	auth services.AuthService) Routable {
 	return &movieRoutes{
 		movies:  movies,
 		ratings: ratings,
+		reviews: reviews,
 		auth:    auth,
 	}
 }
@@ -34,6 +37,15 @@ func (m *movieRoutes) Register(server *http.ServeMux) {
 			case strings.HasSuffix(path, "/similar"):
 				id := strings.TrimSuffix(path, "/similar")
 				m.FindAllMoviesBySimilarity(id, request, writer)
+			case strings.HasSuffix(path, "/reviews"):
+				id := strings.TrimSuffix(path, "/reviews")
+				m.FindAllReviewsByMovieId(id, request, writer)
+			case strings.Contains(path, "/reviews/") && strings.HasSuffix(path, "/helpful"):
+				reviewId := strings.TrimSuffix(path[strings.Index(path, "/reviews/")+len("/reviews/"):], "/helpful")
+				m.VoteReviewHelpful(reviewId, request, writer)
+			case strings.HasSuffix(path, "/ratings/aggregate"):
+				id := strings.TrimSuffix(path, "/ratings/aggregate")
+				m.FindRatingAggregateByMovieId(id, request, writer)
 			case strings.HasSuffix(path, "/ratings"):
 				id := strings.TrimSuffix(path, "/ratings")
 				m.FindAllRatingsByMovieId(id, request, writer)
@@ -46,45 +58,121 @@ func (m *movieRoutes) Register(server *http.ServeMux) {
 // tag::list[]
 func (m *movieRoutes) FindAllMovies(request *http.Request, writer http.ResponseWriter) {
 	// <1> Extract pagination values from request
-	page := paging.ParsePaging(request, paging.MovieSortableAttributes())
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "movies.FindAllMovies")
 
 	// <2> Extract User ID from request
-	userId, err := extractUserId(request, m.auth)
+	userId, err := extractUserId(ctx, request, m.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
 
 	// <3> Get the results
-	movies, err := m.movies.FindAll(userId, page)
+	movies, err := m.movies.FindAll(ctx, userId, page)
 	serializeJson(writer, movies, err)
 }
 
 // end::list[]
 
 func (m *movieRoutes) FindOneMovieById(id string, request *http.Request, writer http.ResponseWriter) {
-	userId, err := extractUserId(request, m.auth)
+	ctx := routeContext(request, "movies.FindOneMovieById")
+	userId, err := extractUserId(ctx, request, m.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movies, err := m.movies.FindOneById(id, userId)
+	movies, err := m.movies.FindOneById(ctx, id, userId)
 	serializeJson(writer, movies, err)
 }
 
 func (m *movieRoutes) FindAllMoviesBySimilarity(id string, request *http.Request, writer http.ResponseWriter) {
-	page := paging.ParsePaging(request, paging.MovieSortableAttributes())
-	userId, err := extractUserId(request, m.auth)
+	page, err := parseProjectedPaging(request, paging.SimilarMovieSortableAttributes())
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "movies.FindAllMoviesBySimilarity")
+	userId, err := extractUserId(ctx, request, m.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movies, err := m.movies.FindAllBySimilarity(id, userId, page)
+	movies, err := m.movies.FindAllBySimilarity(ctx, id, userId, page)
 	serializeJson(writer, movies, err)
 }
 
 func (m *movieRoutes) FindAllRatingsByMovieId(id string, request *http.Request, writer http.ResponseWriter) {
 	page := paging.ParsePaging(request, paging.RatingSortableAttributes())
-	movies, err := m.ratings.FindAllByMovieId(id, page)
+	ctx := routeContext(request, "movies.FindAllRatingsByMovieId")
+	movies, err := m.ratings.FindAllByMovieId(ctx, id, page)
 	serializeJson(writer, movies, err)
 }
+
+// FindRatingAggregateByMovieId summarizes the ratings of the movie: mean,
+// Bayesian mean, count, histogram and monthly trend
+func (m *movieRoutes) FindRatingAggregateByMovieId(id string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "movies.FindRatingAggregateByMovieId")
+	aggregate, err := m.ratings.FindAggregateByMovieId(ctx, id)
+	serializeJson(writer, aggregate, err)
+}
+
+func (m *movieRoutes) FindAllReviewsByMovieId(id string, request *http.Request, writer http.ResponseWriter) {
+	page := paging.ParsePaging(request, paging.ReviewSortableAttributes())
+	ctx := routeContext(request, "movies.FindAllReviewsByMovieId")
+	userId, err := extractUserId(ctx, request, m.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	reviews, err := m.reviews.FindAllByMovieId(ctx, id, userId, page)
+	serializeJson(writer, reviews, err)
+}
+
+// VoteReviewHelpful records (POST) or withdraws (DELETE) the helpful vote of
+// the current user for a review
+func (m *movieRoutes) VoteReviewHelpful(reviewId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "movies.VoteReviewHelpful")
+	userId, err := requireUserId(ctx, request, m.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var review *services.Review
+	switch request.Method {
+	case "POST":
+		review, err = m.reviews.SaveHelpfulVote(ctx, userId, reviewId)
+	case "DELETE":
+		review, err = m.reviews.DeleteHelpfulVote(ctx, userId, reviewId)
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	serializeJson(writer, review, err)
+}
+
+// parseMoviePaging extracts the paging and projection parameters of a movie
+// list request
+func parseMoviePaging(request *http.Request) (*paging.Paging, error) {
+	return parseProjectedPaging(request, paging.MovieSortableAttributes())
+}
+
+// parseProjectedPaging extracts the paging parameters of a request listing
+// movies, or items embedding movies, along with the movie projection
+func parseProjectedPaging(request *http.Request, sortableAttributes *paging.SortableAttributes) (*paging.Paging, error) {
+	page := paging.ParsePaging(request, sortableAttributes)
+	projection, err := paging.ParseProjection(request, paging.MovieProjectableAttributes())
+	if invalid, ok := err.(*paging.InvalidProjectionError); ok {
+		return nil, services.NewDomainError(400, invalid.Error(), map[string]interface{}{
+			invalid.Parameter: invalid.Value,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return page.WithProjection(projection), nil
+}
@@ -9,7 +9,15 @@ import (
 
 func MovieSortableAttributes() *SortableAttributes {
 	return newSortableAttributes([]string{
-		"title", "released", "imdbRating", "score",
+		"title", "released", "imdbRating", "score", "trending",
+	})
+}
+
+// SimilarMovieSortableAttributes sort similar movies, by similarity unless
+// told otherwise
+func SimilarMovieSortableAttributes() *SortableAttributes {
+	return newSortableAttributes([]string{
+		"score", "title", "released", "imdbRating", "trending",
 	})
 }
 
@@ -25,6 +33,26 @@ func RatingSortableAttributes() *SortableAttributes {
 	})
 }
 
+func ReviewSortableAttributes() *SortableAttributes {
+	return newSortableAttributes([]string{
+		"helpfulness", "recency",
+	})
+}
+
+// ActivitySortableAttributes sort activity feeds and follower lists, which
+// are always ordered by time
+func ActivitySortableAttributes() *SortableAttributes {
+	return newSortableAttributes([]string{
+		"timestamp",
+	})
+}
+
+func AuditSortableAttributes() *SortableAttributes {
+	return newSortableAttributes([]string{
+		"at",
+	})
+}
+
 type SortableAttributes struct {
 	defaultValue string
 	values       []string
@@ -42,11 +70,12 @@ func (sa *SortableAttributes) contains(s string) bool {
 }
 
 type Paging struct {
-	query string
-	sort  string
-	order string
-	skip  int
-	limit int
+	query      string
+	sort       string
+	order      string
+	skip       int
+	limit      int
+	projection *Projection
 }
 
 func (p Paging) Query() string {
@@ -69,6 +98,19 @@ func (p Paging) Limit() int {
 	return p.limit
 }
 
+// Projection returns the subset of the paged resources to return,
+// nil meaning the full resources
+func (p Paging) Projection() *Projection {
+	return p.projection
+}
+
+// WithProjection returns a copy of the paging settings restricted to the
+// given projection
+func (p Paging) WithProjection(projection *Projection) *Paging {
+	p.projection = projection
+	return &p
+}
+
 func ParsePaging(req *http.Request, sortableAttributes *SortableAttributes) *Paging {
 	query := req.URL.Query()
 	sortParameter := query.Get("sort")
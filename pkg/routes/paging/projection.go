@@ -0,0 +1,129 @@
+package paging
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+func MovieProjectableAttributes() *ProjectableAttributes {
+	return newProjectableAttributes(
+		[]string{
+			"tmdbId", "title", "poster", "imdbRating", "imdbVotes", "year",
+			"released", "plot", "runtime", "languages", "countries", "budget",
+			"revenue", "url", "trending",
+		},
+		[]string{"actors", "directors", "genres"},
+	)
+}
+
+// ProjectableAttributes lists the fields and embedded collections that can be
+// requested for a given resource type
+type ProjectableAttributes struct {
+	fields     []string
+	embeddable []string
+}
+
+func newProjectableAttributes(fields []string, embeddable []string) *ProjectableAttributes {
+	sort.Strings(fields)
+	sort.Strings(embeddable)
+	return &ProjectableAttributes{fields: fields, embeddable: embeddable}
+}
+
+// Projection describes the subset of a resource a client asked for.
+// A nil *Projection stands for the full resource.
+type Projection struct {
+	fields   []string
+	includes []string
+}
+
+// NewProjection returns a projection restricted to the given fields and
+// embedded collections, nil fields meaning all of them
+func NewProjection(fields []string, includes []string) *Projection {
+	if includes == nil {
+		includes = []string{}
+	}
+	return &Projection{fields: fields, includes: includes}
+}
+
+// Fields returns the requested fields, nil meaning all of them
+func (p *Projection) Fields() []string {
+	if p == nil {
+		return nil
+	}
+	return p.fields
+}
+
+// Includes returns whether the embedded collection called name was requested
+func (p *Projection) Includes(name string) bool {
+	if p == nil || p.includes == nil {
+		return true
+	}
+	for _, include := range p.includes {
+		if include == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidProjectionError reports a `fields` or `include` value that is not
+// supported by the resource type
+type InvalidProjectionError struct {
+	Parameter string
+	Value     string
+	Allowed   []string
+}
+
+func (e *InvalidProjectionError) Error() string {
+	return fmt.Sprintf("unsupported %s value %q, expected one of: %s",
+		e.Parameter, e.Value, strings.Join(e.Allowed, ", "))
+}
+
+// ParseProjection reads the comma-separated `fields` and `include` query
+// parameters.
+// Omitting both returns a nil projection, i.e. the full resource.
+// Selecting `fields` without `include` drops every embedded collection, as
+// does an empty `include=`, while `include` alone keeps every field.
+func ParseProjection(req *http.Request, attributes *ProjectableAttributes) (*Projection, error) {
+	query := req.URL.Query()
+	_, hasFields := query["fields"]
+	_, hasIncludes := query["include"]
+	if !hasFields && !hasIncludes {
+		return nil, nil
+	}
+	// embedded collections must be opted into once fields are selected
+	projection := &Projection{includes: []string{}}
+	if hasFields {
+		fields, err := parseList(query.Get("fields"), "fields", attributes.fields)
+		if err != nil {
+			return nil, err
+		}
+		projection.fields = fields
+	}
+	if hasIncludes {
+		includes, err := parseList(query.Get("include"), "include", attributes.embeddable)
+		if err != nil {
+			return nil, err
+		}
+		projection.includes = includes
+	}
+	return projection, nil
+}
+
+func parseList(rawValue string, parameter string, allowed []string) ([]string, error) {
+	values := []string{}
+	for _, value := range strings.Split(rawValue, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		i := sort.SearchStrings(allowed, value)
+		if i == len(allowed) || allowed[i] != value {
+			return nil, &InvalidProjectionError{Parameter: parameter, Value: value, Allowed: allowed}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
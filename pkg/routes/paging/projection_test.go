@@ -0,0 +1,73 @@
+package paging_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+)
+
+func TestParseProjection(outer *testing.T) {
+	parse := func(query string) (*paging.Projection, error) {
+		request := httptest.NewRequest("GET", "/api/movies/?"+query, nil)
+		return paging.ParseProjection(request, paging.MovieProjectableAttributes())
+	}
+
+	outer.Run("returns the full resource without parameters", func(t *testing.T) {
+		projection, err := parse("limit=6")
+		if err != nil || projection != nil {
+			t.Fatalf("expected nil projection, got %v (error %v)", projection, err)
+		}
+		if projection.Fields() != nil || !projection.Includes("actors") {
+			t.Fatalf("expected nil projection to select everything")
+		}
+	})
+
+	outer.Run("selects fields and drops embeddings by default", func(t *testing.T) {
+		projection, err := parse("fields=tmdbId,title,%20poster")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(projection.Fields(), []string{"tmdbId", "title", "poster"}) {
+			t.Fatalf("unexpected fields %v", projection.Fields())
+		}
+		for _, embedding := range []string{"actors", "directors", "genres"} {
+			if projection.Includes(embedding) {
+				t.Fatalf("expected %s to be left out", embedding)
+			}
+		}
+		projection, err = parse("fields=tmdbId,title&include=genres")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !projection.Includes("genres") || projection.Includes("actors") {
+			t.Fatalf("expected only genres to be included, got %v", projection)
+		}
+	})
+
+	outer.Run("restricts embeddings", func(t *testing.T) {
+		projection, err := parse("include=genres")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if projection.Fields() != nil || !projection.Includes("genres") || projection.Includes("actors") {
+			t.Fatalf("unexpected projection %v", projection)
+		}
+		projection, err = parse("include=")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if projection.Includes("genres") {
+			t.Fatalf("expected empty include to drop all embeddings")
+		}
+	})
+
+	outer.Run("rejects unknown values", func(t *testing.T) {
+		for _, query := range []string{"fields=title,password", "include=ratings", "fields=actors"} {
+			if _, err := parse(query); err == nil {
+				t.Fatalf("expected %q to be rejected", query)
+			}
+		}
+	})
+}
@@ -30,56 +30,111 @@ func (p *peopleRoutes) Register(server *http.ServeMux) {
 			switch {
 			case path == "":
 				p.FindAllPeople(request, writer)
+			case strings.Contains(path, "/path/"):
+				ids := strings.SplitN(path, "/path/", 2)
+				p.FindPathBetweenPeople(ids[0], ids[1], request, writer)
 			case strings.HasSuffix(path, "/similar"):
 				id := strings.TrimSuffix(path, "/similar")
 				p.FindAllPeopleBySimilarity(id, request, writer)
 			case strings.HasSuffix(path, "/acted"):
 				id := strings.TrimSuffix(path, "/acted")
 				p.FindAllActedInMovies(id, request, writer)
+			case strings.HasSuffix(path, "/filmography"):
+				id := strings.TrimSuffix(path, "/filmography")
+				p.FindFilmographyByPersonId(id, request, writer)
 			case strings.HasSuffix(path, "/directed"):
 				id := strings.TrimSuffix(path, "/directed")
 				p.FindAllDirectedMovies(id, request, writer)
 			default:
-				p.FindOnePersonById(path, writer)
+				p.FindOnePersonById(path, request, writer)
 			}
 		})
 }
 
 func (p *peopleRoutes) FindAllPeople(request *http.Request, writer http.ResponseWriter) {
 	page := paging.ParsePaging(request, paging.PersonSortableAttributes())
-	people, err := p.people.FindAll(page)
+	ctx := routeContext(request, "people.FindAllPeople")
+	people, err := p.people.FindAll(ctx, page)
 	serializeJson(writer, people, err)
 }
 
-func (p *peopleRoutes) FindOnePersonById(personId string, writer http.ResponseWriter) {
-	person, err := p.people.FindOneById(personId)
+func (p *peopleRoutes) FindOnePersonById(personId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "people.FindOnePersonById")
+	person, err := p.people.FindOneById(ctx, personId)
 	serializeJson(writer, person, err)
 }
 
 func (p *peopleRoutes) FindAllPeopleBySimilarity(id string, request *http.Request, writer http.ResponseWriter) {
 	page := paging.ParsePaging(request, paging.PersonSortableAttributes())
-	people, err := p.people.FindAllBySimilarity(id, page)
+	ctx := routeContext(request, "people.FindAllPeopleBySimilarity")
+	people, err := p.people.FindAllBySimilarity(ctx, id, page)
 	serializeJson(writer, people, err)
 }
 
 func (p *peopleRoutes) FindAllActedInMovies(id string, request *http.Request, writer http.ResponseWriter) {
-	page := paging.ParsePaging(request, paging.MovieSortableAttributes())
-	userId, err := extractUserId(request, p.auth)
+	page, err := parseMoviePaging(request)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movies, err := p.movies.FindAllByActorId(id, userId, page)
+	ctx := routeContext(request, "people.FindAllActedInMovies")
+	userId, err := extractUserId(ctx, request, p.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	movies, err := p.movies.FindAllByActorId(ctx, id, userId, page)
 	serializeJson(writer, movies, err)
 }
 
 func (p *peopleRoutes) FindAllDirectedMovies(id string, request *http.Request, writer http.ResponseWriter) {
-	page := paging.ParsePaging(request, paging.MovieSortableAttributes())
-	userId, err := extractUserId(request, p.auth)
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "people.FindAllDirectedMovies")
+	userId, err := extractUserId(ctx, request, p.auth)
 	if err != nil {
 		serializeError(writer, err)
 		return
 	}
-	movies, err := p.movies.FindAllByDirectorId(id, userId, page)
+	movies, err := p.movies.FindAllByDirectorId(ctx, id, userId, page)
 	serializeJson(writer, movies, err)
 }
+
+// FindFilmographyByPersonId lists the credits of the person, grouped by the
+// `groupBy` parameter (decade, type or role) when present, with career stats
+func (p *peopleRoutes) FindFilmographyByPersonId(id string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "people.FindFilmographyByPersonId")
+	userId, err := extractUserId(ctx, request, p.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	filmography, err := p.people.FindFilmographyById(ctx, id, request.URL.Query().Get("groupBy"), userId)
+	serializeJson(writer, filmography, err)
+}
+
+// FindPathBetweenPeople returns the shortest chain of credits joining two
+// people, restricted to the relationship `types` (e.g. ACTED_IN,DIRECTED),
+// with `maxDepth` degrees of separation at most and up to `alternatives`
+// other paths of the same length
+func (p *peopleRoutes) FindPathBetweenPeople(fromId, toId string, request *http.Request, writer http.ResponseWriter) {
+	options := services.PathOptions{}
+	for _, relationshipType := range listParameter(request, "types") {
+		options.RelationshipTypes = append(options.RelationshipTypes, strings.ToUpper(relationshipType))
+	}
+	var err error
+	if options.MaxDepth, err = intParameter(request, "maxDepth"); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	if options.Alternatives, err = intParameter(request, "alternatives"); err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "people.FindPathBetweenPeople")
+	path, err := p.people.FindPathBetween(ctx, fromId, toId, options)
+	serializeJson(writer, path, err)
+}
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+type searchRoutes struct {
+	search  services.SearchService
+	suggest services.SuggestService
+}
+
+func NewSearchRoutes(search services.SearchService, suggest services.SuggestService) Routable {
+	return &searchRoutes{search: search, suggest: suggest}
+}
+
+func (s *searchRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/search", s.Search)
+	server.HandleFunc("/api/suggest", s.Suggest)
+}
+
+// Search returns the movies and people matching the `q` parameter.
+// `fuzzy=true` tolerates typos and `prefix=true` matches partial words.
+func (s *searchRoutes) Search(writer http.ResponseWriter, request *http.Request) {
+	page, err := parseMoviePaging(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	options, err := parseSearchOptions(request)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "search.Search")
+	results, err := s.search.Search(ctx, page.Query(), options, page)
+	serializeJson(writer, results, err)
+}
+
+// Suggest returns up to `limit` typeahead suggestions for the `q` parameter
+func (s *searchRoutes) Suggest(writer http.ResponseWriter, request *http.Request) {
+	limit := services.DefaultSuggestionLimit
+	if rawLimit := request.URL.Query().Get("limit"); rawLimit != "" {
+		value, err := strconv.Atoi(rawLimit)
+		if err != nil {
+			serializeError(writer, services.NewDomainError(400, "Expected an integer", map[string]interface{}{
+				"limit": rawLimit,
+			}))
+			return
+		}
+		limit = value
+	}
+	ctx := routeContext(request, "search.Suggest")
+	suggestions, err := s.suggest.Suggest(ctx, request.URL.Query().Get("q"), limit)
+	serializeJson(writer, suggestions, err)
+}
+
+func parseSearchOptions(request *http.Request) (services.SearchOptions, error) {
+	var options services.SearchOptions
+	query := request.URL.Query()
+	for name, target := range map[string]*bool{"fuzzy": &options.Fuzzy, "prefix": &options.Prefix} {
+		rawValue := query.Get(name)
+		if rawValue == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(rawValue)
+		if err != nil {
+			return options, services.NewDomainError(400, "Expected a boolean", map[string]interface{}{
+				name: rawValue,
+			})
+		}
+		*target = value
+	}
+	return options, nil
+}
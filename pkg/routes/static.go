@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hashedAsset matches the content-hashed file names generated by the frontend
+// build, e.g. js/app.c601a03b.js
+var hashedAsset = regexp.MustCompile(`^(js|css|img)/.+\.[0-9a-f]{8}\.[a-z]+(\.map)?$`)
+
+// assetDirectories hold the files built for the frontend, never routes
+var assetDirectories = []string{"js/", "css/", "img/"}
+
+type staticRoutes struct {
+	assets     fs.FS
+	fileServer http.Handler
+}
+
+// NewStaticRoutes serves the single-page application contained in assets.
+// Unknown extension-less paths outside /api/ fall back to index.html so that
+// deep links such as /movies/769 are resolved by the frontend router, while
+// missing files are not found.
+// Go sources, such as the embed.go file next to the assets, are never served.
+func NewStaticRoutes(assets fs.FS) Routable {
+	return &staticRoutes{
+		assets:     assets,
+		fileServer: http.FileServer(http.FS(assets)),
+	}
+}
+
+func (s *staticRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			if strings.HasPrefix(request.URL.Path, "/api/") {
+				http.NotFound(writer, request)
+				return
+			}
+			name := strings.TrimPrefix(path.Clean(request.URL.Path), "/")
+			switch {
+			case name == "" || name == "index.html":
+				s.ServeIndex(writer, request)
+			case !s.exists(name) && isRoute(name):
+				s.ServeIndex(writer, request)
+			case !s.exists(name):
+				http.NotFound(writer, request)
+			default:
+				s.ServeAsset(name, writer, request)
+			}
+		})
+}
+
+func (s *staticRoutes) ServeAsset(name string, writer http.ResponseWriter, request *http.Request) {
+	if hashedAsset.MatchString(name) {
+		writer.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	s.fileServer.ServeHTTP(writer, request)
+}
+
+func (s *staticRoutes) ServeIndex(writer http.ResponseWriter, request *http.Request) {
+	index, err := fs.ReadFile(s.assets, "index.html")
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(200)
+	if request.Method != http.MethodHead {
+		_, _ = writer.Write(index)
+	}
+}
+
+func (s *staticRoutes) exists(name string) bool {
+	if path.Ext(name) == ".go" {
+		return false
+	}
+	info, err := fs.Stat(s.assets, name)
+	return err == nil && !info.IsDir()
+}
+
+// isRoute returns whether the path may be resolved by the frontend router
+func isRoute(name string) bool {
+	for _, directory := range assetDirectories {
+		if strings.HasPrefix(name, directory) {
+			return false
+		}
+	}
+	return path.Ext(name) == ""
+}
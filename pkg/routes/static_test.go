@@ -0,0 +1,69 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes"
+)
+
+func TestStaticRoutes(outer *testing.T) {
+	server := http.NewServeMux()
+	routes.NewStaticRoutes(fstest.MapFS{
+		"index.html":                 {Data: []byte("<html>neoflix</html>")},
+		"js/app.c601a03b.js":         {Data: []byte("console.log('app')")},
+		"img/poster-placeholder.png": {Data: []byte("png")},
+		"embed.go":                   {Data: []byte("package public")},
+	}).Register(server)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	outer.Run("serves index.html for deep links", func(t *testing.T) {
+		for _, path := range []string{"/", "/movies/769", "/people/1158"} {
+			response := serve(path)
+			if response.Code != 200 || response.Body.String() != "<html>neoflix</html>" {
+				t.Fatalf("expected index.html for %s, got %d %q", path, response.Code, response.Body.String())
+			}
+			if cache := response.Header().Get("Cache-Control"); cache != "no-cache" {
+				t.Fatalf("expected index.html not to be cached, got %q", cache)
+			}
+		}
+	})
+
+	outer.Run("serves hashed assets as immutable", func(t *testing.T) {
+		response := serve("/js/app.c601a03b.js")
+		if response.Code != 200 || response.Body.String() != "console.log('app')" {
+			t.Fatalf("unexpected response %d %q", response.Code, response.Body.String())
+		}
+		if cache := response.Header().Get("Cache-Control"); cache != "public, max-age=31536000, immutable" {
+			t.Fatalf("unexpected Cache-Control %q", cache)
+		}
+	})
+
+	outer.Run("does not cache unhashed assets forever", func(t *testing.T) {
+		response := serve("/img/poster-placeholder.png")
+		if response.Code != 200 || response.Header().Get("Cache-Control") != "" {
+			t.Fatalf("unexpected response %d, Cache-Control %q", response.Code, response.Header().Get("Cache-Control"))
+		}
+	})
+
+	outer.Run("does not fall back for missing files", func(t *testing.T) {
+		for _, path := range []string{"/js/missing.abc12345.js", "/css/app", "/img/missing.png", "/robots.txt", "/embed.go"} {
+			if response := serve(path); response.Code != 404 {
+				t.Fatalf("expected 404 for %s, got %d", path, response.Code)
+			}
+		}
+	})
+
+	outer.Run("does not fall back for unknown API paths", func(t *testing.T) {
+		if response := serve("/api/unknown"); response.Code != 404 {
+			t.Fatalf("expected 404, got %d", response.Code)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+type userRoutes struct {
+	social services.SocialService
+	auth   services.AuthService
+}
+
+func NewUserRoutes(social services.SocialService, auth services.AuthService) Routable {
+	return &userRoutes{social: social, auth: auth}
+}
+
+func (u *userRoutes) Register(server *http.ServeMux) {
+	server.HandleFunc("/api/users/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			segments := strings.Split(strings.TrimPrefix(request.URL.Path, "/api/users/"), "/")
+			if len(segments) != 2 || segments[0] == "" {
+				writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+			userId := segments[0]
+			switch {
+			case segments[1] == "follow" && (request.Method == "POST" || request.Method == "DELETE"):
+				u.Follow(userId, request, writer)
+			case segments[1] == "followers":
+				u.FindAllFollowers(userId, request, writer)
+			case segments[1] == "following":
+				u.FindAllFollowing(userId, request, writer)
+			case segments[1] == "activity":
+				u.FindAllActivity(userId, request, writer)
+			case segments[1] == "taste-match":
+				u.FindTasteMatch(userId, request, writer)
+			default:
+				writer.WriteHeader(http.StatusNotFound)
+			}
+		})
+}
+
+// Follow makes the current user follow (POST) or unfollow (DELETE) the user
+func (u *userRoutes) Follow(targetId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "users.Follow")
+	userId, err := requireUserId(ctx, request, u.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	var profile *services.UserProfile
+	if request.Method == "POST" {
+		profile, err = u.social.Follow(ctx, userId, targetId)
+	} else {
+		profile, err = u.social.Unfollow(ctx, userId, targetId)
+	}
+	serializeJson(writer, profile, err)
+}
+
+func (u *userRoutes) FindAllFollowers(userId string, request *http.Request, writer http.ResponseWriter) {
+	page := paging.ParsePaging(request, paging.ActivitySortableAttributes())
+	ctx := routeContext(request, "users.FindAllFollowers")
+	viewerId, err := extractUserId(ctx, request, u.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	profiles, err := u.social.FindAllFollowers(ctx, userId, viewerId, page)
+	serializeJson(writer, profiles, err)
+}
+
+func (u *userRoutes) FindAllFollowing(userId string, request *http.Request, writer http.ResponseWriter) {
+	page := paging.ParsePaging(request, paging.ActivitySortableAttributes())
+	ctx := routeContext(request, "users.FindAllFollowing")
+	viewerId, err := extractUserId(ctx, request, u.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	profiles, err := u.social.FindAllFollowing(ctx, userId, viewerId, page)
+	serializeJson(writer, profiles, err)
+}
+
+// FindAllActivity returns the ratings, favorites and reviews of the user, the
+// ratings being hidden from non-followers when the user chose so
+func (u *userRoutes) FindAllActivity(userId string, request *http.Request, writer http.ResponseWriter) {
+	page, err := parseProjectedPaging(request, paging.ActivitySortableAttributes())
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	ctx := routeContext(request, "users.FindAllActivity")
+	viewerId, err := extractUserId(ctx, request, u.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	activity, err := u.social.FindAllActivityByUserId(ctx, userId, viewerId, page)
+	serializeJson(writer, activity, err)
+}
+
+// FindTasteMatch compares the ratings of the current user with the user's
+func (u *userRoutes) FindTasteMatch(otherId string, request *http.Request, writer http.ResponseWriter) {
+	ctx := routeContext(request, "users.FindTasteMatch")
+	userId, err := requireUserId(ctx, request, u.auth)
+	if err != nil {
+		serializeError(writer, err)
+		return
+	}
+	match, err := u.social.FindTasteMatch(ctx, userId, otherId)
+	serializeJson(writer, match, err)
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// bayesianPriorWeight pulls the Bayesian mean of movies with few ratings
+	// towards the middle of the rating scale, as if each movie had
+	// bayesianPriorWeight extra ratings in the middle of the scale
+	bayesianPriorWeight = 10.0
+
+	monthLayout = "2006-01"
+)
+
+// RatingAggregate summarizes the ratings Neoflix users gave to a movie.
+// The histogram counts ratings rounded to the nearest step of the rating
+// scale, keyed by that step, e.g. from "1" to "5" by "0.5".
+type RatingAggregate struct {
+	Count        int64            `json:"count"`
+	Mean         *float64         `json:"mean,omitempty"`
+	BayesianMean float64          `json:"bayesianMean"`
+	Histogram    map[string]int64 `json:"histogram"`
+	Trend        []MonthlyRating  `json:"trend"`
+}
+
+// MonthlyRating summarizes the ratings given during a month, e.g. "2016-10"
+type MonthlyRating struct {
+	Month string  `json:"month"`
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean"`
+}
+
+// ratingChange is a rating as stored on a RATED relationship
+type ratingChange struct {
+	Rating    float64
+	Timestamp int64
+}
+
+// aggregateDelta is the change to apply to the stored aggregates of a movie
+type aggregateDelta struct {
+	count     int64
+	sum       float64
+	histogram []int64
+	months    map[string]monthDelta
+}
+
+type monthDelta struct {
+	count int64
+	sum   float64
+}
+
+// newAggregateDelta returns the change replacing the previous rating with the
+// next one, either of them being nil when the rating is created or deleted
+func newAggregateDelta(scale RatingScale, previous, next *ratingChange) aggregateDelta {
+	delta := aggregateDelta{histogram: make([]int64, scale.histogramBuckets()), months: map[string]monthDelta{}}
+	apply := func(change *ratingChange, sign int64) {
+		if change == nil {
+			return
+		}
+		delta.count += sign
+		delta.sum += float64(sign) * change.Rating
+		delta.histogram[scale.histogramBucket(change.Rating)] += sign
+		month := time.Unix(change.Timestamp, 0).UTC().Format(monthLayout)
+		monthly := delta.months[month]
+		monthly.count += sign
+		monthly.sum += float64(sign) * change.Rating
+		delta.months[month] = monthly
+	}
+	apply(previous, -1)
+	apply(next, 1)
+	return delta
+}
+
+// histogramBuckets returns the number of buckets of rating histograms, one
+// per step of the scale
+func (s RatingScale) histogramBuckets() int {
+	return int(math.Round((s.Max-s.Min)/s.Step)) + 1
+}
+
+// histogramBucket returns the index of the histogram bucket of rating,
+// rounded to the nearest step
+func (s RatingScale) histogramBucket(rating float64) int {
+	bucket := int(math.Round((rating - s.Min) / s.Step))
+	if bucket < 0 {
+		return 0
+	}
+	if buckets := s.histogramBuckets(); bucket >= buckets {
+		return buckets - 1
+	}
+	return bucket
+}
+
+// histogramLabel returns the rating counted by the histogram bucket
+func (s RatingScale) histogramLabel(bucket int) string {
+	return strconv.FormatFloat(s.normalize(s.Min+float64(bucket)*s.Step), 'f', -1, 64)
+}
+
+// priorMean returns the rating assumed for movies without ratings
+func (s RatingScale) priorMean() float64 {
+	return (s.Min + s.Max) / 2
+}
+
+// histogramParameters returns the parameters bucketing ratings in Cypher, see
+// histogramBucketExpression
+func (s RatingScale) histogramParameters() map[string]interface{} {
+	return map[string]interface{}{"min": s.Min, "step": s.Step, "buckets": s.histogramBuckets()}
+}
+
+// histogramBucketExpression is the Cypher equivalent of
+// RatingScale.histogramBucket for `rating`
+const histogramBucketExpression = `
+	CASE WHEN toInteger(round((rating - $min) / $step)) < 0 THEN 0
+		WHEN toInteger(round((rating - $min) / $step)) >= $buckets THEN $buckets - 1
+		ELSE toInteger(round((rating - $min) / $step)) END`
+
+// ensureAggregates computes the stored aggregates of the movie from its
+// ratings the first time they are needed, i.e. for movies rated before the
+// aggregates were introduced, or again when the number of steps of the rating
+// scale changed.
+// The movie is write-locked first so that concurrent ratings of the same movie
+// cannot both create them.
+func ensureAggregates(tx neo4j.Transaction, movieId string, scale RatingScale) error {
+	params := scale.histogramParameters()
+	params["movieId"] = movieId
+	return run(tx, `
+		MATCH (m:Movie {tmdbId: $movieId})
+		SET m._lock = true
+		REMOVE m._lock
+		WITH m
+		CALL {
+			WITH m
+			MATCH (m)-[:HAS_RATING_AGGREGATE]->(stale:RatingAggregate)
+			WHERE size(stale.histogram) <> $buckets
+			OPTIONAL MATCH (m)-[:HAS_MONTHLY_RATINGS]->(month:RatingMonth)
+			DETACH DELETE stale, month
+		}
+		WITH m
+		WHERE NOT (m)-[:HAS_RATING_AGGREGATE]->(:RatingAggregate)
+		CREATE (m)-[:HAS_RATING_AGGREGATE]->(a:RatingAggregate {
+			count: 0, sum: 0.0, histogram: [i IN range(1, $buckets) | 0]
+		})
+		WITH m, a
+		CALL {
+			WITH m, a
+			MATCH (m)<-[r:RATED]-(:User)
+			WITH a, r.rating AS rating
+			WITH a, rating, `+histogramBucketExpression+` AS bucket
+			WITH a, count(*) AS count, sum(rating) AS sum, collect(bucket) AS buckets
+			SET a.count = count, a.sum = toFloat(sum),
+				a.histogram = [i IN range(0, $buckets - 1) | size([b IN buckets WHERE b = i])]
+		}
+		CALL {
+			WITH m
+			MATCH (m)<-[r:RATED]-(:User)
+			WHERE r.timestamp IS NOT NULL
+			WITH m, substring(toString(datetime({epochSeconds: r.timestamp})), 0, 7) AS month,
+				count(*) AS count, sum(r.rating) AS sum
+			CREATE (m)-[:HAS_MONTHLY_RATINGS]->(:RatingMonth {month: month, count: count, sum: toFloat(sum)})
+		}`,
+		params)
+}
+
+// applyAggregateDelta updates the stored aggregates of the movie, which must
+// already exist
+func applyAggregateDelta(tx neo4j.Transaction, movieId string, delta aggregateDelta) error {
+	months := make([]interface{}, 0, len(delta.months))
+	for month, monthly := range delta.months {
+		months = append(months, map[string]interface{}{"month": month, "count": monthly.count, "sum": monthly.sum})
+	}
+	histogram := make([]interface{}, len(delta.histogram))
+	for i, count := range delta.histogram {
+		histogram[i] = count
+	}
+	return run(tx, `
+		MATCH (m:Movie {tmdbId: $movieId})-[:HAS_RATING_AGGREGATE]->(a:RatingAggregate)
+		SET a.count = a.count + $count, a.sum = a.sum + $sum,
+			a.histogram = [i IN range(0, size($histogram) - 1) | a.histogram[i] + $histogram[i]]
+		WITH m
+		UNWIND $months AS delta
+		MERGE (m)-[:HAS_MONTHLY_RATINGS]->(month:RatingMonth {month: delta.month})
+		ON CREATE SET month.count = 0, month.sum = 0.0
+		SET month.count = month.count + delta.count, month.sum = month.sum + delta.sum
+		WITH month
+		WHERE month.count <= 0
+		DETACH DELETE month`,
+		map[string]interface{}{
+			"movieId":   movieId,
+			"count":     delta.count,
+			"sum":       delta.sum,
+			"histogram": histogram,
+			"months":    months,
+		})
+}
+
+// findAggregates reads the stored aggregates of the movie, nil when they were
+// not computed yet or were computed for a scale with another number of steps
+func findAggregates(ctx context.Context, tx neo4j.Transaction, movieId string, scale RatingScale) (*RatingAggregate, error) {
+	result, err := tx.Run(`
+		MATCH (m:Movie {tmdbId: $movieId})-[:HAS_RATING_AGGREGATE]->(a:RatingAggregate)
+		RETURN a.count AS count, a.sum AS sum, a.histogram AS histogram,
+			[ (m)-[:HAS_MONTHLY_RATINGS]->(month:RatingMonth) |
+				month { .month, .count, mean: month.sum / month.count } ] AS trend`,
+		map[string]interface{}{"movieId": movieId})
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	var row struct {
+		Count     int64           `neo4j:"count"`
+		Sum       float64         `neo4j:"sum"`
+		Histogram []int64         `neo4j:"histogram"`
+		Trend     []MonthlyRating `neo4j:"trend"`
+	}
+	if err := mapping.DecodeRecord(records[0], &row); err != nil {
+		return nil, err
+	}
+	if len(row.Histogram) != scale.histogramBuckets() {
+		return nil, nil
+	}
+	return newRatingAggregate(scale, row.Count, row.Sum, row.Histogram, row.Trend), nil
+}
+
+func newRatingAggregate(scale RatingScale, count int64, sum float64, histogram []int64, trend []MonthlyRating) *RatingAggregate {
+	buckets := scale.histogramBuckets()
+	aggregate := &RatingAggregate{
+		Count:        count,
+		BayesianMean: (bayesianPriorWeight*scale.priorMean() + sum) / (bayesianPriorWeight + float64(count)),
+		Histogram:    make(map[string]int64, buckets),
+		Trend:        trend,
+	}
+	if count > 0 {
+		mean := sum / float64(count)
+		aggregate.Mean = &mean
+	}
+	for i := 0; i < buckets; i++ {
+		var bucketCount int64
+		if i < len(histogram) {
+			bucketCount = histogram[i]
+		}
+		aggregate.Histogram[scale.histogramLabel(i)] = bucketCount
+	}
+	sort.Slice(aggregate.Trend, func(i, j int) bool {
+		return aggregate.Trend[i].Month < aggregate.Trend[j].Month
+	})
+	return aggregate
+}
+
+// FindAggregateByMovieId returns the rating aggregates of the movie, computing
+// them first for movies rated before aggregates were maintained
+func (rs *neo4jRatingService) FindAggregateByMovieId(ctx context.Context, movieId string) (_ *RatingAggregate, err error) {
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		return findAggregates(ctx, tx, movieId, rs.scale)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if aggregate := result.(*RatingAggregate); aggregate != nil {
+		return aggregate, nil
+	}
+
+	result, err = writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		if err := ensureAggregates(tx, movieId, rs.scale); err != nil {
+			return nil, err
+		}
+		return findAggregates(ctx, tx, movieId, rs.scale)
+	})
+	if err != nil {
+		return nil, err
+	}
+	aggregate := result.(*RatingAggregate)
+	if aggregate == nil {
+		return nil, NewDomainError(404, "Movie not found", map[string]interface{}{"movieId": movieId})
+	}
+	return aggregate, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewAggregateDelta(outer *testing.T) {
+	stars := RatingScale{Min: 1, Max: 5, Step: 1}
+	october := time.Date(2016, time.October, 12, 8, 0, 0, 0, time.UTC).Unix()
+	november := time.Date(2016, time.November, 3, 8, 0, 0, 0, time.UTC).Unix()
+
+	outer.Run("new rating", func(t *testing.T) {
+		delta := newAggregateDelta(stars, nil, &ratingChange{Rating: 4, Timestamp: october})
+		assertDelta(t, delta, 1, 4, []int64{0, 0, 0, 1, 0})
+		if monthly := delta.months["2016-10"]; monthly.count != 1 || monthly.sum != 4 {
+			t.Fatalf("expected one rating of 4 in 2016-10, got %+v", monthly)
+		}
+	})
+
+	outer.Run("updated rating", func(t *testing.T) {
+		delta := newAggregateDelta(stars, &ratingChange{Rating: 2, Timestamp: october}, &ratingChange{Rating: 4.5, Timestamp: november})
+		assertDelta(t, delta, 0, 2.5, []int64{0, -1, 0, 0, 1})
+		if monthly := delta.months["2016-10"]; monthly.count != -1 || monthly.sum != -2 {
+			t.Fatalf("expected the 2016-10 rating to be withdrawn, got %+v", monthly)
+		}
+		if monthly := delta.months["2016-11"]; monthly.count != 1 || monthly.sum != 4.5 {
+			t.Fatalf("expected one rating of 4.5 in 2016-11, got %+v", monthly)
+		}
+	})
+
+	outer.Run("deleted rating", func(t *testing.T) {
+		delta := newAggregateDelta(stars, &ratingChange{Rating: 0.5, Timestamp: october}, nil)
+		assertDelta(t, delta, -1, -0.5, []int64{-1, 0, 0, 0, 0})
+	})
+
+	outer.Run("one bucket per step of the scale", func(t *testing.T) {
+		delta := newAggregateDelta(DefaultRatingScale(), nil, &ratingChange{Rating: 4.5, Timestamp: october})
+		assertDelta(t, delta, 1, 4.5, []int64{0, 0, 0, 0, 0, 0, 0, 1, 0})
+		if len(delta.histogram) != 9 {
+			t.Fatalf("expected nine buckets, got %v", delta.histogram)
+		}
+	})
+}
+
+func TestNewRatingAggregate(outer *testing.T) {
+	stars := RatingScale{Min: 1, Max: 5, Step: 1}
+
+	outer.Run("no ratings", func(t *testing.T) {
+		aggregate := newRatingAggregate(stars, 0, 0, nil, nil)
+		if aggregate.Mean != nil {
+			t.Fatalf("expected no mean, got %v", *aggregate.Mean)
+		}
+		if aggregate.BayesianMean != 3 {
+			t.Fatalf("expected the prior mean, got %v", aggregate.BayesianMean)
+		}
+		if len(aggregate.Histogram) != 5 || aggregate.Histogram["5"] != 0 {
+			t.Fatalf("expected five empty buckets, got %v", aggregate.Histogram)
+		}
+	})
+
+	outer.Run("few high ratings are pulled towards the prior", func(t *testing.T) {
+		aggregate := newRatingAggregate(stars, 2, 10, []int64{0, 0, 0, 0, 2}, []MonthlyRating{
+			{Month: "2016-11", Count: 1, Mean: 5},
+			{Month: "2016-10", Count: 1, Mean: 5},
+		})
+		if *aggregate.Mean != 5 {
+			t.Fatalf("expected a mean of 5, got %v", *aggregate.Mean)
+		}
+		if expected := 40.0 / 12; math.Abs(aggregate.BayesianMean-expected) > 1e-9 {
+			t.Fatalf("expected a Bayesian mean of %v, got %v", expected, aggregate.BayesianMean)
+		}
+		if aggregate.Histogram["5"] != 2 {
+			t.Fatalf("expected two 5-star ratings, got %v", aggregate.Histogram)
+		}
+		if aggregate.Trend[0].Month != "2016-10" {
+			t.Fatalf("expected the trend to be ordered by month, got %v", aggregate.Trend)
+		}
+	})
+
+	outer.Run("buckets and prior follow the scale", func(t *testing.T) {
+		aggregate := newRatingAggregate(RatingScale{Min: 0, Max: 10, Step: 2.5}, 1, 7.5, []int64{0, 0, 0, 1, 0}, nil)
+		for _, label := range []string{"0", "2.5", "5", "7.5", "10"} {
+			if _, ok := aggregate.Histogram[label]; !ok {
+				t.Fatalf("expected a %q bucket, got %v", label, aggregate.Histogram)
+			}
+		}
+		if aggregate.Histogram["7.5"] != 1 {
+			t.Fatalf("expected one rating of 7.5, got %v", aggregate.Histogram)
+		}
+		if expected := (10*5 + 7.5) / 11; math.Abs(aggregate.BayesianMean-expected) > 1e-9 {
+			t.Fatalf("expected a Bayesian mean of %v, got %v", expected, aggregate.BayesianMean)
+		}
+	})
+}
+
+func assertDelta(t *testing.T, delta aggregateDelta, count int64, sum float64, histogram []int64) {
+	t.Helper()
+	if delta.count != count || delta.sum != sum {
+		t.Fatalf("expected count %d and sum %v, got %d and %v", count, sum, delta.count, delta.sum)
+	}
+	for i, bucket := range histogram {
+		if delta.histogram[i] != bucket {
+			t.Fatalf("expected histogram %v, got %v", histogram, delta.histogram)
+		}
+	}
+}
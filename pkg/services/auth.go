@@ -1,23 +1,75 @@
 package services
 
 import (
-	"fmt"
+	"context"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
 	"github.com/neo4j-graphacademy/neoflix/pkg/services/jwtutils"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type User map[string]interface{}
+// AdminRole grants access to the catalogue management API
+const AdminRole = "admin"
+
+type User struct {
+	UserId   string   `json:"userId"`
+	Email    string   `json:"email"`
+	Name     string   `json:"name"`
+	Password string   `json:"-" neo4j:"password"`
+	Roles    []string `json:"roles,omitempty"`
+	Token    string   `json:"token,omitempty"`
+	// RefreshToken is exchanged for a new access token once Token expires
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// Principal is the authenticated user behind a bearer token
+type Principal struct {
+	UserId string
+	Roles  []string
+}
+
+// HasRole returns whether the principal was granted role
+func (p *Principal) HasRole(role string) bool {
+	for _, candidate := range p.Roles {
+		if candidate == role {
+			return true
+		}
+	}
+	return false
+}
+
+// UserFromNode converts a User node, password hash included
+func UserFromNode(node neo4j.Node) (User, error) {
+	var user User
+	err := mapping.Decode(node, &user)
+	return user, err
+}
 
 type AuthService interface {
-	Save(email, plainPassword, name string) (User, error)
+	Save(ctx context.Context, email, plainPassword, name string) (*User, error)
+
+	FindOneByEmailAndPassword(ctx context.Context, email string, password string) (*User, error)
 
-	FindOneByEmailAndPassword(email string, password string) (User, error)
+	// Refresh exchanges a refresh token for new access and refresh tokens
+	Refresh(ctx context.Context, refreshToken string) (*User, error)
 
-	ExtractUserId(bearer string) (string, error)
+	// Logout revokes the bearer token along with the refresh tokens issued
+	// since the login
+	Logout(ctx context.Context, bearer string) error
+
+	// ExtractUserId returns the user ID of the bearer token, empty for
+	// anonymous requests.
+	// Malformed, expired and revoked tokens are rejected with a 401 error.
+	ExtractUserId(ctx context.Context, bearer string) (string, error)
+
+	// ExtractPrincipal returns the user ID and roles of the bearer token,
+	// nil for anonymous requests
+	ExtractPrincipal(ctx context.Context, bearer string) (*Principal, error)
 }
 
 type neo4jAuthService struct {
@@ -25,81 +77,272 @@ type neo4jAuthService struct {
 	driver     neo4j.Driver
 	jwtSecret  string
 	saltRounds int
+	tokens     TokenSettings
+	denylist   *tokenDenylist
 }
 
-func NewAuthService(loader *fixtures.FixtureLoader, driver neo4j.Driver, jwtSecret string, saltRounds int) AuthService {
+func NewAuthService(loader *fixtures.FixtureLoader, driver neo4j.Driver, jwtSecret string, saltRounds int, tokens TokenSettings) AuthService {
+	denylist := newTokenDenylist(func(ctx context.Context) ([]revokedToken, error) {
+		return loadRevokedTokens(ctx, driver)
+	}, revokedTokensTtl)
+	denylist.warm()
 	return &neo4jAuthService{
 		loader:     loader,
 		driver:     driver,
 		jwtSecret:  jwtSecret,
 		saltRounds: saltRounds,
+		tokens:     tokens,
+		denylist:   denylist,
 	}
 }
 
-// Save should create a new User node in the database with the email and name
-// provided, along with an encrypted version of the password and a `userId` property
+// Save creates a new User node in the database with the email and name
+// provided, along with a bcrypt hash of the password and a `userId` property
 // generated by the server.
+// Emails are normalised, so that addresses differing only by case or
+// surrounding whitespace belong to the same account.
 //
-// The properties also be used to generate a JWT `token` which should be included
-// with the returned user.
+// The properties are also used to generate a JWT `token` which is included
+// with the returned user, along with the first `refreshToken` of a new family.
 // tag::register[]
-func (as *neo4jAuthService) Save(email, plainPassword, name string) (_ User, err error) {
-	// TODO: Handle Unique constraints in the database
-	if email != "graphacademy@neo4j.com" {
-		return nil, fmt.Errorf("An account already exists with this email address")
+func (as *neo4jAuthService) Save(ctx context.Context, email, plainPassword, name string) (_ *User, err error) {
+	email = normalizeEmail(email)
+	if err := validateRegistration(email, plainPassword, name); err != nil {
+		return nil, err
 	}
-
-	user, err := as.loader.ReadObject("fixtures/user.json")
+	encryptedPassword, err := encryptPassword(plainPassword, as.saltRounds)
 	if err != nil {
 		return nil, err
 	}
 
-	subject := user["userId"].(string)
-	token, err := jwtutils.Sign(subject, userToClaims(user), as.jwtSecret)
+	session := as.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			CREATE (u:User {
+				userId: randomUUID(),
+				email: $email,
+				password: $encrypted,
+				name: $name
+			})
+			RETURN u`,
+			map[string]interface{}{
+				"email":     email,
+				"encrypted": encryptedPassword,
+				"name":      name,
+			})
+		if err != nil {
+			return nil, err
+		}
+		record, err := result.Single()
+		if err != nil {
+			return nil, err
+		}
+		node, _ := record.Get("u")
+		user, err := UserFromNode(node.(neo4j.Node))
+		if err != nil {
+			return nil, err
+		}
+		// registering logs the user in, starting a new family of refresh tokens
+		family, err := newTokenId()
+		if err != nil {
+			return nil, err
+		}
+		return as.issueTokens(tx, user, family)
+	})
+	if isConstraintViolation(err) {
+		return nil, NewDomainError(422, "An account already exists with this email address",
+			map[string]interface{}{"email": "Email address already taken"})
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	return userWithToken(user, token), nil
+	return result.(*User), nil
 }
 
 // end::register[]
 
+// FindOneByEmailAndPassword authenticates the user by email and password,
+// starting a new family of refresh tokens
 // tag::authenticate[]
-func (as *neo4jAuthService) FindOneByEmailAndPassword(email string, password string) (_ User, err error) {
-	// TODO: Authenticate the user from the database
-	if email != "graphacademy@neo4j.com" {
-		return nil, fmt.Errorf("Incorrect username or password")
-	}
+func (as *neo4jAuthService) FindOneByEmailAndPassword(ctx context.Context, email string, password string) (_ *User, err error) {
+	session := as.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
 
-	user, err := as.loader.ReadObject("fixtures/user.json")
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			MATCH (u:User {email: $email})
+			RETURN u`,
+			map[string]interface{}{"email": normalizeEmail(email)})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(401, "Incorrect username or password", nil)
+		}
+		node, _ := records[0].Get("u")
+		user, err := UserFromNode(node.(neo4j.Node))
+		if err != nil {
+			return nil, err
+		}
+		if !verifyPassword(password, user.Password) {
+			return nil, NewDomainError(401, "Incorrect username or password", nil)
+		}
+		// every login starts a new family of refresh tokens
+		family, err := newTokenId()
+		if err != nil {
+			return nil, err
+		}
+		return as.issueTokens(tx, user, family)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return result.(*User), nil
+}
+
+// end::authenticate[]
 
-	subject := user["userId"].(string)
-	token, err := jwtutils.Sign(subject, userToClaims(user), as.jwtSecret)
+func (as *neo4jAuthService) ExtractUserId(ctx context.Context, bearer string) (string, error) {
+	principal, err := as.ExtractPrincipal(ctx, bearer)
+	if err != nil || principal == nil {
+		return "", err
+	}
+	return principal.UserId, nil
+}
+
+func (as *neo4jAuthService) ExtractPrincipal(ctx context.Context, bearer string) (*Principal, error) {
+	if bearer == "" {
+		return nil, nil
+	}
+	claims, err := as.parseAccessToken(bearer)
 	if err != nil {
 		return nil, err
 	}
+	subject, ok := claims["sub"].(string)
+	if !ok {
+		return nil, NewDomainError(401, "Invalid token subject", nil)
+	}
+	principal := &Principal{UserId: subject}
+	// custom claims are nested under the subject, see jwtutils.Sign
+	if userClaims, ok := claims[subject].(map[string]interface{}); ok {
+		if roles, ok := userClaims["roles"].([]interface{}); ok {
+			for _, role := range roles {
+				if name, ok := role.(string); ok {
+					principal.Roles = append(principal.Roles, name)
+				}
+			}
+		}
+	}
+	return principal, nil
+}
 
-	return userWithToken(user, token), nil
+// parseAccessToken returns the claims of the bearer token, failing with a 401
+// error when it is malformed, expired, lacks an ID or was revoked
+func (as *neo4jAuthService) parseAccessToken(bearer string) (jwt.MapClaims, error) {
+	claims, err := jwtutils.ExtractToken(bearer, as.jwtSecret, func(token *jwt.Token) interface{} {
+		return token.Claims.(jwt.MapClaims)
+	})
+	if err != nil {
+		return nil, NewDomainError(401, "Invalid token", map[string]interface{}{"error": err.Error()})
+	}
+	mapClaims := claims.(jwt.MapClaims)
+	id, ok := mapClaims["jti"].(string)
+	if !ok || id == "" {
+		return nil, NewDomainError(401, "Invalid token ID", nil)
+	}
+	if as.denylist.contains(id) {
+		return nil, NewDomainError(401, "Token revoked", nil)
+	}
+	return mapClaims, nil
 }
 
-// end::authenticate[]
+// normalizeEmail trims and lower-cases email addresses before they are stored
+// or looked up
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
 
-func (as *neo4jAuthService) ExtractUserId(bearer string) (string, error) {
-	if bearer == "" {
-		return "", nil
-	}
-	userId, err := jwtutils.ExtractToken(bearer, as.jwtSecret, func(token *jwt.Token) interface{} {
-		claims := token.Claims.(jwt.MapClaims)
-		return claims["sub"]
+// validateRegistration returns a 422 DomainError listing the blank fields of
+// a registration, checked before the password is hashed
+func validateRegistration(email, plainPassword, name string) error {
+	var v validation
+	v.required("email", email)
+	v.required("password", plainPassword)
+	v.required("name", name)
+	return v.err("Invalid registration")
+}
+
+// EmailMigration reports the outcome of NormalizeEmails.
+// Conflicts lists the normalised emails shared by several accounts, which are
+// left as they are.
+type EmailMigration struct {
+	Users     int64    `json:"users"`
+	Changed   int64    `json:"changed"`
+	Conflicts []string `json:"conflicts"`
+}
+
+// NormalizeEmails is a one-off migration normalising the emails of the
+// accounts registered before emails were, so that their owners can still log
+// in and the uniqueness constraint of User emails covers them.
+// Accounts whose emails only differ by case or whitespace cannot be merged
+// automatically: they are reported and must be merged by hand before the
+// server can create the constraint.
+// The migration is idempotent.
+func NormalizeEmails(ctx context.Context, driver neo4j.Driver) (_ *EmailMigration, err error) {
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			MATCH (u:User)
+			WHERE u.email IS NOT NULL
+			WITH toLower(trim(u.email)) AS email, collect(u) AS users
+			CALL {
+				WITH email, users
+				WITH email, users[0] AS u
+				WHERE size(users) = 1 AND u.email <> email
+				SET u.email = email
+				RETURN count(u) AS changed
+			}
+			RETURN sum(size(users)) AS users, sum(changed) AS changed,
+				[conflict IN collect(CASE WHEN size(users) > 1 THEN email END) WHERE conflict IS NOT NULL] AS conflicts`,
+			nil)
+		if err != nil {
+			return nil, err
+		}
+		record, err := result.Single()
+		if err != nil {
+			return nil, err
+		}
+		var migration EmailMigration
+		if err := mapping.DecodeRecord(record, &migration); err != nil {
+			return nil, err
+		}
+		return &migration, nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return userId.(string), nil
+	return result.(*EmailMigration), nil
+}
+
+// isConstraintViolation returns whether err was raised by Neo4j rejecting a
+// write breaking a constraint, such as the uniqueness of User emails
+func isConstraintViolation(err error) bool {
+	neo4jError, ok := err.(*neo4j.Neo4jError)
+	return ok && neo4jError.Title() == "ConstraintValidationFailed"
 }
 
 func encryptPassword(password string, cost int) (string, error) {
@@ -120,17 +363,20 @@ func verifyPassword(password, hash string) bool {
 
 func userToClaims(user User) map[string]interface{} {
 	return map[string]interface{}{
-		"sub":    user["userId"],
-		"userId": user["userId"],
-		"name":   user["name"],
+		"sub":    user.UserId,
+		"userId": user.UserId,
+		"name":   user.Name,
+		"roles":  user.Roles,
 	}
 }
 
-func userWithToken(user User, token string) User {
-	return map[string]interface{}{
-		"token":  token,
-		"userId": user["userId"],
-		"email":  user["email"],
-		"name":   user["name"],
+func userWithTokens(user User, token, refreshToken string) *User {
+	return &User{
+		Token:        token,
+		RefreshToken: refreshToken,
+		UserId:       user.UserId,
+		Email:        user.Email,
+		Name:         user.Name,
+		Roles:        user.Roles,
 	}
 }
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	if email := normalizeEmail("  GraphAcademy@Neo4j.COM\t"); email != "graphacademy@neo4j.com" {
+		t.Errorf("expected a trimmed, lower-case email, got %q", email)
+	}
+}
+
+func TestIsConstraintViolation(t *testing.T) {
+	violation := &neo4j.Neo4jError{Code: "Neo.ClientError.Schema.ConstraintValidationFailed"}
+	if !isConstraintViolation(violation) {
+		t.Errorf("expected %v to be a constraint violation", violation)
+	}
+	for _, err := range []error{
+		nil,
+		errors.New("ConstraintValidationFailed"),
+		&neo4j.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError"},
+	} {
+		if isConstraintViolation(err) {
+			t.Errorf("expected %v not to be a constraint violation", err)
+		}
+	}
+}
+
+func TestEncryptPassword(t *testing.T) {
+	hash, err := encryptPassword("letmein", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "letmein" || !verifyPassword("letmein", hash) || verifyPassword("letmeout", hash) {
+		t.Errorf("expected %q to only verify the original password", hash)
+	}
+}
+
+func TestSaveRejectsBlankFields(t *testing.T) {
+	// the service has no driver: validation must fail before it is needed
+	service := &neo4jAuthService{saltRounds: 4}
+	_, err := service.Save(context.Background(), " \t", "", " ")
+	domainError, ok := err.(*DomainError)
+	if !ok || domainError.StatusCode() != 422 {
+		t.Fatalf("expected a 422 error, got %v", err)
+	}
+	for _, field := range []string{"email", "password", "name"} {
+		if domainError.details[field] != "is required" {
+			t.Errorf("expected %s to be required, got %v", field, domainError.details)
+		}
+	}
+}
@@ -0,0 +1,767 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	ActedIn  = "ACTED_IN"
+	Directed = "DIRECTED"
+	InGenre  = "IN_GENRE"
+
+	maxTitleLength = 500
+	maxNameLength  = 200
+	maxTextLength  = 10000
+
+	dateLayout = "2006-01-02"
+)
+
+// MovieInput holds the editable properties of a Movie node.
+// Updates replace all of them, so that omitted properties are removed.
+type MovieInput struct {
+	TmdbId     string   `json:"tmdbId"`
+	Title      string   `json:"title"`
+	Plot       string   `json:"plot"`
+	Released   string   `json:"released"`
+	Year       *int64   `json:"year"`
+	Runtime    *int64   `json:"runtime"`
+	ImdbRating *float64 `json:"imdbRating"`
+	Languages  []string `json:"languages"`
+	Countries  []string `json:"countries"`
+	Poster     string   `json:"poster"`
+	Url        string   `json:"url"`
+	// Version is the version the update is based on, required by updates and
+	// ignored on creation
+	Version *int64 `json:"version"`
+}
+
+// PersonInput holds the editable properties of a Person node
+type PersonInput struct {
+	TmdbId  string `json:"tmdbId"`
+	Name    string `json:"name"`
+	Born    string `json:"born"`
+	Died    string `json:"died"`
+	BornIn  string `json:"bornIn"`
+	Bio     string `json:"bio"`
+	Poster  string `json:"poster"`
+	Url     string `json:"url"`
+	Version *int64 `json:"version"`
+}
+
+// GenreInput holds the editable properties of a Genre node.
+// The name identifies the genre and cannot be changed.
+type GenreInput struct {
+	Name    string `json:"name"`
+	Poster  string `json:"poster"`
+	Version *int64 `json:"version"`
+}
+
+// RelationshipInput identifies an ACTED_IN or DIRECTED relationship from a
+// person to a movie, or an IN_GENRE relationship from a movie to a genre
+type RelationshipInput struct {
+	Type string `json:"type"`
+	// From is the tmdbId of the person, or of the movie for IN_GENRE
+	From string `json:"from"`
+	// To is the tmdbId of the movie, or the name of the genre for IN_GENRE
+	To string `json:"to"`
+	// Role is the character played, for ACTED_IN only
+	Role string `json:"role,omitempty"`
+}
+
+// AuditEntry records a change made through the CatalogueService
+type AuditEntry struct {
+	Id       string          `json:"id"`
+	At       string          `json:"at"`
+	UserId   string          `json:"userId"`
+	Action   string          `json:"action"`
+	Entity   string          `json:"entity"`
+	EntityId string          `json:"entityId"`
+	Changes  json.RawMessage `json:"changes,omitempty" neo4j:"-"`
+	// RawChanges is the JSON document stored on the AuditEntry node
+	RawChanges string `json:"-" neo4j:"changes"`
+}
+
+// CatalogueService manages the Movie, Person and Genre nodes and the
+// relationships between them.
+// Updates and deletions must name the version of the node they are based on,
+// and fail with a 409 error when the node has changed since.
+// Every change is recorded as an AuditEntry attributed to adminId.
+type CatalogueService interface {
+	SaveMovie(ctx context.Context, adminId string, input MovieInput) (*Movie, error)
+
+	UpdateMovie(ctx context.Context, adminId, tmdbId string, input MovieInput) (*Movie, error)
+
+	DeleteMovie(ctx context.Context, adminId, tmdbId string, version int64) (*Movie, error)
+
+	SavePerson(ctx context.Context, adminId string, input PersonInput) (*Person, error)
+
+	UpdatePerson(ctx context.Context, adminId, tmdbId string, input PersonInput) (*Person, error)
+
+	DeletePerson(ctx context.Context, adminId, tmdbId string, version int64) (*Person, error)
+
+	SaveGenre(ctx context.Context, adminId string, input GenreInput) (*Genre, error)
+
+	UpdateGenre(ctx context.Context, adminId, name string, input GenreInput) (*Genre, error)
+
+	DeleteGenre(ctx context.Context, adminId, name string, version int64) (*Genre, error)
+
+	SaveRelationship(ctx context.Context, adminId string, input RelationshipInput) (*Movie, error)
+
+	DeleteRelationship(ctx context.Context, adminId string, input RelationshipInput) (*Movie, error)
+
+	FindAllAuditEntries(ctx context.Context, page *paging.Paging) ([]AuditEntry, error)
+}
+
+type neo4jCatalogueService struct {
+	driver neo4j.Driver
+}
+
+func NewCatalogueService(driver neo4j.Driver) CatalogueService {
+	return &neo4jCatalogueService{driver: driver}
+}
+
+// catalogueEntity describes a node label and the property identifying its
+// nodes
+type catalogueEntity struct {
+	label string
+	key   string
+	// dependents is a Cypher list of the nodes deleted along with the node
+	// bound to `n`, which make no sense without it
+	dependents string
+}
+
+var (
+	movieEntity = catalogueEntity{label: "Movie", key: "tmdbId", dependents: `
+		[ (n)-[:HAS_RATING_AGGREGATE|HAS_MONTHLY_RATINGS]->(d) | d ] +
+		[ (n)<-[:REVIEWS]-(d:Review) | d ]`}
+	personEntity = catalogueEntity{label: "Person", key: "tmdbId", dependents: "[]"}
+	genreEntity  = catalogueEntity{label: "Genre", key: "name", dependents: "[]"}
+)
+
+type catalogueRelationship struct {
+	from catalogueEntity
+	to   catalogueEntity
+	// movieEnd names the end of the relationship bound to the movie
+	movieEnd string
+	roles    bool
+}
+
+var catalogueRelationships = map[string]catalogueRelationship{
+	ActedIn:  {from: personEntity, to: movieEntity, movieEnd: "to", roles: true},
+	Directed: {from: personEntity, to: movieEntity, movieEnd: "to"},
+	InGenre:  {from: movieEntity, to: genreEntity, movieEnd: "from"},
+}
+
+func (cs *neo4jCatalogueService) SaveMovie(ctx context.Context, adminId string, input MovieInput) (*Movie, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	var movie Movie
+	if err := cs.createNode(ctx, adminId, movieEntity, input.TmdbId, input.properties(), &movie); err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (cs *neo4jCatalogueService) UpdateMovie(ctx context.Context, adminId, tmdbId string, input MovieInput) (*Movie, error) {
+	if err := checkKey(movieEntity, tmdbId, &input.TmdbId); err != nil {
+		return nil, err
+	}
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	version, err := requireVersion(input.Version)
+	if err != nil {
+		return nil, err
+	}
+	var movie Movie
+	if err := cs.updateNode(ctx, adminId, movieEntity, tmdbId, version, input.properties(), &movie); err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (cs *neo4jCatalogueService) DeleteMovie(ctx context.Context, adminId, tmdbId string, version int64) (*Movie, error) {
+	var movie Movie
+	if err := cs.deleteNode(ctx, adminId, movieEntity, tmdbId, version, &movie); err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (cs *neo4jCatalogueService) SavePerson(ctx context.Context, adminId string, input PersonInput) (*Person, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	var person Person
+	if err := cs.createNode(ctx, adminId, personEntity, input.TmdbId, input.properties(), &person); err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+func (cs *neo4jCatalogueService) UpdatePerson(ctx context.Context, adminId, tmdbId string, input PersonInput) (*Person, error) {
+	if err := checkKey(personEntity, tmdbId, &input.TmdbId); err != nil {
+		return nil, err
+	}
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	version, err := requireVersion(input.Version)
+	if err != nil {
+		return nil, err
+	}
+	var person Person
+	if err := cs.updateNode(ctx, adminId, personEntity, tmdbId, version, input.properties(), &person); err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+func (cs *neo4jCatalogueService) DeletePerson(ctx context.Context, adminId, tmdbId string, version int64) (*Person, error) {
+	var person Person
+	if err := cs.deleteNode(ctx, adminId, personEntity, tmdbId, version, &person); err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+func (cs *neo4jCatalogueService) SaveGenre(ctx context.Context, adminId string, input GenreInput) (*Genre, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	var genre Genre
+	if err := cs.createNode(ctx, adminId, genreEntity, input.Name, input.properties(), &genre); err != nil {
+		return nil, err
+	}
+	return &genre, nil
+}
+
+func (cs *neo4jCatalogueService) UpdateGenre(ctx context.Context, adminId, name string, input GenreInput) (*Genre, error) {
+	if err := checkKey(genreEntity, name, &input.Name); err != nil {
+		return nil, err
+	}
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	version, err := requireVersion(input.Version)
+	if err != nil {
+		return nil, err
+	}
+	var genre Genre
+	if err := cs.updateNode(ctx, adminId, genreEntity, name, version, input.properties(), &genre); err != nil {
+		return nil, err
+	}
+	return &genre, nil
+}
+
+func (cs *neo4jCatalogueService) DeleteGenre(ctx context.Context, adminId, name string, version int64) (*Genre, error) {
+	var genre Genre
+	if err := cs.deleteNode(ctx, adminId, genreEntity, name, version, &genre); err != nil {
+		return nil, err
+	}
+	return &genre, nil
+}
+
+// SaveRelationship creates the relationship if missing, updating the role of
+// existing ACTED_IN relationships, and returns the movie with its credits
+func (cs *neo4jCatalogueService) SaveRelationship(ctx context.Context, adminId string, input RelationshipInput) (*Movie, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	relationship := catalogueRelationships[input.Type]
+	setRole := ""
+	if relationship.roles {
+		setRole = "SET r.role = $role"
+	}
+	return cs.writeRelationship(ctx, adminId, "link", input, fmt.Sprintf(`
+		MATCH (from:%s {%s: $from})
+		MATCH (to:%s {%s: $to})
+		MERGE (from)-[r:%s]->(to)
+		%s
+		WITH %s AS m`,
+		relationship.from.label, relationship.from.key,
+		relationship.to.label, relationship.to.key,
+		input.Type, setRole, relationship.movieEnd))
+}
+
+// DeleteRelationship removes the relationship, and returns the movie with its
+// remaining credits
+func (cs *neo4jCatalogueService) DeleteRelationship(ctx context.Context, adminId string, input RelationshipInput) (*Movie, error) {
+	input.Role = ""
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	relationship := catalogueRelationships[input.Type]
+	return cs.writeRelationship(ctx, adminId, "unlink", input, fmt.Sprintf(`
+		MATCH (from:%s {%s: $from})-[r:%s]->(to:%s {%s: $to})
+		DELETE r
+		WITH DISTINCT %s AS m`,
+		relationship.from.label, relationship.from.key, input.Type,
+		relationship.to.label, relationship.to.key, relationship.movieEnd))
+}
+
+func (cs *neo4jCatalogueService) FindAllAuditEntries(ctx context.Context, page *paging.Paging) (_ []AuditEntry, err error) {
+	session := cs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (a:AuditEntry)
+			RETURN a { .*, at: toString(a.at) } AS entry
+			ORDER BY a.at %s
+			SKIP $skip
+			LIMIT $limit`, direction(page)),
+			map[string]interface{}{"skip": page.Skip(), "limit": page.Limit()})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]AuditEntry, len(records))
+		for i, record := range records {
+			if err := mapping.DecodeColumn(record, "entry", &entries[i]); err != nil {
+				return nil, err
+			}
+			if entries[i].RawChanges != "" {
+				entries[i].Changes = json.RawMessage(entries[i].RawChanges)
+			}
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]AuditEntry), nil
+}
+
+func (cs *neo4jCatalogueService) createNode(ctx context.Context, adminId string, entity catalogueEntity, id string, properties map[string]interface{}, target interface{}) (err error) {
+	session := cs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	alreadyExists := NewDomainError(409, fmt.Sprintf("%s already exists", entity.label), map[string]interface{}{
+		entity.key: id,
+	})
+	_, err = writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		// MERGE locks the key, and the uniqueness constraints created by
+		// EnsureConstraints keep concurrent creations from both succeeding
+		result, err := tx.Run(fmt.Sprintf(`
+			MERGE (n:%[1]s {%[2]s: $id})
+			ON CREATE SET n = $properties, n.%[2]s = $id, n.version = 1, n._created = true
+			WITH n, n._created IS NOT NULL AS created
+			REMOVE n._created
+			RETURN n, created`, entity.label, entity.key),
+			map[string]interface{}{"id": id, "properties": properties})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if created, _ := records[0].Get("created"); created != true {
+			return nil, alreadyExists
+		}
+		if err := mapping.DecodeColumn(records[0], "n", target); err != nil {
+			return nil, err
+		}
+		return nil, recordAudit(tx, adminId, "create", entity.label, id, properties)
+	})
+	if isConstraintViolation(err) {
+		return alreadyExists
+	}
+	return err
+}
+
+// updateNode locks the node before comparing its version, so that concurrent
+// updates based on the same version cannot both succeed
+func (cs *neo4jCatalogueService) updateNode(ctx context.Context, adminId string, entity catalogueEntity, id string, version int64, properties map[string]interface{}, target interface{}) (err error) {
+	session := cs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	_, err = writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (n:%s {%s: $id})
+			SET n.version = coalesce(n.version, 0)
+			WITH n, n.version AS version
+			FOREACH (_ IN CASE WHEN version = $version THEN [1] ELSE [] END |
+				SET n += $properties, n.version = version + 1)
+			RETURN n, version`, entity.label, entity.key),
+			map[string]interface{}{"id": id, "version": version, "properties": properties})
+		if err != nil {
+			return nil, err
+		}
+		record, err := lockedVersion(ctx, result, entity, id, version)
+		if err != nil {
+			return nil, err
+		}
+		if err := mapping.DecodeColumn(record, "n", target); err != nil {
+			return nil, err
+		}
+		return nil, recordAudit(tx, adminId, "update", entity.label, id, properties)
+	})
+	return err
+}
+
+// deleteNode deletes the node along with its dependents, e.g. the rating
+// aggregates and reviews of movies
+func (cs *neo4jCatalogueService) deleteNode(ctx context.Context, adminId string, entity catalogueEntity, id string, version int64, target interface{}) (err error) {
+	session := cs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	_, err = writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (n:%s {%s: $id})
+			SET n.version = coalesce(n.version, 0)
+			WITH n, n.version AS version, n { .* } AS before, %s AS dependents
+			FOREACH (_ IN CASE WHEN version = $version THEN [1] ELSE [] END |
+				FOREACH (d IN dependents | DETACH DELETE d)
+				DETACH DELETE n)
+			RETURN before, version`, entity.label, entity.key, entity.dependents),
+			map[string]interface{}{"id": id, "version": version})
+		if err != nil {
+			return nil, err
+		}
+		record, err := lockedVersion(ctx, result, entity, id, version)
+		if err != nil {
+			return nil, err
+		}
+		if err := mapping.DecodeColumn(record, "before", target); err != nil {
+			return nil, err
+		}
+		return nil, recordAudit(tx, adminId, "delete", entity.label, id, nil)
+	})
+	return err
+}
+
+// lockedVersion returns the single record of an update or deletion, or an
+// error if the node does not exist or its version is not the expected one
+func lockedVersion(ctx context.Context, result neo4j.Result, entity catalogueEntity, id string, expected int64) (*neo4j.Record, error) {
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, NewDomainError(404, fmt.Sprintf("%s not found", entity.label), map[string]interface{}{
+			entity.key: id,
+		})
+	}
+	var row struct {
+		Version int64 `neo4j:"version"`
+	}
+	if err := mapping.DecodeRecord(records[0], &row); err != nil {
+		return nil, err
+	}
+	if row.Version != expected {
+		return nil, NewDomainError(409, fmt.Sprintf("%s was modified concurrently", entity.label), map[string]interface{}{
+			"expectedVersion": expected,
+			"actualVersion":   row.Version,
+		})
+	}
+	return records[0], nil
+}
+
+// writeRelationship runs the cypher statement, which binds the movie at either
+// end of the relationship to `m`, and returns that movie
+func (cs *neo4jCatalogueService) writeRelationship(ctx context.Context, adminId string, action string, input RelationshipInput, cypher string) (_ *Movie, err error) {
+	session := cs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			%s
+			RETURN %s AS movie`, cypher, movieProjection("m", nil)),
+			map[string]interface{}{"from": input.From, "to": input.To, "role": input.Role})
+		if err != nil {
+			return nil, err
+		}
+		movies, err := collectMovies(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(movies) == 0 {
+			return nil, NewDomainError(404, fmt.Sprintf("%s relationship ends not found", input.Type), map[string]interface{}{
+				"from": input.From,
+				"to":   input.To,
+			})
+		}
+		changes := map[string]interface{}{"type": input.Type, "from": input.From, "to": input.To}
+		if input.Role != "" {
+			changes["role"] = input.Role
+		}
+		entityId := movies[0].TmdbId
+		return &movies[0], recordAudit(tx, adminId, action, movieEntity.label, entityId, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Movie), nil
+}
+
+// recordAudit creates an AuditEntry node for the change, linked to the User
+// who made it
+func recordAudit(tx neo4j.Transaction, userId, action, entity, entityId string, changes map[string]interface{}) error {
+	var rawChanges interface{}
+	if changes != nil {
+		encoded, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		rawChanges = string(encoded)
+	}
+	result, err := tx.Run(`
+		CREATE (a:AuditEntry {
+			id: randomUUID(), at: datetime(), userId: $userId, action: $action,
+			entity: $entity, entityId: $entityId, changes: $changes
+		})
+		WITH a
+		OPTIONAL MATCH (u:User {userId: $userId})
+		FOREACH (_ IN CASE WHEN u IS NULL THEN [] ELSE [1] END |
+			CREATE (u)-[:PERFORMED]->(a))`,
+		map[string]interface{}{
+			"userId":   userId,
+			"action":   action,
+			"entity":   entity,
+			"entityId": entityId,
+			"changes":  rawChanges,
+		})
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
+}
+
+// checkKey rejects updates changing the identifying property of a node,
+// filling it in when omitted
+func checkKey(entity catalogueEntity, id string, inputKey *string) error {
+	if *inputKey == "" {
+		*inputKey = id
+	}
+	if *inputKey != id {
+		return NewDomainError(422, fmt.Sprintf("%s cannot be changed", entity.key), map[string]interface{}{
+			entity.key: "must match the identifier in the path",
+		})
+	}
+	return nil
+}
+
+// requireVersion returns the version an update is based on, rejecting
+// updates without one with a 422 DomainError: nodes created before versions
+// were introduced are at version 0, which must be given explicitly
+func requireVersion(version *int64) (int64, error) {
+	if version == nil {
+		return 0, NewDomainError(422, "Missing version", map[string]interface{}{
+			"version": "is required",
+		})
+	}
+	return *version, nil
+}
+
+func (mi MovieInput) validate() error {
+	var v validation
+	v.required("tmdbId", mi.TmdbId)
+	v.required("title", mi.Title)
+	v.maxLength("title", mi.Title, maxTitleLength)
+	v.maxLength("plot", mi.Plot, maxTextLength)
+	v.date("released", mi.Released)
+	if mi.Year != nil && (*mi.Year < 1870 || *mi.Year > 2100) {
+		v.fail("year", "must be between 1870 and 2100")
+	}
+	if mi.Runtime != nil && *mi.Runtime <= 0 {
+		v.fail("runtime", "must be positive")
+	}
+	if mi.ImdbRating != nil && (*mi.ImdbRating < 0 || *mi.ImdbRating > 10) {
+		v.fail("imdbRating", "must be between 0 and 10")
+	}
+	v.url("poster", mi.Poster)
+	v.url("url", mi.Url)
+	return v.err("Invalid movie")
+}
+
+func (mi MovieInput) properties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"title":      optionalString(mi.Title),
+		"plot":       optionalString(mi.Plot),
+		"released":   optionalString(mi.Released),
+		"poster":     optionalString(mi.Poster),
+		"url":        optionalString(mi.Url),
+		"year":       nil,
+		"runtime":    nil,
+		"imdbRating": nil,
+		"languages":  nil,
+		"countries":  nil,
+	}
+	if mi.Year != nil {
+		properties["year"] = *mi.Year
+	}
+	if mi.Runtime != nil {
+		properties["runtime"] = *mi.Runtime
+	}
+	if mi.ImdbRating != nil {
+		properties["imdbRating"] = *mi.ImdbRating
+	}
+	if len(mi.Languages) > 0 {
+		properties["languages"] = mi.Languages
+	}
+	if len(mi.Countries) > 0 {
+		properties["countries"] = mi.Countries
+	}
+	return properties
+}
+
+func (pi PersonInput) validate() error {
+	var v validation
+	v.required("tmdbId", pi.TmdbId)
+	v.required("name", pi.Name)
+	v.maxLength("name", pi.Name, maxNameLength)
+	v.maxLength("bornIn", pi.BornIn, maxNameLength)
+	v.maxLength("bio", pi.Bio, maxTextLength)
+	born, bornOk := v.date("born", pi.Born)
+	died, diedOk := v.date("died", pi.Died)
+	if bornOk && diedOk && died.Before(born) {
+		v.fail("died", "must not be before born")
+	}
+	v.url("poster", pi.Poster)
+	v.url("url", pi.Url)
+	return v.err("Invalid person")
+}
+
+// properties stores birth and death dates as Cypher dates, like the
+// imported data does
+func (pi PersonInput) properties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"name":   optionalString(pi.Name),
+		"bornIn": optionalString(pi.BornIn),
+		"bio":    optionalString(pi.Bio),
+		"poster": optionalString(pi.Poster),
+		"url":    optionalString(pi.Url),
+		"born":   nil,
+		"died":   nil,
+	}
+	if born, err := time.Parse(dateLayout, pi.Born); err == nil {
+		properties["born"] = neo4j.DateOf(born)
+	}
+	if died, err := time.Parse(dateLayout, pi.Died); err == nil {
+		properties["died"] = neo4j.DateOf(died)
+	}
+	return properties
+}
+
+func (gi GenreInput) validate() error {
+	var v validation
+	v.required("name", gi.Name)
+	v.maxLength("name", gi.Name, maxNameLength)
+	v.url("poster", gi.Poster)
+	return v.err("Invalid genre")
+}
+
+func (gi GenreInput) properties() map[string]interface{} {
+	return map[string]interface{}{
+		"poster": optionalString(gi.Poster),
+	}
+}
+
+func (ri RelationshipInput) validate() error {
+	var v validation
+	relationship, found := catalogueRelationships[ri.Type]
+	if !found {
+		v.fail("type", fmt.Sprintf("must be one of %s, %s or %s", ActedIn, Directed, InGenre))
+	}
+	v.required("from", ri.From)
+	v.required("to", ri.To)
+	if found && !relationship.roles && ri.Role != "" {
+		v.fail("role", fmt.Sprintf("is only supported by %s", ActedIn))
+	}
+	v.maxLength("role", ri.Role, maxNameLength)
+	return v.err("Invalid relationship")
+}
+
+// validation collects the problems of an input, by field
+type validation struct {
+	details map[string]interface{}
+}
+
+func (v *validation) fail(field, message string) {
+	if v.details == nil {
+		v.details = map[string]interface{}{}
+	}
+	if _, found := v.details[field]; !found {
+		v.details[field] = message
+	}
+}
+
+func (v *validation) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+	}
+}
+
+func (v *validation) maxLength(field, value string, length int) {
+	if len([]rune(value)) > length {
+		v.fail(field, fmt.Sprintf("must be at most %d characters long", length))
+	}
+}
+
+// date checks that value is empty or a YYYY-MM-DD date, and returns the
+// parsed date when present
+func (v *validation) date(field, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		v.fail(field, "must be a YYYY-MM-DD date")
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+func (v *validation) url(field, value string) {
+	if value == "" {
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.fail(field, "must be an http or https URL")
+	}
+}
+
+// err returns a 422 DomainError listing the problems found, if any
+func (v *validation) err(message string) error {
+	if len(v.details) == 0 {
+		return nil
+	}
+	return NewDomainError(422, message, v.details)
+}
+
+// optionalString maps empty strings to null, which removes the property when
+// set on a node
+func optionalString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCatalogueValidation(outer *testing.T) {
+	year := int64(1800)
+	rating := 11.0
+	testCases := []struct {
+		name    string
+		err     error
+		details map[string]interface{}
+	}{
+		{
+			name: "valid movie",
+			err:  MovieInput{TmdbId: "769", Title: "Goodfellas", Released: "1990-09-19", Poster: "https://image.tmdb.org/goodfellas.jpg"}.validate(),
+		},
+		{
+			name: "invalid movie",
+			err:  MovieInput{TmdbId: "769", Year: &year, ImdbRating: &rating, Released: "19/09/1990", Url: "ftp://example.com"}.validate(),
+			details: map[string]interface{}{
+				"title":      "is required",
+				"year":       "must be between 1870 and 2100",
+				"imdbRating": "must be between 0 and 10",
+				"released":   "must be a YYYY-MM-DD date",
+				"url":        "must be an http or https URL",
+			},
+		},
+		{
+			name: "person dying before birth",
+			err:  PersonInput{TmdbId: "1158", Name: "Al Pacino", Born: "1940-04-25", Died: "1930-01-01"}.validate(),
+			details: map[string]interface{}{
+				"died": "must not be before born",
+			},
+		},
+		{
+			name: "genre without name",
+			err:  GenreInput{}.validate(),
+			details: map[string]interface{}{
+				"name": "is required",
+			},
+		},
+		{
+			name: "role on a non ACTED_IN relationship",
+			err:  RelationshipInput{Type: Directed, From: "1032", To: "769", Role: "Director"}.validate(),
+			details: map[string]interface{}{
+				"role": "is only supported by ACTED_IN",
+			},
+		},
+		{
+			name: "unknown relationship type",
+			err:  RelationshipInput{Type: "PRODUCED", From: "1032", To: "769"}.validate(),
+			details: map[string]interface{}{
+				"type": "must be one of ACTED_IN, DIRECTED or IN_GENRE",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.name, func(t *testing.T) {
+			if testCase.details == nil {
+				if testCase.err != nil {
+					t.Fatalf("expected no error, got %v", testCase.err)
+				}
+				return
+			}
+			domainErr, ok := testCase.err.(*DomainError)
+			if !ok || domainErr.StatusCode() != 422 {
+				t.Fatalf("expected a 422 DomainError, got %v", testCase.err)
+			}
+			expected, _ := json.Marshal(testCase.details)
+			actual, _ := json.Marshal(domainErr.details)
+			if string(expected) != string(actual) {
+				t.Fatalf("expected details %s, got %s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	key := ""
+	if err := checkKey(movieEntity, "769", &key); err != nil || key != "769" {
+		t.Fatalf("expected the key to be filled in, got %q and %v", key, err)
+	}
+	key = "770"
+	if err := checkKey(movieEntity, "769", &key); err == nil {
+		t.Fatal("expected changing the key to fail")
+	}
+}
+
+func TestRequireVersion(t *testing.T) {
+	_, err := requireVersion(nil)
+	domainError, ok := err.(*DomainError)
+	if !ok || domainError.StatusCode() != 422 || domainError.details["version"] == nil {
+		t.Fatalf("expected a 422 error on version, got %v", domainError)
+	}
+	var input MovieInput
+	if err := json.Unmarshal([]byte(`{"tmdbId": "769", "version": 0}`), &input); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := requireVersion(input.Version); err != nil || version != 0 {
+		t.Fatalf("expected an explicit version 0 to be accepted, got %d and %v", version, err)
+	}
+}
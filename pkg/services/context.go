@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+type contextKey int
+
+const (
+	requestIdKey contextKey = iota
+	routeNameKey
+)
+
+// WithRequestId attaches the ID of the HTTP request being served to ctx.
+// It is forwarded to Neo4j as transaction metadata.
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// WithRouteName attaches the name of the route being served to ctx.
+// It is forwarded to Neo4j as transaction metadata.
+func WithRouteName(ctx context.Context, routeName string) context.Context {
+	return context.WithValue(ctx, routeNameKey, routeName)
+}
+
+// RequestId returns the request ID attached to ctx, if any
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey).(string)
+	return requestId
+}
+
+// transactionConfig derives the transaction timeout from the deadline of ctx and
+// the transaction metadata from the request ID and route name attached to it.
+// The server aborts the transaction once the deadline has passed.
+func transactionConfig(ctx context.Context) []func(*neo4j.TransactionConfig) {
+	metadata := map[string]interface{}{}
+	if requestId := RequestId(ctx); requestId != "" {
+		metadata["requestId"] = requestId
+	}
+	if routeName, ok := ctx.Value(routeNameKey).(string); ok {
+		metadata["route"] = routeName
+	}
+	configurers := []func(*neo4j.TransactionConfig){neo4j.WithTxMetadata(metadata)}
+	if deadline, ok := ctx.Deadline(); ok {
+		// a zero timeout means "no timeout" to the server, round up instead
+		timeout := time.Until(deadline)
+		if timeout < time.Millisecond {
+			timeout = time.Millisecond
+		}
+		configurers = append(configurers, neo4j.WithTxTimeout(timeout))
+	}
+	return configurers
+}
+
+// readTransaction runs work in a read transaction configured after ctx.
+// The work is not started, nor retried, once ctx is done.
+func readTransaction(ctx context.Context, session neo4j.Session, work neo4j.TransactionWork) (interface{}, error) {
+	return session.ReadTransaction(cancellableWork(ctx, work), transactionConfig(ctx)...)
+}
+
+// writeTransaction runs work in a write transaction configured after ctx.
+// The work is not started, nor retried, once ctx is done.
+func writeTransaction(ctx context.Context, session neo4j.Session, work neo4j.TransactionWork) (interface{}, error) {
+	return session.WriteTransaction(cancellableWork(ctx, work), transactionConfig(ctx)...)
+}
+
+func cancellableWork(ctx context.Context, work neo4j.TransactionWork) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := work(tx)
+		if err != nil {
+			return nil, err
+		}
+		// returning an error rolls the transaction back
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+// collect buffers the remaining records of result, stopping early when ctx
+// is done so that abandoned requests stop pulling records.
+func collect(ctx context.Context, result neo4j.Result) ([]*neo4j.Record, error) {
+	var records []*neo4j.Record
+	for result.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		records = append(records, result.Record())
+	}
+	return records, result.Err()
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// enrichMovies sets the `favorite` flag of each movie for the given user, as
+// well as the user's own `rating` when they rated it.
+// Movies are looked up with a single query, whatever their number.
+// Nothing is set for anonymous users.
+func enrichMovies(ctx context.Context, tx neo4j.Transaction, userId string, movies []Movie) error {
+	if userId == "" || len(movies) == 0 {
+		return nil
+	}
+	tmdbIds := make([]string, len(movies))
+	for i, movie := range movies {
+		tmdbIds[i] = movie.TmdbId
+	}
+	states, err := getUserFavorites(ctx, tx, userId, tmdbIds)
+	if err != nil {
+		return err
+	}
+	for i := range movies {
+		state := states[movies[i].TmdbId]
+		favorite := state.Favorite
+		movies[i].Favorite = &favorite
+		movies[i].Rating = state.Rating
+	}
+	return nil
+}
+
+// enrichMoviesInSession runs enrichMovies in a transaction of its own, for
+// movies that were not read from the database
+func enrichMoviesInSession(ctx context.Context, driver neo4j.Driver, userId string, movies []Movie) (err error) {
+	if userId == "" || len(movies) == 0 {
+		return nil
+	}
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+	_, err = readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		return nil, enrichMovies(ctx, tx, userId, movies)
+	})
+	return err
+}
+
+type userMovieState struct {
+	TmdbId   string   `neo4j:"tmdbId"`
+	Favorite bool     `neo4j:"favorite"`
+	Rating   *float64 `neo4j:"rating"`
+}
+
+// getUserFavorites returns, for each of the tmdbIds the user has added to their
+// 'My Favorites' list or rated, whether it is a favorite and the user's rating.
+// tag::getUserFavorites[]
+func getUserFavorites(ctx context.Context, tx neo4j.Transaction, userId string, tmdbIds []string) (map[string]userMovieState, error) {
+	result, err := tx.Run(`
+		MATCH (u:User {userId: $userId})
+		UNWIND $tmdbIds AS tmdbId
+		MATCH (m:Movie {tmdbId: tmdbId})
+		OPTIONAL MATCH (u)-[r:RATED]->(m)
+		WITH m, r, exists((u)-[:HAS_FAVORITE]->(m)) AS favorite
+		WHERE favorite OR r IS NOT NULL
+		RETURN m.tmdbId AS tmdbId, favorite, r.rating AS rating`,
+		map[string]interface{}{"userId": userId, "tmdbIds": tmdbIds})
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]userMovieState, len(records))
+	for _, record := range records {
+		var state userMovieState
+		if err := mapping.DecodeRecord(record, &state); err != nil {
+			return nil, err
+		}
+		states[state.TmdbId] = state
+	}
+	return states, nil
+}
+
+// end::getUserFavorites[]
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
@@ -8,11 +9,11 @@ import (
 )
 
 type FavoriteService interface {
-	Save(userId, movieId string) (Movie, error)
+	Save(ctx context.Context, userId, movieId string) (*Movie, error)
 
-	FindAllByUserId(userId string, page *paging.Paging) ([]Movie, error)
+	FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) ([]Movie, error)
 
-	Delete(userId, movieId string) (Movie, error)
+	Delete(ctx context.Context, userId, movieId string) (*Movie, error)
 }
 
 type neo4jFavoriteService struct {
@@ -29,18 +30,22 @@ func NewFavoriteService(loader *fixtures.FixtureLoader, driver neo4j.Driver) Fav
 //
 // If either the user or movie cannot be found, a `NotFoundError` should be thrown.
 // tag::add[]
-func (fs *neo4jFavoriteService) Save(userId, movieId string) (_ Movie, err error) {
+func (fs *neo4jFavoriteService) Save(ctx context.Context, userId, movieId string) (_ *Movie, err error) {
 	// TODO: Open a new Session
 	// TODO: Create HAS_FAVORITE relationship within a Write Transaction
 	// TODO: Close the session
 	// TODO: Return movie details and `favorite` property
 
-	result, err := fs.loader.ReadObject("fixtures/goodfellas.json")
-	if err != nil {
+	movies := make([]Movie, 1)
+	if err := fs.loader.Read("fixtures/goodfellas.json", &movies[0]); err != nil {
 		return nil, err
 	}
-	result["favorite"] = true
-	return result, nil
+	if err := enrichMoviesInSession(ctx, fs.driver, userId, movies); err != nil {
+		return nil, err
+	}
+	favorite := true
+	movies[0].Favorite = &favorite
+	return &movies[0], nil
 }
 
 // end::add[]
@@ -53,12 +58,16 @@ func (fs *neo4jFavoriteService) Save(userId, movieId string) (_ Movie, err error
 // Results should be limited to the number passed as `limit`.
 // The `skip` variable should be used to skip a certain number of rows.
 // tag::all[]
-func (fs *neo4jFavoriteService) FindAllByUserId(userId string, page *paging.Paging) (_ []Movie, err error) {
+func (fs *neo4jFavoriteService) FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) (_ []Movie, err error) {
 	// TODO: Open a new session
 	// TODO: Retrieve a list of movies favorited by the user
 	// TODO: Close session
 
-	return fs.loader.ReadArray("fixtures/popular.json")
+	var movies []Movie
+	if err := fs.loader.Read("fixtures/popular.json", &movies); err != nil {
+		return nil, err
+	}
+	return movies, enrichMoviesInSession(ctx, fs.driver, userId, movies)
 }
 
 // end::all[]
@@ -68,18 +77,22 @@ func (fs *neo4jFavoriteService) FindAllByUserId(userId string, page *paging.Pagi
 // If either the user, movie or the relationship between them cannot be found,
 // a `NotFoundError` should be thrown.
 // tag::remove[]
-func (fs *neo4jFavoriteService) Delete(userId, movieId string) (_ Movie, err error) {
+func (fs *neo4jFavoriteService) Delete(ctx context.Context, userId, movieId string) (_ *Movie, err error) {
 	// TODO: Open a new Session
 	// TODO: Delete the HAS_FAVORITE relationship within a Write Transaction
 	// TODO: Close the session
 	// TODO: Return movie details and `favorite` property
 
-	result, err := fs.loader.ReadObject("fixtures/goodfellas.json")
-	if err != nil {
+	movies := make([]Movie, 1)
+	if err := fs.loader.Read("fixtures/goodfellas.json", &movies[0]); err != nil {
+		return nil, err
+	}
+	if err := enrichMoviesInSession(ctx, fs.driver, userId, movies); err != nil {
 		return nil, err
 	}
-	result["favorite"] = false
-	return result, nil
+	favorite := false
+	movies[0].Favorite = &favorite
+	return &movies[0], nil
 }
 
 // end::remove[]
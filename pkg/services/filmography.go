@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	ActorCredit    = "actor"
+	DirectorCredit = "director"
+
+	// Filmography groupings, no grouping lists the credits chronologically
+	GroupByDecade = "decade"
+	GroupByType   = "type"
+	GroupByRole   = "role"
+
+	maxCollaborators = 5
+	maxTopGenres     = 5
+)
+
+// creditProjection keeps filmographies of prolific people small: credits only
+// carry what lists of movies display, without their embedded cast and genres
+var creditProjection = paging.NewProjection([]string{"tmdbId", "title", "released", "poster", "imdbRating"}, nil)
+
+// Credit is a movie a person acted in, as the Role character, or directed
+type Credit struct {
+	Type  string `json:"type"`
+	Role  string `json:"role,omitempty"`
+	Year  *int64 `json:"year,omitempty"`
+	Movie Movie  `json:"movie"`
+}
+
+// CreditGroup gathers the credits of a decade ("1990s"), of a type, or of a
+// character
+type CreditGroup struct {
+	Key     string   `json:"key"`
+	Credits []Credit `json:"credits"`
+}
+
+// Collaborator is a person credited on Credits of the same movies
+type Collaborator struct {
+	Person  Person `json:"person"`
+	Credits int64  `json:"credits"`
+}
+
+// CareerStats summarizes the credits of a person.
+// TopGenres count the movies of each genre under `movies`.
+type CareerStats struct {
+	TotalCredits      int64          `json:"totalCredits"`
+	ActedCount        int64          `json:"actedCount"`
+	DirectedCount     int64          `json:"directedCount"`
+	AverageImdbRating *float64       `json:"averageImdbRating,omitempty"`
+	Collaborators     []Collaborator `json:"collaborators"`
+	TopGenres         []Genre        `json:"topGenres"`
+}
+
+// Filmography lists the credits of a person chronologically, or in groups
+// when requested
+type Filmography struct {
+	Person  Person        `json:"person"`
+	Credits []Credit      `json:"credits,omitempty"`
+	Groups  []CreditGroup `json:"groups,omitempty"`
+	Stats   CareerStats   `json:"stats"`
+}
+
+// FindFilmographyById returns every ACTED_IN and DIRECTED credit of the
+// person along with career stats, the credits being grouped by decade, type
+// or role when groupBy is set.
+// If a userId value is supplied, the movies carry the favorite flag and
+// rating of the user.
+func (ps *neo4jPeopleService) FindFilmographyById(ctx context.Context, id string, groupBy string, userId string) (_ *Filmography, err error) {
+	if groupBy != "" && groupBy != GroupByDecade && groupBy != GroupByType && groupBy != GroupByRole {
+		return nil, NewDomainError(400, "Invalid grouping", map[string]interface{}{"groupBy": groupBy})
+	}
+
+	session := ps.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (p:Person {tmdbId: $id})
+			CALL {
+				WITH p
+				MATCH (p)-[r:ACTED_IN|DIRECTED]->(m:Movie)
+				RETURN collect({
+					type: CASE type(r) WHEN 'ACTED_IN' THEN $actor ELSE $director END,
+					role: r.role,
+					year: coalesce(m.year, toInteger(left(toString(m.released), 4))),
+					movie: %s
+				}) AS credits
+			}
+			CALL {
+				WITH p
+				MATCH (p)-[:ACTED_IN|DIRECTED]->(m:Movie)
+				WITH DISTINCT m
+				RETURN avg(m.imdbRating) AS averageImdbRating
+			}
+			CALL {
+				WITH p
+				MATCH (p)-[:ACTED_IN|DIRECTED]->(m:Movie)<-[:ACTED_IN|DIRECTED]-(other:Person)
+				WHERE other <> p
+				WITH other, count(DISTINCT m) AS credits
+				ORDER BY credits DESC, other.name
+				LIMIT $collaborators
+				RETURN collect({person: other { .tmdbId, .name, .poster }, credits: credits}) AS collaborators
+			}
+			CALL {
+				WITH p
+				MATCH (p)-[:ACTED_IN|DIRECTED]->(m:Movie)-[:IN_GENRE]->(g:Genre)
+				WITH g, count(DISTINCT m) AS movies
+				ORDER BY movies DESC, g.name
+				LIMIT $genres
+				RETURN collect(g { .name, movies: movies }) AS topGenres
+			}
+			RETURN p { .* } AS person, credits, averageImdbRating, collaborators, topGenres`,
+			movieProjection("m", creditProjection)),
+			map[string]interface{}{
+				"id":            id,
+				"actor":         ActorCredit,
+				"director":      DirectorCredit,
+				"collaborators": maxCollaborators,
+				"genres":        maxTopGenres,
+			})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(404, "Person not found", map[string]interface{}{"id": id})
+		}
+		var row struct {
+			Person            Person         `neo4j:"person"`
+			Credits           []Credit       `neo4j:"credits"`
+			AverageImdbRating *float64       `neo4j:"averageImdbRating"`
+			Collaborators     []Collaborator `neo4j:"collaborators"`
+			TopGenres         []Genre        `neo4j:"topGenres"`
+		}
+		if err := mapping.DecodeRecord(records[0], &row); err != nil {
+			return nil, err
+		}
+		movies := make([]Movie, len(row.Credits))
+		for i, credit := range row.Credits {
+			movies[i] = credit.Movie
+		}
+		if err := enrichMovies(ctx, tx, userId, movies); err != nil {
+			return nil, err
+		}
+		for i := range movies {
+			row.Credits[i].Movie = movies[i]
+		}
+		filmography := newFilmography(row.Person, row.Credits, groupBy)
+		filmography.Stats.AverageImdbRating = row.AverageImdbRating
+		filmography.Stats.Collaborators = row.Collaborators
+		filmography.Stats.TopGenres = row.TopGenres
+		return filmography, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Filmography), nil
+}
+
+// newFilmography sorts the credits chronologically, counts them, and groups
+// them by groupBy when set
+func newFilmography(person Person, credits []Credit, groupBy string) *Filmography {
+	sort.SliceStable(credits, func(i, j int) bool {
+		left, right := credits[i], credits[j]
+		if (left.Year == nil) != (right.Year == nil) {
+			return right.Year == nil
+		}
+		if left.Year != nil && *left.Year != *right.Year {
+			return *left.Year < *right.Year
+		}
+		return left.Movie.Title < right.Movie.Title
+	})
+
+	filmography := &Filmography{Person: person, Stats: CareerStats{TotalCredits: int64(len(credits))}}
+	for _, credit := range credits {
+		if credit.Type == ActorCredit {
+			filmography.Stats.ActedCount++
+		} else {
+			filmography.Stats.DirectedCount++
+		}
+	}
+	if groupBy == "" {
+		filmography.Credits = credits
+		return filmography
+	}
+
+	indexes := map[string]int{}
+	for _, credit := range credits {
+		key := creditGroupKey(credit, groupBy)
+		index, found := indexes[key]
+		if !found {
+			index = len(filmography.Groups)
+			indexes[key] = index
+			filmography.Groups = append(filmography.Groups, CreditGroup{Key: key})
+		}
+		filmography.Groups[index].Credits = append(filmography.Groups[index].Credits, credit)
+	}
+	if groupBy == GroupByRole {
+		sort.SliceStable(filmography.Groups, func(i, j int) bool {
+			return len(filmography.Groups[i].Credits) > len(filmography.Groups[j].Credits)
+		})
+	}
+	return filmography
+}
+
+// creditGroupKey returns the decade of the credit, its type, or the played
+// character, directing credits being grouped under their type
+func creditGroupKey(credit Credit, groupBy string) string {
+	switch groupBy {
+	case GroupByDecade:
+		if credit.Year == nil {
+			return "unknown"
+		}
+		return strconv.FormatInt(*credit.Year/10*10, 10) + "s"
+	case GroupByRole:
+		if credit.Type == ActorCredit && credit.Role != "" {
+			return credit.Role
+		}
+	}
+	return credit.Type
+}
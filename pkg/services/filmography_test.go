@@ -0,0 +1,64 @@
+package services
+
+import "testing"
+
+func TestNewFilmography(outer *testing.T) {
+	year := func(value int64) *int64 { return &value }
+	credits := func() []Credit {
+		return []Credit{
+			{Type: ActorCredit, Role: "Michael Corleone", Year: year(1974), Movie: Movie{Title: "The Godfather: Part II"}},
+			{Type: DirectorCredit, Year: year(1996), Movie: Movie{Title: "Looking for Richard"}},
+			{Type: ActorCredit, Role: "Michael Corleone", Year: year(1972), Movie: Movie{Title: "The Godfather"}},
+			{Type: ActorCredit, Role: "Richard III", Year: year(1996), Movie: Movie{Title: "Looking for Richard"}},
+			{Type: ActorCredit, Role: "Sonny", Movie: Movie{Title: "Unknown"}},
+		}
+	}
+
+	outer.Run("chronological credits", func(t *testing.T) {
+		filmography := newFilmography(Person{Name: "Al Pacino"}, credits(), "")
+		if filmography.Stats.TotalCredits != 5 || filmography.Stats.ActedCount != 4 || filmography.Stats.DirectedCount != 1 {
+			t.Fatalf("unexpected stats %+v", filmography.Stats)
+		}
+		titles := []string{"The Godfather", "The Godfather: Part II", "Looking for Richard", "Looking for Richard", "Unknown"}
+		for i, title := range titles {
+			if filmography.Credits[i].Movie.Title != title {
+				t.Fatalf("expected %q at %d, got %q", title, i, filmography.Credits[i].Movie.Title)
+			}
+		}
+		if len(filmography.Groups) != 0 {
+			t.Fatalf("expected no groups, got %v", filmography.Groups)
+		}
+	})
+
+	outer.Run("grouped by decade", func(t *testing.T) {
+		filmography := newFilmography(Person{}, credits(), GroupByDecade)
+		assertGroups(t, filmography.Groups, map[string]int{"1970s": 2, "1990s": 2, "unknown": 1}, "1970s")
+	})
+
+	outer.Run("grouped by role", func(t *testing.T) {
+		filmography := newFilmography(Person{}, credits(), GroupByRole)
+		assertGroups(t, filmography.Groups, map[string]int{"Michael Corleone": 2, "director": 1, "Richard III": 1, "Sonny": 1}, "Michael Corleone")
+	})
+}
+
+func TestCreditProjection(t *testing.T) {
+	expected := "m { .tmdbId, .title, .released, .poster, .imdbRating }"
+	if projection := movieProjection("m", creditProjection); projection != expected {
+		t.Fatalf("expected credits to only project %s, got %s", expected, projection)
+	}
+}
+
+func assertGroups(t *testing.T, groups []CreditGroup, sizes map[string]int, first string) {
+	t.Helper()
+	if len(groups) != len(sizes) {
+		t.Fatalf("expected %d groups, got %v", len(sizes), groups)
+	}
+	if groups[0].Key != first {
+		t.Fatalf("expected %q first, got %q", first, groups[0].Key)
+	}
+	for _, group := range groups {
+		if len(group.Credits) != sizes[group.Key] {
+			t.Fatalf("expected %d credits in %q, got %d", sizes[group.Key], group.Key, len(group.Credits))
+		}
+	}
+}
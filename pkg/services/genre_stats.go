@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// AllGenres matches the movies in every requested genre
+	AllGenres = "all"
+	// AnyGenre matches the movies in at least one requested genre
+	AnyGenre = "any"
+
+	maxMatchedGenres  = 5
+	maxGenreStatsRows = 10
+)
+
+// DecadeCount is the number of movies released during a decade, e.g. "1990s"
+type DecadeCount struct {
+	Decade string `json:"decade"`
+	Movies int64  `json:"movies"`
+}
+
+// GenreContributor is a person credited on Movies of a genre
+type GenreContributor struct {
+	Person Person `json:"person"`
+	Movies int64  `json:"movies"`
+}
+
+// GenreStats describes a genre.
+// Co-occurring genres count the movies they share with the genre under
+// `movies`.
+type GenreStats struct {
+	Genre             Genre              `json:"genre"`
+	AverageImdbRating *float64           `json:"averageImdbRating,omitempty"`
+	Decades           []DecadeCount      `json:"decades"`
+	TopActors         []GenreContributor `json:"topActors"`
+	TopDirectors      []GenreContributor `json:"topDirectors"`
+	CoOccurringGenres []Genre            `json:"coOccurringGenres"`
+}
+
+func validateGenreMatch(genres []string, match string) error {
+	var v validation
+	if len(genres) == 0 || len(genres) > maxMatchedGenres {
+		v.fail("genres", fmt.Sprintf("must list between 1 and %d genres", maxMatchedGenres))
+	}
+	if match != AllGenres && match != AnyGenre {
+		v.fail("match", fmt.Sprintf("must be %s or %s", AllGenres, AnyGenre))
+	}
+	if len(v.details) == 0 {
+		return nil
+	}
+	return NewDomainError(400, "Invalid genres", v.details)
+}
+
+// FindStatsByName returns the statistics of the genre: average imdbRating,
+// movies per decade, top actors and directors, and the genres its movies
+// most often also belong to.
+// If the genre is not found, a 404 DomainError is returned.
+func (gs *neo4jGenreService) FindStatsByName(ctx context.Context, name string) (_ *GenreStats, err error) {
+	session := gs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			MATCH (g:Genre {name: $name})
+			CALL {
+				WITH g
+				MATCH (g)<-[:IN_GENRE]-(m:Movie)
+				RETURN count(m) AS movies, avg(m.imdbRating) AS averageImdbRating
+			}
+			CALL {
+				WITH g
+				MATCH (g)<-[:IN_GENRE]-(m:Movie)
+				WITH coalesce(m.year, toInteger(left(toString(m.released), 4))) AS year
+				WHERE year IS NOT NULL
+				WITH year / 10 * 10 AS decade, count(*) AS movies
+				ORDER BY decade
+				RETURN collect({decade: toString(decade) + 's', movies: movies}) AS decades
+			}
+			CALL {
+				WITH g
+				MATCH (g)<-[:IN_GENRE]-(m:Movie)<-[:ACTED_IN]-(p:Person)
+				WITH p, count(DISTINCT m) AS movies
+				ORDER BY movies DESC, p.name
+				LIMIT $limit
+				RETURN collect({person: p { .tmdbId, .name, .poster }, movies: movies}) AS topActors
+			}
+			CALL {
+				WITH g
+				MATCH (g)<-[:IN_GENRE]-(m:Movie)<-[:DIRECTED]-(p:Person)
+				WITH p, count(DISTINCT m) AS movies
+				ORDER BY movies DESC, p.name
+				LIMIT $limit
+				RETURN collect({person: p { .tmdbId, .name, .poster }, movies: movies}) AS topDirectors
+			}
+			CALL {
+				WITH g
+				MATCH (g)<-[:IN_GENRE]-(:Movie)-[:IN_GENRE]->(other:Genre)
+				WHERE other <> g
+				WITH other, count(*) AS movies
+				ORDER BY movies DESC, other.name
+				LIMIT $limit
+				RETURN collect(other { .name, movies: movies }) AS coOccurringGenres
+			}
+			RETURN g {
+				.name,
+				movies: movies,
+				poster: [ (g)<-[:IN_GENRE]-(m:Movie) WHERE m.poster IS NOT NULL | m.poster ][0]
+			} AS genre, averageImdbRating, decades, topActors, topDirectors, coOccurringGenres`,
+			map[string]interface{}{"name": name, "limit": maxGenreStatsRows})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(404, "Genre not found", map[string]interface{}{"name": name})
+		}
+		var stats GenreStats
+		if err := mapping.DecodeRecord(records[0], &stats); err != nil {
+			return nil, err
+		}
+		return &stats, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*GenreStats), nil
+}
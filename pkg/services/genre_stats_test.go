@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestValidateGenreMatch(outer *testing.T) {
+	testCases := []struct {
+		name   string
+		genres []string
+		match  string
+		fields []string
+	}{
+		{name: "single genre", genres: []string{"Comedy"}, match: AllGenres},
+		{name: "any genre", genres: []string{"Action", "Comedy"}, match: AnyGenre},
+		{name: "no genre", match: AllGenres, fields: []string{"genres"}},
+		{name: "too many genres", genres: []string{"a", "b", "c", "d", "e", "f"}, match: AnyGenre, fields: []string{"genres"}},
+		{name: "unknown match", genres: []string{"Action", "Comedy"}, match: "both", fields: []string{"match"}},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.name, func(t *testing.T) {
+			err := validateGenreMatch(testCase.genres, testCase.match)
+			if len(testCase.fields) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			domainError, ok := err.(*DomainError)
+			if !ok || domainError.StatusCode() != 400 {
+				t.Fatalf("expected a 400 error, got %v", err)
+			}
+			for _, field := range testCase.fields {
+				if domainError.details[field] == nil {
+					t.Errorf("expected an error on %s, got %v", field, domainError.details)
+				}
+			}
+		})
+	}
+}
@@ -1,18 +1,41 @@
 package services
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
 
-type Genre = map[string]interface{}
+type Genre struct {
+	Name    string `json:"name"`
+	Link    string `json:"link,omitempty"`
+	Movies  *int64 `json:"movies,omitempty"`
+	Poster  string `json:"poster,omitempty"`
+	Version *int64 `json:"version,omitempty"`
+}
+
+// GenreFromNode converts a Genre node
+func GenreFromNode(node neo4j.Node) (Genre, error) {
+	return GenreFromMap(node.Props)
+}
+
+// GenreFromMap converts the result of a Genre map projection
+func GenreFromMap(values map[string]interface{}) (Genre, error) {
+	var genre Genre
+	err := mapping.Decode(values, &genre)
+	return genre, err
+}
 
 type GenreService interface {
-	FindAll() ([]Genre, error)
+	FindAll(ctx context.Context) ([]Genre, error)
+
+	FindOneByName(ctx context.Context, name string) (*Genre, error)
 
-	FindOneByName(name string) (Genre, error)
+	// FindStatsByName returns the statistics of the genre
+	FindStatsByName(ctx context.Context, name string) (*GenreStats, error)
 }
 
 type neo4jGenreService struct {
@@ -38,11 +61,13 @@ func NewGenreService(loader *fixtures.FixtureLoader, driver neo4j.Driver) GenreS
 // ]
 //
 // tag::all[]
-func (gs *neo4jGenreService) FindAll() (_ []Genre, err error) {
+func (gs *neo4jGenreService) FindAll(ctx context.Context) (_ []Genre, err error) {
 	// TODO: Open a new session
 	// TODO: Get a list of Genres from the database
 
-	return gs.loader.ReadArray("fixtures/genres.json")
+	var genres []Genre
+	err = gs.loader.Read("fixtures/genres.json", &genres)
+	return genres, err
 }
 
 // end::all[]
@@ -52,18 +77,18 @@ func (gs *neo4jGenreService) FindAll() (_ []Genre, err error) {
 //
 // If the genre is not found, an error should be thrown.
 // tag::find[]
-func (gs *neo4jGenreService) FindOneByName(name string) (_ Genre, err error) {
+func (gs *neo4jGenreService) FindOneByName(ctx context.Context, name string) (_ *Genre, err error) {
 	// TODO: Open a new session
 	// TODO: Get Genre information from the database
 	// TODO: Return an error if the genre is not found
 
-	genres, err := gs.loader.ReadArray("fixtures/genres.json")
-	if err != nil {
+	var genres []Genre
+	if err := gs.loader.Read("fixtures/genres.json", &genres); err != nil {
 		return nil, err
 	}
 	for _, genre := range genres {
-		if genre["name"] == name {
-			return genre, nil
+		if genre.Name == name {
+			return &genre, nil
 		}
 	}
 	return nil, fmt.Errorf("genre %q not found", name)
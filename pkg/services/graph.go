@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	DefaultNeighbourhoodHops  = 1
+	MaxNeighbourhoodHops      = 3
+	DefaultNeighbourhoodNodes = 100
+	MaxNeighbourhoodNodes     = 500
+)
+
+// graphLabels are the labels of the nodes a neighbourhood may contain, with
+// the properties exposed for each.
+// Users and their activity are never part of a neighbourhood.
+var graphLabels = map[string][]string{
+	"Movie":  {"tmdbId", "title", "year", "poster", "imdbRating"},
+	"Person": {"tmdbId", "name", "poster"},
+	"Genre":  {"name"},
+}
+
+// graphRelationshipTypes are the relationship types a neighbourhood may
+// contain, with the properties exposed for each
+var graphRelationshipTypes = map[string][]string{
+	ActedIn:  {"role"},
+	Directed: nil,
+	InGenre:  nil,
+}
+
+// GraphNode is a node of a neighbourhood, identified by its internal id.
+// Degree counts its relationships of the requested types.
+type GraphNode struct {
+	Id         int64                  `json:"id"`
+	Labels     []string               `json:"labels"`
+	Properties map[string]interface{} `json:"properties"`
+	Degree     int64                  `json:"degree"`
+}
+
+// GraphEdge is a relationship between two nodes of a neighbourhood
+type GraphEdge struct {
+	Id         int64                  `json:"id"`
+	Type       string                 `json:"type"`
+	Source     int64                  `json:"source"`
+	Target     int64                  `json:"target"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Graph is a node/edge document, Truncated when nodes were left out to
+// respect the node cap
+type Graph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
+
+// NeighbourhoodOptions restricts the neighbourhood to Hops relationships from
+// the start node, to nodes with one of the Labels joined by one of the
+// RelationshipTypes, and to MaxNodes nodes, the best connected ones being kept.
+// Empty options default to every label and relationship type, one hop and
+// DefaultNeighbourhoodNodes nodes.
+type NeighbourhoodOptions struct {
+	Hops              int
+	Labels            []string
+	RelationshipTypes []string
+	MaxNodes          int
+}
+
+type GraphService interface {
+	// FindNeighbourhood returns the neighbourhood of the Movie, Person or
+	// Genre node whose key is id
+	FindNeighbourhood(ctx context.Context, label string, id string, options NeighbourhoodOptions) (*Graph, error)
+}
+
+type neo4jGraphService struct {
+	driver neo4j.Driver
+}
+
+func NewGraphService(driver neo4j.Driver) GraphService {
+	return &neo4jGraphService{driver: driver}
+}
+
+var graphEntities = map[string]catalogueEntity{
+	movieEntity.label:  movieEntity,
+	personEntity.label: personEntity,
+	genreEntity.label:  genreEntity,
+}
+
+func (no *NeighbourhoodOptions) validate() error {
+	var v validation
+	if no.Hops == 0 {
+		no.Hops = DefaultNeighbourhoodHops
+	}
+	if no.Hops < 1 || no.Hops > MaxNeighbourhoodHops {
+		v.fail("hops", fmt.Sprintf("must be between 1 and %d", MaxNeighbourhoodHops))
+	}
+	if no.MaxNodes == 0 {
+		no.MaxNodes = DefaultNeighbourhoodNodes
+	}
+	if no.MaxNodes < 1 || no.MaxNodes > MaxNeighbourhoodNodes {
+		v.fail("limit", fmt.Sprintf("must be between 1 and %d", MaxNeighbourhoodNodes))
+	}
+	if len(no.Labels) == 0 {
+		no.Labels = sortedKeys(graphLabels)
+	}
+	for _, label := range no.Labels {
+		if _, found := graphLabels[label]; !found {
+			v.fail("labels", fmt.Sprintf("must be among %s", strings.Join(sortedKeys(graphLabels), ", ")))
+		}
+	}
+	if len(no.RelationshipTypes) == 0 {
+		no.RelationshipTypes = sortedKeys(graphRelationshipTypes)
+	}
+	for _, relationshipType := range no.RelationshipTypes {
+		if _, found := graphRelationshipTypes[relationshipType]; !found {
+			v.fail("types", fmt.Sprintf("must be among %s", strings.Join(sortedKeys(graphRelationshipTypes), ", ")))
+		}
+	}
+	if len(v.details) == 0 {
+		return nil
+	}
+	return NewDomainError(400, "Invalid neighbourhood options", v.details)
+}
+
+// FindNeighbourhood expands the neighbourhood one hop at a time.
+// At each hop, the new nodes with the highest degree are kept until the node
+// cap is reached, and the others are pruned along with whatever lies beyond
+// them.
+// Relationships between the kept nodes are added last.
+// If the start node cannot be found, a 404 DomainError is returned.
+func (gs *neo4jGraphService) FindNeighbourhood(ctx context.Context, label string, id string, options NeighbourhoodOptions) (_ *Graph, err error) {
+	entity, found := graphEntities[label]
+	if !found {
+		return nil, NewDomainError(400, "Unsupported node label", map[string]interface{}{"label": label})
+	}
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	types := strings.Join(options.RelationshipTypes, "|")
+
+	session := gs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (start:%s {%s: $id})
+			RETURN start, size((start)-[:%s]-()) AS degree`,
+			entity.label, entity.key, types),
+			map[string]interface{}{"id": id})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(404, fmt.Sprintf("%s not found", entity.label), map[string]interface{}{entity.key: id})
+		}
+		builder := newGraphBuilder()
+		value, _ := records[0].Get("start")
+		start, ok := value.(neo4j.Node)
+		if !ok {
+			return nil, fmt.Errorf("expected a neo4j.Node, got %T", value)
+		}
+		var degree int64
+		if err := mapping.DecodeColumn(records[0], "degree", &degree); err != nil {
+			return nil, err
+		}
+		builder.addNode(start, degree)
+
+		frontier := []int64{start.Id}
+		for hop := 0; hop < options.Hops && len(frontier) > 0; hop++ {
+			remaining := options.MaxNodes - len(builder.graph.Nodes)
+			result, err := tx.Run(fmt.Sprintf(`
+				MATCH (n)
+				WHERE id(n) IN $frontier
+				MATCH path = (n)-[:%[1]s]-(m)
+				WHERE id(m) IN $visited OR any(label IN labels(m) WHERE label IN $labels)
+				WITH m, collect(path) AS paths, id(m) IN $visited AS visited
+				WITH m, paths, visited, size((m)-[:%[1]s]-()) AS degree
+				ORDER BY visited DESC, degree DESC, id(m)
+				LIMIT $limit
+				RETURN paths, visited, degree`, types),
+				map[string]interface{}{
+					"frontier": frontier,
+					"visited":  builder.nodeIds(),
+					"labels":   options.Labels,
+					"limit":    len(builder.graph.Nodes) + remaining + 1,
+				})
+			if err != nil {
+				return nil, err
+			}
+			records, err := collect(ctx, result)
+			if err != nil {
+				return nil, err
+			}
+			frontier = nil
+			for _, record := range records {
+				var row struct {
+					Visited bool  `neo4j:"visited"`
+					Degree  int64 `neo4j:"degree"`
+				}
+				if err := mapping.DecodeRecord(record, &row); err != nil {
+					return nil, err
+				}
+				value, _ := record.Get("paths")
+				paths, err := neighbourPaths(value)
+				if err != nil {
+					return nil, err
+				}
+				if !row.Visited {
+					if remaining == 0 {
+						builder.graph.Truncated = true
+						continue
+					}
+					remaining--
+					neighbour := paths[0].Nodes[len(paths[0].Nodes)-1]
+					builder.addNode(neighbour, row.Degree)
+					frontier = append(frontier, neighbour.Id)
+				}
+				for _, path := range paths {
+					builder.addPath(path)
+				}
+			}
+		}
+
+		result, err = tx.Run(fmt.Sprintf(`
+			MATCH (a)-[r:%s]->(b)
+			WHERE id(a) IN $ids AND id(b) IN $ids
+			RETURN r`, types),
+			map[string]interface{}{"ids": builder.nodeIds()})
+		if err != nil {
+			return nil, err
+		}
+		records, err = collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			value, _ := record.Get("r")
+			relationship, ok := value.(neo4j.Relationship)
+			if !ok {
+				return nil, fmt.Errorf("expected a neo4j.Relationship, got %T", value)
+			}
+			builder.addRelationship(relationship)
+		}
+		return builder.graph, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Graph), nil
+}
+
+// neighbourPaths converts the collected single relationship paths leading to
+// a neighbour
+func neighbourPaths(value interface{}) ([]neo4j.Path, error) {
+	values, ok := value.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("expected a non-empty list of neo4j.Path, got %T", value)
+	}
+	paths := make([]neo4j.Path, len(values))
+	for i, value := range values {
+		path, ok := value.(neo4j.Path)
+		if !ok {
+			return nil, fmt.Errorf("expected a neo4j.Path, got %T", value)
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// sortedKeys returns the keys of values in alphabetical order
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// graphBuilder accumulates nodes and edges, ignoring duplicates and the
+// properties that are not exposed
+type graphBuilder struct {
+	graph *Graph
+	nodes map[int64]bool
+	edges map[int64]bool
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{
+		graph: &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}},
+		nodes: map[int64]bool{},
+		edges: map[int64]bool{},
+	}
+}
+
+func (gb *graphBuilder) nodeIds() []int64 {
+	ids := make([]int64, len(gb.graph.Nodes))
+	for i, node := range gb.graph.Nodes {
+		ids[i] = node.Id
+	}
+	return ids
+}
+
+func (gb *graphBuilder) addNode(node neo4j.Node, degree int64) {
+	if gb.nodes[node.Id] {
+		return
+	}
+	gb.nodes[node.Id] = true
+	properties := map[string]interface{}{}
+	for _, label := range node.Labels {
+		for _, key := range graphLabels[label] {
+			if value, found := node.Props[key]; found {
+				properties[key] = value
+			}
+		}
+	}
+	gb.graph.Nodes = append(gb.graph.Nodes, GraphNode{
+		Id:         node.Id,
+		Labels:     node.Labels,
+		Properties: properties,
+		Degree:     degree,
+	})
+}
+
+// addRelationship adds the relationship when both its ends were added
+func (gb *graphBuilder) addRelationship(relationship neo4j.Relationship) {
+	if gb.edges[relationship.Id] || !gb.nodes[relationship.StartId] || !gb.nodes[relationship.EndId] {
+		return
+	}
+	gb.edges[relationship.Id] = true
+	var properties map[string]interface{}
+	for _, key := range graphRelationshipTypes[relationship.Type] {
+		if value, found := relationship.Props[key]; found {
+			if properties == nil {
+				properties = map[string]interface{}{}
+			}
+			properties[key] = value
+		}
+	}
+	gb.graph.Edges = append(gb.graph.Edges, GraphEdge{
+		Id:         relationship.Id,
+		Type:       relationship.Type,
+		Source:     relationship.StartId,
+		Target:     relationship.EndId,
+		Properties: properties,
+	})
+}
+
+// addPath adds the relationships of path whose ends were added
+func (gb *graphBuilder) addPath(path neo4j.Path) {
+	for _, relationship := range path.Relationships {
+		gb.addRelationship(relationship)
+	}
+}
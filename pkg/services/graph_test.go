@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func TestGraphBuilder(t *testing.T) {
+	heat := neo4j.Node{Id: 1, Labels: []string{"Movie"}, Props: map[string]interface{}{"tmdbId": "949", "title": "Heat", "plot": "A group of robbers"}}
+	pacino := neo4j.Node{Id: 2, Labels: []string{"Person", "Actor"}, Props: map[string]interface{}{"tmdbId": "1158", "name": "Al Pacino", "bio": "..."}}
+	actedIn := neo4j.Relationship{Id: 10, StartId: 2, EndId: 1, Type: ActedIn, Props: map[string]interface{}{"role": "Vincent Hanna"}}
+	inGenre := neo4j.Relationship{Id: 11, StartId: 1, EndId: 3, Type: InGenre}
+
+	builder := newGraphBuilder()
+	builder.addNode(heat, 12)
+	builder.addNode(pacino, 40)
+	builder.addNode(heat, 12)
+	builder.addPath(neo4j.Path{Nodes: []neo4j.Node{heat, pacino}, Relationships: []neo4j.Relationship{actedIn}})
+	builder.addRelationship(actedIn)
+	builder.addRelationship(inGenre)
+
+	graph := builder.graph
+	if len(graph.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %v", graph.Nodes)
+	}
+	if _, found := graph.Nodes[0].Properties["plot"]; found || graph.Nodes[0].Properties["title"] != "Heat" {
+		t.Errorf("expected only the key properties of the movie, got %v", graph.Nodes[0].Properties)
+	}
+	if _, found := graph.Nodes[1].Properties["bio"]; found || graph.Nodes[1].Degree != 40 {
+		t.Errorf("unexpected person node %+v", graph.Nodes[1])
+	}
+	if len(graph.Edges) != 1 {
+		t.Fatalf("expected the edge to the missing genre to be left out, got %v", graph.Edges)
+	}
+	if edge := graph.Edges[0]; edge.Source != 2 || edge.Target != 1 || edge.Properties["role"] != "Vincent Hanna" {
+		t.Errorf("unexpected edge %+v", edge)
+	}
+}
+
+func TestNeighbourhoodOptionsValidate(outer *testing.T) {
+	outer.Run("defaults", func(t *testing.T) {
+		options := NeighbourhoodOptions{}
+		if err := options.validate(); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if options.Hops != DefaultNeighbourhoodHops || options.MaxNodes != DefaultNeighbourhoodNodes ||
+			len(options.Labels) != 3 || len(options.RelationshipTypes) != 3 {
+			t.Fatalf("expected defaults, got %+v", options)
+		}
+	})
+
+	outer.Run("users are never exposed", func(t *testing.T) {
+		options := NeighbourhoodOptions{Labels: []string{"User"}, RelationshipTypes: []string{"RATED"}, Hops: 4, MaxNodes: -1}
+		domainError, ok := options.validate().(*DomainError)
+		if !ok || domainError.StatusCode() != 400 {
+			t.Fatalf("expected a 400 error, got %v", domainError)
+		}
+		for _, field := range []string{"labels", "types", "hops", "limit"} {
+			if domainError.details[field] == nil {
+				t.Errorf("expected an error on %s, got %v", field, domainError.details)
+			}
+		}
+	})
+}
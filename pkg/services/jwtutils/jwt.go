@@ -7,12 +7,15 @@ import (
 	"time"
 )
 
-func Sign(subject string, claims map[string]interface{}, secret string) (string, error) {
+// Sign issues a token identified by id (its `jti` claim), expiring after ttl.
+// The custom claims are nested under the subject.
+func Sign(subject, id string, ttl time.Duration, claims map[string]interface{}, secret string) (string, error) {
 	now := time.Now()
 	registeredClaims := jwt.RegisteredClaims{
 		Issuer:    "auth0",
 		Subject:   subject,
-		ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)),
+		ID:        id,
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		IssuedAt:  jwt.NewNumericDate(now),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, customClaims{
@@ -0,0 +1,127 @@
+package services_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/services"
+)
+
+// TestModelsMatchFixtures checks that the domain models serialize to the same
+// JSON shapes as the fixtures the frontend was built against, whether they are
+// decoded from JSON or converted from driver values.
+func TestModelsMatchFixtures(outer *testing.T) {
+	testCases := []struct {
+		fixture string
+		decode  func([]byte) (interface{}, error)
+		convert func(map[string]interface{}) (interface{}, error)
+	}{
+		{"goodfellas.json", decodeInto(&services.Movie{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.MovieFromMap(values)
+		}},
+		{"popular.json", decodeInto(&[]services.Movie{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.MovieFromMap(values)
+		}},
+		{"latest.json", decodeInto(&[]services.Movie{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.MovieFromMap(values)
+		}},
+		{"roles.json", decodeInto(&[]services.Movie{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.MovieFromMap(values)
+		}},
+		{"similar.json", decodeInto(&[]services.Movie{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.MovieFromMap(values)
+		}},
+		{"genres.json", decodeInto(&[]services.Genre{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.GenreFromMap(values)
+		}},
+		{"pacino.json", decodeInto(&services.Person{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.PersonFromMap(values)
+		}},
+		{"people.json", decodeInto(&[]services.Person{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.PersonFromMap(values)
+		}},
+		{"ratings.json", decodeInto(&[]services.Rating{}), func(values map[string]interface{}) (interface{}, error) {
+			return services.RatingFromMap(values)
+		}},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.fixture, func(t *testing.T) {
+			content, err := ioutil.ReadFile(filepath.Join("../../fixtures", testCase.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := unmarshal(t, content)
+
+			decoded, err := testCase.decode(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameJson(t, expected, decoded)
+
+			var converted interface{}
+			switch values := expected.(type) {
+			case map[string]interface{}:
+				converted, err = testCase.convert(values)
+			case []interface{}:
+				results := make([]interface{}, len(values))
+				for i, value := range values {
+					if results[i], err = testCase.convert(value.(map[string]interface{})); err != nil {
+						break
+					}
+				}
+				converted = results
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameJson(t, expected, converted)
+		})
+	}
+
+	outer.Run("user.json", func(t *testing.T) {
+		content, err := ioutil.ReadFile("../../fixtures/user.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var user services.User
+		if err := json.Unmarshal(content, &user); err != nil {
+			t.Fatal(err)
+		}
+		expected := unmarshal(t, content).(map[string]interface{})
+		// passwords are never sent back to clients
+		delete(expected, "password")
+		assertSameJson(t, expected, user)
+	})
+}
+
+func decodeInto(target interface{}) func([]byte) (interface{}, error) {
+	return func(content []byte) (interface{}, error) {
+		err := json.Unmarshal(content, target)
+		return target, err
+	}
+}
+
+func unmarshal(t *testing.T, content []byte) interface{} {
+	t.Helper()
+	var result interface{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func assertSameJson(t *testing.T, expected interface{}, value interface{}) {
+	t.Helper()
+	content, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := unmarshal(t, content); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected JSON %v, got %v", expected, actual)
+	}
+}
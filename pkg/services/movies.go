@@ -1,37 +1,93 @@
 package services
 
 import (
-	"math/rand"
+	"context"
+	"fmt"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
 
-type Movie = map[string]interface{}
+type Movie struct {
+	TmdbId      string         `json:"tmdbId"`
+	ImdbId      string         `json:"imdbId,omitempty"`
+	MovieId     string         `json:"movieId,omitempty"`
+	Id          string         `json:"id,omitempty"`
+	Title       string         `json:"title,omitempty"`
+	Plot        string         `json:"plot,omitempty"`
+	Poster      string         `json:"poster,omitempty"`
+	Url         string         `json:"url,omitempty"`
+	Released    string         `json:"released,omitempty"`
+	Year        *int64         `json:"year,omitempty"`
+	Runtime     *int64         `json:"runtime,omitempty"`
+	ImdbRating  *float64       `json:"imdbRating,omitempty"`
+	ImdbVotes   *int64         `json:"imdbVotes,omitempty"`
+	Budget      *int64         `json:"budget,omitempty"`
+	Revenue     *int64         `json:"revenue,omitempty"`
+	Version     *int64         `json:"version,omitempty"`
+	Languages   []string       `json:"languages,omitempty"`
+	Countries   []string       `json:"countries,omitempty"`
+	Actors      []Person       `json:"actors,omitempty"`
+	Directors   []Person       `json:"directors,omitempty"`
+	Genres      []Genre        `json:"genres,omitempty"`
+	Role        string         `json:"role,omitempty"`
+	RatingCount *int64         `json:"ratingCount,omitempty"`
+	Ratings     []LegacyRating `json:"ratings,omitempty"`
+	Rating      *float64       `json:"rating,omitempty"`
+	Favorite    *bool          `json:"favorite,omitempty"`
+	Score       *float64       `json:"score,omitempty"`
+	// Similarity explains the score of movies returned by FindAllBySimilarity
+	Similarity *Similarity `json:"similarity,omitempty"`
+	// Aggregate summarizes the ratings of the movie, after rating changes
+	Aggregate *RatingAggregate `json:"aggregate,omitempty"`
+	// Trending is the time-decayed popularity of the movie
+	Trending *float64 `json:"trending,omitempty"`
+}
+
+// MovieFromNode converts a Movie node
+func MovieFromNode(node neo4j.Node) (Movie, error) {
+	return MovieFromMap(node.Props)
+}
+
+// MovieFromMap converts the result of a Movie map projection
+func MovieFromMap(values map[string]interface{}) (Movie, error) {
+	var movie Movie
+	err := mapping.Decode(values, &movie)
+	return movie, err
+}
 
 type MovieService interface {
-	FindAll(userId string, page *paging.Paging) ([]Movie, error)
+	FindAll(ctx context.Context, userId string, page *paging.Paging) ([]Movie, error)
+
+	FindAllByGenre(ctx context.Context, genre, userId string, page *paging.Paging) ([]Movie, error)
 
-	FindAllByGenre(genre, userId string, page *paging.Paging) ([]Movie, error)
+	// FindAllByGenres returns the movies in all (AllGenres) or any (AnyGenre)
+	// of the genres
+	FindAllByGenres(ctx context.Context, genres []string, match string, userId string, page *paging.Paging) ([]Movie, error)
 
-	FindAllByActorId(actorId string, userId string, page *paging.Paging) ([]Movie, error)
+	FindAllByActorId(ctx context.Context, actorId string, userId string, page *paging.Paging) ([]Movie, error)
 
-	FindAllByDirectorId(actorId string, userId string, page *paging.Paging) ([]Movie, error)
+	FindAllByDirectorId(ctx context.Context, directorId string, userId string, page *paging.Paging) ([]Movie, error)
 
-	FindOneById(id string, userId string) (Movie, error)
+	FindOneById(ctx context.Context, id string, userId string) (*Movie, error)
 
-	FindAllBySimilarity(id string, userId string, page *paging.Paging) ([]Movie, error)
+	FindAllBySimilarity(ctx context.Context, id string, userId string, page *paging.Paging) ([]Movie, error)
 }
 
 type neo4jMovieService struct {
-	loader *fixtures.FixtureLoader
-	driver neo4j.Driver
+	loader     *fixtures.FixtureLoader
+	driver     neo4j.Driver
+	indexes    *FullTextIndexes
+	similarity SimilarityWeights
+	scale      RatingScale
 }
 
-func NewMovieService(loader *fixtures.FixtureLoader, driver neo4j.Driver) MovieService {
-	return &neo4jMovieService{loader: loader, driver: driver}
+func NewMovieService(loader *fixtures.FixtureLoader, driver neo4j.Driver, indexes *FullTextIndexes, similarity SimilarityWeights, scale RatingScale) MovieService {
+	return &neo4jMovieService{loader: loader, driver: driver, indexes: indexes, similarity: similarity, scale: scale}
 }
 
 // FindAll should return a paginated list of movies ordered by the `sort`
@@ -41,18 +97,8 @@ func NewMovieService(loader *fixtures.FixtureLoader, driver neo4j.Driver) MovieS
 // If a userId value is supplied, a `favorite` boolean property should be returned to
 // signify whether the user has added the movie to their "My Favorites" list.
 // tag::all[]
-func (ms *neo4jMovieService) FindAll(userId string, page *paging.Paging) (_ []Movie, err error) {
-	// TODO: Open an Session
-	// TODO: Execute a query in a new Read Transaction
-	// TODO: Get a list of Movies from the Result
-	// TODO: Close the session
-
-	popularMovies, err := ms.loader.ReadArray("fixtures/popular.json")
-	if err != nil {
-		return nil, err
-	}
-
-	return fixtures.Slice(popularMovies, page.Skip(), page.Limit()), err
+func (ms *neo4jMovieService) FindAll(ctx context.Context, userId string, page *paging.Paging) (_ []Movie, err error) {
+	return ms.findAllMovies(ctx, userId, "(m:Movie)", nil, page)
 }
 
 // end::all[]
@@ -69,19 +115,35 @@ func (ms *neo4jMovieService) FindAll(userId string, page *paging.Paging) (_ []Mo
 // signify whether the user has added the movie to their "My Favorites" list.
 //
 // tag::getByGenre[]
-func (ms *neo4jMovieService) FindAllByGenre(genre string, userId string, page *paging.Paging) (_ []Movie, err error) {
-	// TODO: Get Movies in a Genre
-	// MATCH (m:Movie)-[:IN_GENRE]->(:Genre {name: $name})
+func (ms *neo4jMovieService) FindAllByGenre(ctx context.Context, genre string, userId string, page *paging.Paging) (_ []Movie, err error) {
+	return ms.FindAllByGenres(ctx, []string{genre}, AllGenres, userId, page)
+}
 
-	popularMovies, err := ms.loader.ReadArray("fixtures/popular.json")
-	if err != nil {
+// end::getByGenre[]
+
+// FindAllByGenres returns a paginated list of the movies in all of the genres
+// when match is AllGenres, e.g. Action AND Comedy, or in any of them when
+// match is AnyGenre.
+// Sorting, paging and the favorite flag work as for FindAllByGenre.
+func (ms *neo4jMovieService) FindAllByGenres(ctx context.Context, genres []string, match string, userId string, page *paging.Paging) (_ []Movie, err error) {
+	if err := validateGenreMatch(genres, match); err != nil {
 		return nil, err
 	}
-	return fixtures.Slice(popularMovies, page.Skip(), page.Limit()), nil
+	params := map[string]interface{}{"names": genres}
+	if match == AnyGenre {
+		return ms.findAllMovies(ctx, userId,
+			"(m:Movie)-[:IN_GENRE]->(g:Genre) WHERE g.name IN $names",
+			params,
+			page)
+	}
+	// anchored on the first genre, the other ones are checked per movie
+	params["name"] = genres[0]
+	return ms.findAllMovies(ctx, userId,
+		"(m:Movie)-[:IN_GENRE]->(:Genre {name: $name}) WHERE all(name IN $names WHERE exists((m)-[:IN_GENRE]->(:Genre {name: name})))",
+		params,
+		page)
 }
 
-// end::getByGenre[]
-
 // FindAllByActorId should return a paginated list of movies that have an ACTED_IN relationship
 // to a Person with the id supplied
 //
@@ -93,15 +155,11 @@ func (ms *neo4jMovieService) FindAllByGenre(genre string, userId string, page *p
 // If a userId value is supplied, a `favorite` boolean property should be returned to
 // signify whether the user has added the movie to their "My Favorites" list.
 // tag::getForActor[]
-func (ms *neo4jMovieService) FindAllByActorId(actorId string, userId string, page *paging.Paging) (_ []Movie, err error) {
-	// TODO: Get Movies acted in by a Person
-	// MATCH (:Person {tmdbId: $id})-[:ACTED_IN]->(m:Movie)
-
-	roles, err := ms.loader.ReadArray("fixtures/roles.json")
-	if err != nil {
-		return nil, err
-	}
-	return fixtures.Slice(roles, page.Skip(), page.Limit()), nil
+func (ms *neo4jMovieService) FindAllByActorId(ctx context.Context, actorId string, userId string, page *paging.Paging) (_ []Movie, err error) {
+	return ms.findAllMovies(ctx, userId,
+		"(:Person {tmdbId: $id})-[:ACTED_IN]->(m:Movie)",
+		map[string]interface{}{"id": actorId},
+		page)
 }
 
 // end::getForActor[]
@@ -117,15 +175,11 @@ func (ms *neo4jMovieService) FindAllByActorId(actorId string, userId string, pag
 // If a userId value is supplied, a `favorite` boolean property should be returned to
 // signify whether the user has added the movie to their "My Favorites" list.
 // tag::getForDirector[]
-func (ms *neo4jMovieService) FindAllByDirectorId(actorId string, userId string, page *paging.Paging) (_ []Movie, err error) {
-	// TODO: Get Movies directed by a Person
-	// MATCH (:Person {tmdbId: $id})-[:DIRECTED]->(m:Movie)
-
-	popularMovies, err := ms.loader.ReadArray("fixtures/popular.json")
-	if err != nil {
-		return nil, err
-	}
-	return fixtures.Slice(popularMovies, page.Skip(), page.Limit()), nil
+func (ms *neo4jMovieService) FindAllByDirectorId(ctx context.Context, directorId string, userId string, page *paging.Paging) (_ []Movie, err error) {
+	return ms.findAllMovies(ctx, userId,
+		"(:Person {tmdbId: $id})-[:DIRECTED]->(m:Movie)",
+		map[string]interface{}{"id": directorId},
+		page)
 }
 
 // end::getForDirector[]
@@ -138,18 +192,28 @@ func (ms *neo4jMovieService) FindAllByDirectorId(actorId string, userId string,
 // If a userId value is supplied, a `favorite` boolean property should be returned to
 // signify whether the user has added the movie to their "My Favorites" list.
 // tag::findById[]
-func (ms *neo4jMovieService) FindOneById(id string, userId string) (_ Movie, err error) {
+func (ms *neo4jMovieService) FindOneById(ctx context.Context, id string, userId string) (_ *Movie, err error) {
 	// TODO: Find a movie by its ID
 	// MATCH (m:Movie {tmdbId: $id})
 
-	return ms.loader.ReadObject("fixtures/goodfellas.json")
+	movies := make([]Movie, 1)
+	if err := ms.loader.Read("fixtures/goodfellas.json", &movies[0]); err != nil {
+		return nil, err
+	}
+	if err := enrichMoviesInSession(ctx, ms.driver, userId, movies); err != nil {
+		return nil, err
+	}
+	return &movies[0], nil
 }
 
 // end::findById[]
 
 // FindAllBySimilarity should return a paginated list of similar movies to the Movie with the
 // id supplied.  This similarity is calculated by finding movies that have many first
-// degree connections in common: Actors, Directors and Genres.
+// degree connections in common: Actors, Directors and Genres, as well as users
+// who rated both movies highly.
+// Each kind of connection is weighted by the SimilarityWeights of the service,
+// and every result explains its `score` with a `similarity` breakdown.
 //
 // Results should be ordered by the `sort` parameter, and in the direction specified
 // in the `order` parameter.
@@ -159,26 +223,116 @@ func (ms *neo4jMovieService) FindOneById(id string, userId string) (_ Movie, err
 // If a userId value is supplied, a `favorite` boolean property should be returned to
 // signify whether the user has added the movie to their "My Favorites" list.
 // tag::getSimilarMovies[]
-func (ms *neo4jMovieService) FindAllBySimilarity(id string, userId string, page *paging.Paging) (_ []Movie, err error) {
-	// TODO: Get similar movies based on genres or ratings
-	popularMovies, err := ms.loader.ReadArray("fixtures/popular.json")
+func (ms *neo4jMovieService) FindAllBySimilarity(ctx context.Context, id string, userId string, page *paging.Paging) (_ []Movie, err error) {
+	session := ms.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		movies, err := findSimilarMovies(ctx, tx, id, ms.similarity, ms.scale, page)
+		if err != nil {
+			return nil, err
+		}
+		return movies, enrichMovies(ctx, tx, userId, movies)
+	})
 	if err != nil {
 		return nil, err
 	}
-	results := fixtures.Slice(popularMovies, page.Skip(), page.Limit())
-	for _, movie := range results {
-		movie["score"] = rand.Intn(100)
-	}
-	return results, nil
+	return results.([]Movie), nil
 }
 
 // end::getSimilarMovies[]
 
-// getUserFavorites should return a list of tmdbId properties for the movies that
-// the user has added to their 'My Favorites' list.
-// tag::getUserFavorites[]
-func getUserFavorites(tx neo4j.Transaction, userId string) ([]string, error) {
-	return nil, nil
+// findAllMovies returns a page of the distinct movies bound to `m` by the
+// pattern, which may be followed by a WHERE clause, shaped after the projection of the page, with the favorite flag and
+// rating of the user.
+// When the page carries a `q` value, only the movies matching it through a
+// full-text search are returned, and `sort=score` orders them by relevance.
+// `sort=trending` orders the movies with a trending score, most trending
+// first unless the order is given.
+func (ms *neo4jMovieService) findAllMovies(ctx context.Context, userId string, pattern string, params map[string]interface{}, page *paging.Paging) (_ []Movie, err error) {
+	luceneQuery, _ := fullTextQuery(page.Query(), SearchOptions{Prefix: true})
+	if luceneQuery != "" {
+		if err := ms.indexes.ensure(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	session := ms.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		parameters := map[string]interface{}{
+			"skip":  page.Skip(),
+			"limit": page.Limit(),
+		}
+		for key, value := range params {
+			parameters[key] = value
+		}
+		var cypher string
+		if luceneQuery == "" {
+			ordering := orderBy("m", page)
+			if page.Sort() == TrendingSort && page.Order() == "" {
+				ordering = fmt.Sprintf("ORDER BY %s DESC", property("m", TrendingSort))
+			}
+			cypher = fmt.Sprintf(`
+				MATCH %s
+				WITH DISTINCT m
+				WHERE %s IS NOT NULL
+				WITH m
+				%s`,
+				pattern, property("m", page.Sort()), ordering)
+		} else {
+			parameters["query"] = luceneQuery
+			parameters["castWeight"] = castMatchWeight
+			parameters["maxCastHits"] = maxCastHits
+			ordering := "ORDER BY score DESC"
+			if page.Sort() != "score" {
+				ordering = fmt.Sprintf("WHERE %s IS NOT NULL\n%s", property("m", page.Sort()), orderBy("m", page))
+			}
+			cypher = fmt.Sprintf(`
+				%s
+				MATCH %s
+				WITH DISTINCT m, score
+				%s`,
+				movieSearchSubquery, pattern, ordering)
+		}
+		result, err := tx.Run(fmt.Sprintf(`
+			%s
+			SKIP $skip
+			LIMIT $limit
+			RETURN %s AS movie`,
+			cypher, movieProjection("m", page.Projection())),
+			parameters)
+		if err != nil {
+			return nil, err
+		}
+		movies, err := collectMovies(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		return movies, enrichMovies(ctx, tx, userId, movies)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]Movie), nil
 }
 
-// end::getUserFavorites[]
+// collectMovies buffers the `movie` column of each remaining record of result
+func collectMovies(ctx context.Context, result neo4j.Result) ([]Movie, error) {
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	movies := make([]Movie, len(records))
+	for i, record := range records {
+		if err := mapping.DecodeColumn(record, "movie", &movies[i]); err != nil {
+			return nil, err
+		}
+	}
+	return movies, nil
+}
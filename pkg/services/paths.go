@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// DefaultPathDepth is the usual "six degrees of separation"
+	DefaultPathDepth    = 6
+	MaxPathDepth        = 10
+	MaxPathAlternatives = 25
+
+	PersonStep = "person"
+	MovieStep  = "movie"
+)
+
+// PathOptions restricts the search of a path between two people.
+// RelationshipTypes default to ACTED_IN and DIRECTED, MaxDepth counts the
+// people met along the way and defaults to DefaultPathDepth.
+// Up to Alternatives other paths of the same length are returned.
+type PathOptions struct {
+	RelationshipTypes []string
+	MaxDepth          int
+	Alternatives      int
+}
+
+// PathStep is a person or a movie along a path.
+// Relationship and Role describe how the step is joined to the previous one.
+type PathStep struct {
+	Type         string  `json:"type"`
+	Person       *Person `json:"person,omitempty"`
+	Movie        *Movie  `json:"movie,omitempty"`
+	Relationship string  `json:"relationship,omitempty"`
+	Role         string  `json:"role,omitempty"`
+}
+
+// PeoplePath is a shortest chain of alternating people and movies, with
+// alternative chains of the same length
+type PeoplePath struct {
+	Degrees      int          `json:"degrees"`
+	Steps        []PathStep   `json:"steps"`
+	Alternatives [][]PathStep `json:"alternatives,omitempty"`
+}
+
+var pathRelationshipTypes = []string{ActedIn, Directed}
+
+// validate checks the options and fills in their defaults
+func (po *PathOptions) validate() error {
+	var v validation
+	if len(po.RelationshipTypes) == 0 {
+		po.RelationshipTypes = pathRelationshipTypes
+	}
+	for _, relationshipType := range po.RelationshipTypes {
+		if relationshipType != ActedIn && relationshipType != Directed {
+			v.fail("types", fmt.Sprintf("must be among %s", strings.Join(pathRelationshipTypes, ", ")))
+		}
+	}
+	if po.MaxDepth == 0 {
+		po.MaxDepth = DefaultPathDepth
+	}
+	if po.MaxDepth < 1 || po.MaxDepth > MaxPathDepth {
+		v.fail("maxDepth", fmt.Sprintf("must be between 1 and %d", MaxPathDepth))
+	}
+	if po.Alternatives < 0 || po.Alternatives > MaxPathAlternatives {
+		v.fail("alternatives", fmt.Sprintf("must be between 0 and %d", MaxPathAlternatives))
+	}
+	if len(v.details) == 0 {
+		return nil
+	}
+	return NewDomainError(400, "Invalid path options", v.details)
+}
+
+// FindPathBetween returns the shortest chain of credits joining two people,
+// as alternating person and movie steps.
+// If either person cannot be found, or no path exists within the maximum
+// depth, a 404 DomainError is returned.
+func (ps *neo4jPeopleService) FindPathBetween(ctx context.Context, fromId, toId string, options PathOptions) (_ *PeoplePath, err error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	if fromId == toId {
+		return nil, NewDomainError(400, "A path joins two different people", map[string]interface{}{"id": fromId})
+	}
+
+	session := ps.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			OPTIONAL MATCH (from:Person {tmdbId: $from})
+			OPTIONAL MATCH (to:Person {tmdbId: $to})
+			RETURN from IS NOT NULL AND to IS NOT NULL AS found`,
+			map[string]interface{}{"from": fromId, "to": toId})
+		if err != nil {
+			return nil, err
+		}
+		record, err := result.Single()
+		if err != nil {
+			return nil, err
+		}
+		if found, _ := record.Get("found"); found != true {
+			return nil, NewDomainError(404, "Person not found", map[string]interface{}{"from": fromId, "to": toId})
+		}
+
+		// every degree of separation goes through a movie, hence two
+		// relationships
+		result, err = tx.Run(fmt.Sprintf(`
+			MATCH (from:Person {tmdbId: $from}), (to:Person {tmdbId: $to})
+			MATCH path = allShortestPaths((from)-[:%s*..%d]-(to))
+			RETURN path
+			LIMIT $limit`,
+			strings.Join(options.RelationshipTypes, "|"), 2*options.MaxDepth),
+			map[string]interface{}{"from": fromId, "to": toId, "limit": options.Alternatives + 1})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(404, "No path found", map[string]interface{}{
+				"from":     fromId,
+				"to":       toId,
+				"maxDepth": options.MaxDepth,
+			})
+		}
+		var peoplePath PeoplePath
+		for i, record := range records {
+			value, _ := record.Get("path")
+			path, ok := value.(neo4j.Path)
+			if !ok {
+				return nil, fmt.Errorf("expected a neo4j.Path, got %T", value)
+			}
+			steps, err := pathSteps(path)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				peoplePath.Degrees = len(path.Relationships) / 2
+				peoplePath.Steps = steps
+			} else {
+				peoplePath.Alternatives = append(peoplePath.Alternatives, steps)
+			}
+		}
+		return &peoplePath, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*PeoplePath), nil
+}
+
+// pathSteps converts the nodes of path to steps, each joined to the previous
+// one by the relationship at the same position
+func pathSteps(path neo4j.Path) ([]PathStep, error) {
+	steps := make([]PathStep, len(path.Nodes))
+	for i, node := range path.Nodes {
+		step, err := pathStep(node)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && i <= len(path.Relationships) {
+			relationship := path.Relationships[i-1]
+			step.Relationship = relationship.Type
+			step.Role, _ = relationship.Props["role"].(string)
+		}
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+func pathStep(node neo4j.Node) (PathStep, error) {
+	for _, label := range node.Labels {
+		switch label {
+		case "Person":
+			person, err := PersonFromNode(node)
+			return PathStep{Type: PersonStep, Person: &person}, err
+		case "Movie":
+			movie, err := MovieFromNode(node)
+			return PathStep{Type: MovieStep, Movie: &movie}, err
+		}
+	}
+	return PathStep{}, fmt.Errorf("unexpected node with labels %v in path", node.Labels)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func TestPathSteps(t *testing.T) {
+	pacino := neo4j.Node{Id: 1, Labels: []string{"Person"}, Props: map[string]interface{}{"tmdbId": "1158", "name": "Al Pacino"}}
+	heat := neo4j.Node{Id: 2, Labels: []string{"Movie"}, Props: map[string]interface{}{"tmdbId": "949", "title": "Heat"}}
+	deNiro := neo4j.Node{Id: 3, Labels: []string{"Person"}, Props: map[string]interface{}{"tmdbId": "380", "name": "Robert De Niro"}}
+	path := neo4j.Path{
+		Nodes: []neo4j.Node{pacino, heat, deNiro},
+		Relationships: []neo4j.Relationship{
+			{StartId: 1, EndId: 2, Type: ActedIn, Props: map[string]interface{}{"role": "Vincent Hanna"}},
+			{StartId: 3, EndId: 2, Type: ActedIn, Props: map[string]interface{}{"role": "Neil McCauley"}},
+		},
+	}
+
+	steps, err := pathSteps(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(steps))
+	}
+	if steps[0].Type != PersonStep || steps[0].Person.Name != "Al Pacino" || steps[0].Relationship != "" {
+		t.Errorf("unexpected first step %+v", steps[0])
+	}
+	if steps[1].Type != MovieStep || steps[1].Movie.Title != "Heat" || steps[1].Role != "Vincent Hanna" {
+		t.Errorf("unexpected second step %+v", steps[1])
+	}
+	if steps[2].Person.Name != "Robert De Niro" || steps[2].Relationship != ActedIn || steps[2].Role != "Neil McCauley" {
+		t.Errorf("unexpected last step %+v", steps[2])
+	}
+
+	if _, err := pathSteps(neo4j.Path{Nodes: []neo4j.Node{{Labels: []string{"Genre"}}}}); err == nil {
+		t.Errorf("expected an error for genre nodes")
+	}
+}
+
+func TestPathOptionsValidate(outer *testing.T) {
+	outer.Run("defaults", func(t *testing.T) {
+		options := PathOptions{}
+		if err := options.validate(); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if options.MaxDepth != DefaultPathDepth || len(options.RelationshipTypes) != 2 {
+			t.Fatalf("expected defaults, got %+v", options)
+		}
+	})
+
+	outer.Run("invalid options", func(t *testing.T) {
+		options := PathOptions{RelationshipTypes: []string{InGenre}, MaxDepth: MaxPathDepth + 1, Alternatives: -1}
+		domainError, ok := options.validate().(*DomainError)
+		if !ok || domainError.StatusCode() != 400 {
+			t.Fatalf("expected a 400 error, got %v", domainError)
+		}
+		for _, field := range []string{"types", "maxDepth", "alternatives"} {
+			if domainError.details[field] == nil {
+				t.Errorf("expected an error on %s, got %v", field, domainError.details)
+			}
+		}
+	})
+}
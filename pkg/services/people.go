@@ -1,20 +1,53 @@
 package services
 
 import (
+	"context"
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
 
-type Person = map[string]interface{}
+type Person struct {
+	TmdbId        string `json:"tmdbId"`
+	Id            string `json:"id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Born          string `json:"born,omitempty"`
+	Died          string `json:"died,omitempty"`
+	BornIn        string `json:"bornIn,omitempty"`
+	Bio           string `json:"bio,omitempty"`
+	Poster        string `json:"poster,omitempty"`
+	Url           string `json:"url,omitempty"`
+	ActedCount    *int64 `json:"actedCount,omitempty"`
+	DirectedCount *int64 `json:"directedCount,omitempty"`
+	Version       *int64 `json:"version,omitempty"`
+}
+
+// PersonFromNode converts a Person node
+func PersonFromNode(node neo4j.Node) (Person, error) {
+	return PersonFromMap(node.Props)
+}
+
+// PersonFromMap converts the result of a Person map projection
+func PersonFromMap(values map[string]interface{}) (Person, error) {
+	var person Person
+	err := mapping.Decode(values, &person)
+	return person, err
+}
 
 type PeopleService interface {
-	FindAll(page *paging.Paging) ([]Person, error)
+	FindAll(ctx context.Context, page *paging.Paging) ([]Person, error)
 
-	FindOneById(id string) (Person, error)
+	FindOneById(ctx context.Context, id string) (*Person, error)
 
-	FindAllBySimilarity(id string, page *paging.Paging) ([]Person, error)
+	FindAllBySimilarity(ctx context.Context, id string, page *paging.Paging) ([]Person, error)
+
+	// FindFilmographyById returns the credits and career stats of the person
+	FindFilmographyById(ctx context.Context, id string, groupBy string, userId string) (*Filmography, error)
+
+	// FindPathBetween returns the shortest chain of credits joining two people
+	FindPathBetween(ctx context.Context, fromId, toId string, options PathOptions) (*PeoplePath, error)
 }
 
 type neo4jPeopleService struct {
@@ -33,14 +66,15 @@ func NewPeopleService(loader *fixtures.FixtureLoader, driver neo4j.Driver) Peopl
 // number passed as `limit`.  The `skip` variable should be used to skip a
 // certain number of rows.
 // tag::all[]
-func (ps *neo4jPeopleService) FindAll(page *paging.Paging) (_ []Person, err error) {
+func (ps *neo4jPeopleService) FindAll(ctx context.Context, page *paging.Paging) (_ []Person, err error) {
 	// TODO: Get a list of people from the database
 
-	people, err := ps.loader.ReadArray("fixtures/people.json")
-	if err != nil {
+	var people []Person
+	if err := ps.loader.Read("fixtures/people.json", &people); err != nil {
 		return nil, err
 	}
-	return fixtures.Slice(people, page.Skip(), page.Limit()), nil
+	start, end := fixtures.Bounds(len(people), page.Skip(), page.Limit())
+	return people[start:end], nil
 }
 
 //end::all[]
@@ -48,10 +82,14 @@ func (ps *neo4jPeopleService) FindAll(page *paging.Paging) (_ []Person, err erro
 // FindOneById finds a user by their ID.
 // If no user is found, an error should be thrown.
 // tag::findById[]
-func (ps *neo4jPeopleService) FindOneById(id string) (_ Person, err error) {
+func (ps *neo4jPeopleService) FindOneById(ctx context.Context, id string) (_ *Person, err error) {
 	// TODO: Find a user by their ID
 
-	return ps.loader.ReadObject("fixtures/pacino.json")
+	var person Person
+	if err := ps.loader.Read("fixtures/pacino.json", &person); err != nil {
+		return nil, err
+	}
+	return &person, nil
 }
 
 //end::findById[]
@@ -59,13 +97,14 @@ func (ps *neo4jPeopleService) FindOneById(id string) (_ Person, err error) {
 // FindAllBySimilarity gets a list of similar people to a Person, ordered by their similarity score
 // in descending order.
 // tag::getSimilarPeople[]
-func (ps *neo4jPeopleService) FindAllBySimilarity(id string, page *paging.Paging) (_ []Person, err error) {
+func (ps *neo4jPeopleService) FindAllBySimilarity(ctx context.Context, id string, page *paging.Paging) (_ []Person, err error) {
 	// TODO: Get a list of similar people to the person by their id
-	people, err := ps.loader.ReadArray("fixtures/people.json")
-	if err != nil {
+	var people []Person
+	if err := ps.loader.Read("fixtures/people.json", &people); err != nil {
 		return nil, err
 	}
-	return fixtures.Slice(people, page.Skip(), page.Limit()), nil
+	start, end := fixtures.Bounds(len(people), page.Skip(), page.Limit())
+	return people[start:end], nil
 }
 
 // end::getSimilarPeople[]
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+)
+
+// movieEmbeddings holds the pattern comprehensions computing the collections
+// that can be embedded in a movie payload. %[1]s is the movie variable.
+var movieEmbeddings = []struct {
+	name   string
+	cypher string
+}{
+	{"actors", "[ (%[1]s)<-[:ACTED_IN]-(a:Person) | a { .tmdbId, .name } ]"},
+	{"directors", "[ (%[1]s)<-[:DIRECTED]-(d:Person) | d { .tmdbId, .name } ]"},
+	{"genres", "[ (%[1]s)-[:IN_GENRE]->(g:Genre) | g { link: '/genres/' + g.name, .name } ]"},
+}
+
+// movieProjection compiles projection into a Cypher map projection of the
+// movie bound to variable.
+// Only the requested embedded collections are computed, and `tmdbId` is
+// always part of the result.
+func movieProjection(variable string, projection *paging.Projection) string {
+	entries := []string{".tmdbId"}
+	if fields := projection.Fields(); fields == nil {
+		entries = []string{".*"}
+	} else {
+		for _, field := range fields {
+			if field != "tmdbId" {
+				entries = append(entries, "."+field)
+			}
+		}
+	}
+	for _, embedding := range movieEmbeddings {
+		if projection.Includes(embedding.name) {
+			entries = append(entries, embedding.name+": "+fmt.Sprintf(embedding.cypher, variable))
+		}
+	}
+	return fmt.Sprintf("%s { %s }", variable, strings.Join(entries, ", "))
+}
+
+// orderBy returns a Cypher ORDER BY clause sorting variable by the sort
+// attribute and direction of page
+func orderBy(variable string, page *paging.Paging) string {
+	return fmt.Sprintf("ORDER BY %s %s", property(variable, page.Sort()), direction(page))
+}
+
+// property returns a Cypher property lookup, escaping name
+func property(variable, name string) string {
+	return fmt.Sprintf("%s.`%s`", variable, strings.ReplaceAll(name, "`", "``"))
+}
+
+func direction(page *paging.Paging) string {
+	if strings.EqualFold(page.Order(), "desc") {
+		return "DESC"
+	}
+	return "ASC"
+}
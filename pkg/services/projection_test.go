@@ -0,0 +1,36 @@
+package services
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+)
+
+func TestMovieProjection(outer *testing.T) {
+	project := func(query string) string {
+		request := httptest.NewRequest("GET", "/api/movies/?"+query, nil)
+		projection, err := paging.ParseProjection(request, paging.MovieProjectableAttributes())
+		if err != nil {
+			outer.Fatal(err)
+		}
+		return movieProjection("m", projection)
+	}
+
+	outer.Run("projects everything by default", func(t *testing.T) {
+		expected := "m { .*, " +
+			"actors: [ (m)<-[:ACTED_IN]-(a:Person) | a { .tmdbId, .name } ], " +
+			"directors: [ (m)<-[:DIRECTED]-(d:Person) | d { .tmdbId, .name } ], " +
+			"genres: [ (m)-[:IN_GENRE]->(g:Genre) | g { link: '/genres/' + g.name, .name } ] }"
+		if actual := project(""); actual != expected {
+			t.Fatalf("expected %s, got %s", expected, actual)
+		}
+	})
+
+	outer.Run("only computes requested collections", func(t *testing.T) {
+		expected := "m { .tmdbId, .title, .poster, .imdbRating }"
+		if actual := project("fields=title,poster,imdbRating&include="); actual != expected {
+			t.Fatalf("expected %s, got %s", expected, actual)
+		}
+	})
+}
@@ -1,27 +1,84 @@
 package services
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/neo4j-graphacademy/neoflix/pkg/fixtures"
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
 
 	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
 
-type Rating = map[string]interface{}
+type Rating struct {
+	Rating    float64      `json:"rating"`
+	Timestamp int64        `json:"timestamp"`
+	User      RatingAuthor `json:"user"`
+}
+
+// RatingAuthor is the public view of the User who rated a movie
+type RatingAuthor struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// LegacyRating is a rating embedded in the details of a movie, in the legacy
+// shape the frontend reads: the rating under `imdbRating` and the ID of its
+// author under `user.tmdbId`
+type LegacyRating struct {
+	Rating    float64            `json:"imdbRating"`
+	Timestamp int64              `json:"timestamp"`
+	User      LegacyRatingAuthor `json:"user"`
+}
+
+type LegacyRatingAuthor struct {
+	Id   string `json:"tmdbId"`
+	Name string `json:"name"`
+}
+
+// RatingFromMap converts the result of a RATED relationship map projection,
+// whose author is projected under `user`
+func RatingFromMap(values map[string]interface{}) (Rating, error) {
+	var rating Rating
+	err := mapping.Decode(values, &rating)
+	return rating, err
+}
+
+// MovieRating is a rating given by the current user, along with the movie
+type MovieRating struct {
+	Rating    float64 `json:"rating"`
+	Timestamp int64   `json:"timestamp"`
+	Movie     Movie   `json:"movie"`
+}
 
 type RatingService interface {
-	FindAllByMovieId(id string, page *paging.Paging) ([]Rating, error)
+	FindAllByMovieId(ctx context.Context, id string, page *paging.Paging) ([]Rating, error)
 
-	Save(rating int, movieId string, userId string) (Movie, error)
+	// FindAllByUserId returns a page of the ratings of the user, each with its
+	// movie
+	FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) ([]MovieRating, error)
+
+	// Save rejects ratings out of the RatingScale of the service with a 422
+	// DomainError
+	Save(ctx context.Context, rating float64, movieId string, userId string) (*Movie, error)
+
+	// Delete removes the rating of the user, along with its review
+	Delete(ctx context.Context, movieId string, userId string) (*Movie, error)
+
+	// FindAggregateByMovieId summarizes the ratings of the movie
+	FindAggregateByMovieId(ctx context.Context, movieId string) (*RatingAggregate, error)
 }
 
 type neo4jRatingService struct {
 	loader *fixtures.FixtureLoader
 	driver neo4j.Driver
+	scale  RatingScale
 }
 
-func NewRatingService(loader *fixtures.FixtureLoader, driver neo4j.Driver) RatingService {
-	return &neo4jRatingService{loader: loader, driver: driver}
+func NewRatingService(loader *fixtures.FixtureLoader, driver neo4j.Driver, scale RatingScale) RatingService {
+	return &neo4jRatingService{loader: loader, driver: driver, scale: scale}
 }
 
 // FindAllByMovieId returns a paginated list of reviews for a Movie.
@@ -31,23 +88,213 @@ func NewRatingService(loader *fixtures.FixtureLoader, driver neo4j.Driver) Ratin
 // Results should be limited to the number passed as `limit`.
 // The `skip` variable should be used to skip a certain number of rows.
 // tag::forMovie[]
-func (rs *neo4jRatingService) FindAllByMovieId(movieId string, page *paging.Paging) (_ []Rating, err error) {
-	return rs.loader.ReadArray("fixtures/ratings.json")
+func (rs *neo4jRatingService) FindAllByMovieId(ctx context.Context, movieId string, page *paging.Paging) (_ []Rating, err error) {
+	var ratings []Rating
+	err = rs.loader.Read("fixtures/ratings.json", &ratings)
+	return ratings, err
 }
 
 // end::forMovie[]
 
-// Save adds a relationship between a User and Movie with a `rating` property.
-// The `rating` parameter should be converted to a Neo4j Integer.
+// Save adds a relationship between a User and Movie with a `rating` property,
+// or updates the existing one, setting its `timestamp` to the current time.
+// The rating must be one of the steps of the rating scale, and is stored as a
+// float.
 //
-// If the User or Movie cannot be found, a NotFoundError should be thrown
+// The movie is returned with the user's rating and the aggregate of all
+// ratings, which is updated in the same transaction.
+// If the User or Movie cannot be found, a 404 DomainError is returned.
 // tag::add[]
-func (rs *neo4jRatingService) Save(rating int, movieId string, userId string) (_ Movie, err error) {
-	// TODO: Open a new session
-	// TODO: Save the rating in the database
-	// TODO: Return movie details and a rating
+func (rs *neo4jRatingService) Save(ctx context.Context, rating float64, movieId string, userId string) (_ *Movie, err error) {
+	if err := rs.scale.validate(rating); err != nil {
+		return nil, err
+	}
+	rating = rs.scale.normalize(rating)
 
-	return rs.loader.ReadObject("fixtures/goodfellas.json")
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		if err := ensureAggregates(tx, movieId, rs.scale); err != nil {
+			return nil, err
+		}
+		result, err := tx.Run(`
+			MATCH (u:User {userId: $userId})
+			MATCH (m:Movie {tmdbId: $movieId})
+			OPTIONAL MATCH (u)-[previous:RATED]->(m)
+			WITH u, m, previous.rating AS previousRating, previous.timestamp AS previousTimestamp
+			MERGE (u)-[r:RATED]->(m)
+			SET r.rating = $rating, r.timestamp = datetime().epochSeconds
+			RETURN previousRating, previousTimestamp, r.rating AS rating, r.timestamp AS timestamp`,
+			map[string]interface{}{"userId": userId, "movieId": movieId, "rating": rating})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(404, "User or movie not found", map[string]interface{}{
+				"movieId": movieId,
+				"userId":  userId,
+			})
+		}
+		record := records[0]
+		var row struct {
+			PreviousRating    *float64 `neo4j:"previousRating"`
+			PreviousTimestamp *int64   `neo4j:"previousTimestamp"`
+			Rating            float64  `neo4j:"rating"`
+			Timestamp         int64    `neo4j:"timestamp"`
+		}
+		if err := mapping.DecodeRecord(record, &row); err != nil {
+			return nil, err
+		}
+		var previous *ratingChange
+		if row.PreviousRating != nil {
+			previous = &ratingChange{Rating: *row.PreviousRating}
+			if row.PreviousTimestamp != nil {
+				previous.Timestamp = *row.PreviousTimestamp
+			}
+		}
+		next := &ratingChange{Rating: row.Rating, Timestamp: row.Timestamp}
+		if err := applyAggregateDelta(tx, movieId, newAggregateDelta(rs.scale, previous, next)); err != nil {
+			return nil, err
+		}
+		return findRatedMovie(ctx, tx, movieId, userId, rs.scale)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Movie), nil
 }
 
 // end::add[]
+
+func (rs *neo4jRatingService) Delete(ctx context.Context, movieId string, userId string) (_ *Movie, err error) {
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		if err := ensureAggregates(tx, movieId, rs.scale); err != nil {
+			return nil, err
+		}
+		result, err := tx.Run(`
+			MATCH (u:User {userId: $userId})-[r:RATED]->(m:Movie {tmdbId: $movieId})
+			OPTIONAL MATCH (u)-[:WROTE]->(review:Review)-[:REVIEWS]->(m)
+			WITH r, review, r.rating AS rating, r.timestamp AS timestamp
+			DELETE r
+			DETACH DELETE review
+			RETURN rating, timestamp`,
+			map[string]interface{}{"userId": userId, "movieId": movieId})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(404, "Rating not found", map[string]interface{}{
+				"movieId": movieId,
+			})
+		}
+		record := records[0]
+		var deleted struct {
+			Rating    float64 `neo4j:"rating"`
+			Timestamp *int64  `neo4j:"timestamp"`
+		}
+		if err := mapping.DecodeRecord(record, &deleted); err != nil {
+			return nil, err
+		}
+		previous := &ratingChange{Rating: deleted.Rating}
+		if deleted.Timestamp != nil {
+			previous.Timestamp = *deleted.Timestamp
+		}
+		if err := applyAggregateDelta(tx, movieId, newAggregateDelta(rs.scale, previous, nil)); err != nil {
+			return nil, err
+		}
+		return findRatedMovie(ctx, tx, movieId, userId, rs.scale)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Movie), nil
+}
+
+func (rs *neo4jRatingService) FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) (_ []MovieRating, err error) {
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (:User {userId: $userId})-[r:RATED]->(m:Movie)
+			WITH r, m
+			ORDER BY %s %s
+			SKIP $skip
+			LIMIT $limit
+			RETURN r.rating AS rating, r.timestamp AS timestamp, %s AS movie`,
+			property("r", page.Sort()), direction(page), movieProjection("m", page.Projection())),
+			map[string]interface{}{"userId": userId, "skip": page.Skip(), "limit": page.Limit()})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		ratings := make([]MovieRating, len(records))
+		movies := make([]Movie, len(records))
+		for i, record := range records {
+			if err := mapping.DecodeRecord(record, &ratings[i]); err != nil {
+				return nil, err
+			}
+			movies[i] = ratings[i].Movie
+		}
+		if err := enrichMovies(ctx, tx, userId, movies); err != nil {
+			return nil, err
+		}
+		for i := range movies {
+			ratings[i].Movie = movies[i]
+		}
+		return ratings, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]MovieRating), nil
+}
+
+// findRatedMovie returns the movie with the rating of the user and the
+// stored aggregate of all ratings
+func findRatedMovie(ctx context.Context, tx neo4j.Transaction, movieId, userId string, scale RatingScale) (*Movie, error) {
+	result, err := tx.Run(fmt.Sprintf(`
+		MATCH (m:Movie {tmdbId: $movieId})
+		RETURN %s AS movie`, movieProjection("m", nil)),
+		map[string]interface{}{"movieId": movieId})
+	if err != nil {
+		return nil, err
+	}
+	movies, err := collectMovies(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(movies) == 0 {
+		return nil, NewDomainError(404, "Movie not found", map[string]interface{}{"movieId": movieId})
+	}
+	if err := enrichMovies(ctx, tx, userId, movies); err != nil {
+		return nil, err
+	}
+	aggregate, err := findAggregates(ctx, tx, movieId, scale)
+	if err != nil {
+		return nil, err
+	}
+	movies[0].Aggregate = aggregate
+	return &movies[0], nil
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// SimilarUsersReason marks movies rated highly by users who liked the same
+	// movies as the current user
+	SimilarUsersReason = "similar_users"
+	// PopularInGenreReason marks cold-start recommendations, i.e. popular
+	// movies of the genres the user interacted with, or of any genre
+	PopularInGenreReason = "popular_in_genre"
+
+	// minRatingOverlap is the number of movies two users must both like to
+	// be considered similar
+	minRatingOverlap = 2
+	// maxSimilarUsers bounds the neighbourhood used to score recommendations
+	maxSimilarUsers = 50
+	// maxPreferredGenres bounds the genres used by the cold-start fallback
+	maxPreferredGenres = 3
+)
+
+type Recommendation struct {
+	Movie  Movie                `json:"movie"`
+	Score  float64              `json:"score"`
+	Reason RecommendationReason `json:"reason"`
+}
+
+// RecommendationReason explains a recommendation.
+// Similar users recommendations carry the number of similar users who liked
+// the movie, genre ones the genre the movie was picked from.
+type RecommendationReason struct {
+	Code         string `json:"code" neo4j:"code"`
+	SimilarUsers int64  `json:"similarUsers,omitempty" neo4j:"similarUsers"`
+	Genre        string `json:"genre,omitempty" neo4j:"genre"`
+}
+
+type RecommendationService interface {
+	FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) ([]Recommendation, error)
+}
+
+type neo4jRecommendationService struct {
+	driver neo4j.Driver
+	scale  RatingScale
+}
+
+func NewRecommendationService(driver neo4j.Driver, scale RatingScale) RecommendationService {
+	return &neo4jRecommendationService{driver: driver, scale: scale}
+}
+
+// FindAllByUserId recommends movies the user has neither rated nor favorited.
+//
+// Users who liked at least two of the movies the user liked, i.e. rated them
+// in the top quarter of the rating scale, are considered similar. Movies they
+// liked are scored by the sum of their ratings, weighted by the number of
+// movies each of them has in common with the user.
+// When similar users do not fill the page, the most popular movies of the
+// genres the user rated or favorited, or of all genres if they have not
+// interacted yet, follow, scored by IMDB rating weighted by the number of IMDB
+// votes.
+func (rs *neo4jRecommendationService) FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) (_ []Recommendation, err error) {
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		// the recommendations of similar users up to the end of the page tell
+		// how many of them come before the fallback ones
+		similar, ids, err := findRecommendations(ctx, tx, fmt.Sprintf(`
+			MATCH (u:User {userId: $userId})-[liked:RATED]->(:Movie)<-[rated:RATED]-(other:User)
+			WHERE liked.rating >= $likedRating AND rated.rating >= $likedRating
+			WITH u, other, count(*) AS overlap
+			WHERE overlap >= $minOverlap
+			WITH u, other, overlap
+			ORDER BY overlap DESC
+			LIMIT $neighbours
+			MATCH (other)-[r:RATED]->(m:Movie)
+			WHERE r.rating >= $likedRating
+				AND NOT (u)-[:RATED]->(m)
+				AND NOT (u)-[:HAS_FAVORITE]->(m)
+			WITH m, sum(overlap * r.rating) AS score, count(other) AS similarUsers
+			ORDER BY score DESC, similarUsers DESC
+			LIMIT $skip + $limit
+			RETURN m.tmdbId AS id, %s AS movie, toFloat(score) AS score,
+				{code: '%s', similarUsers: similarUsers} AS reason`,
+			movieProjection("m", page.Projection()), SimilarUsersReason),
+			map[string]interface{}{
+				"userId":      userId,
+				"likedRating": rs.scale.likedRating(),
+				"minOverlap":  minRatingOverlap,
+				"neighbours":  maxSimilarUsers,
+				"skip":        page.Skip(),
+				"limit":       page.Limit(),
+			})
+		if err != nil {
+			return nil, err
+		}
+		recommendations := []Recommendation{}
+		if len(similar) > page.Skip() {
+			recommendations = similar[page.Skip():]
+		}
+		if len(recommendations) == page.Limit() {
+			return recommendations, nil
+		}
+
+		popular, _, err := findRecommendations(ctx, tx, fmt.Sprintf(`
+			OPTIONAL MATCH (u:User {userId: $userId})
+			CALL {
+				WITH u
+				OPTIONAL MATCH (u)-[:RATED|HAS_FAVORITE]->(:Movie)-[:IN_GENRE]->(g:Genre)
+				WITH g, count(*) AS interactions
+				WHERE g IS NOT NULL
+				ORDER BY interactions DESC
+				LIMIT $genreCount
+				RETURN collect(g.name) AS preferred
+			}
+			MATCH (m:Movie)-[:IN_GENRE]->(g:Genre)
+			WHERE (size(preferred) = 0 OR g.name IN preferred)
+				AND m.imdbRating IS NOT NULL AND m.imdbVotes IS NOT NULL
+				AND NOT m.tmdbId IN $exclude
+				AND (u IS NULL OR NOT (u)-[:RATED|HAS_FAVORITE]->(m))
+			WITH m, collect(g.name)[0] AS genre
+			WITH m, genre, m.imdbRating * log10(1 + m.imdbVotes) AS score
+			ORDER BY score DESC
+			SKIP $skip
+			LIMIT $limit
+			RETURN %s AS movie, toFloat(score) AS score,
+				{code: '%s', genre: genre} AS reason`,
+			movieProjection("m", page.Projection()), PopularInGenreReason),
+			map[string]interface{}{
+				"userId":     userId,
+				"genreCount": maxPreferredGenres,
+				"exclude":    ids,
+				"skip":       fallbackSkip(page.Skip(), len(similar)),
+				"limit":      page.Limit() - len(recommendations),
+			})
+		if err != nil {
+			return nil, err
+		}
+		return append(recommendations, popular...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]Recommendation), nil
+}
+
+// findRecommendations runs a statement returning recommendations, along with
+// the tmdbId of their movie under `id` if any
+func findRecommendations(ctx context.Context, tx neo4j.Transaction, cypher string, params map[string]interface{}) ([]Recommendation, []string, error) {
+	result, err := tx.Run(cypher, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, nil, err
+	}
+	recommendations := make([]Recommendation, len(records))
+	ids := make([]string, 0, len(records))
+	for i, record := range records {
+		if err := mapping.DecodeRecord(record, &recommendations[i]); err != nil {
+			return nil, nil, err
+		}
+		if id, ok := record.Get("id"); ok && id != nil {
+			ids = append(ids, id.(string))
+		}
+	}
+	return recommendations, ids, nil
+}
+
+// fallbackSkip returns the number of fallback recommendations to skip, when
+// the similar users recommendations are exhausted after the given count
+func fallbackSkip(skip, similar int) int {
+	if skip > similar {
+		return skip - similar
+	}
+	return 0
+}
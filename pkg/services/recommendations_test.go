@@ -0,0 +1,20 @@
+package services
+
+import "testing"
+
+func TestFallbackSkip(t *testing.T) {
+	for _, testCase := range []struct {
+		skip, similar, expected int
+	}{
+		{0, 0, 0},
+		{0, 3, 0},
+		{10, 3, 7},
+		{10, 10, 0},
+		{10, 12, 0},
+	} {
+		if skip := fallbackSkip(testCase.skip, testCase.similar); skip != testCase.expected {
+			t.Errorf("expected to skip %d fallbacks after %d of %d similar, got %d",
+				testCase.expected, testCase.skip, testCase.similar, skip)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	maxReviewTitleLength = 120
+	minReviewBodyLength  = 20
+	maxReviewBodyLength  = 5000
+)
+
+// reviewOrderings maps ReviewSortableAttributes to Cypher sort expressions
+var reviewOrderings = map[string]string{
+	"helpfulness": "review.helpfulCount %[1]s, review.createdAt DESC",
+	"recency":     "review.createdAt %[1]s",
+}
+
+// profanities are rejected in review titles and bodies, whatever their case
+var profanities = map[string]bool{
+	"arsehole": true, "asshole": true, "bastard": true, "bitch": true,
+	"bollocks": true, "bullshit": true, "cunt": true, "dickhead": true,
+	"fuck": true, "fucked": true, "fucking": true, "motherfucker": true,
+	"shit": true, "shitty": true, "twat": true, "wanker": true,
+}
+
+// Review is the text a user attached to their rating of a movie
+type Review struct {
+	Id           string       `json:"id"`
+	Title        string       `json:"title"`
+	Body         string       `json:"body"`
+	Rating       *float64     `json:"rating,omitempty"`
+	HelpfulCount int64        `json:"helpfulCount"`
+	CreatedAt    string       `json:"createdAt"`
+	UpdatedAt    string       `json:"updatedAt"`
+	User         RatingAuthor `json:"user"`
+	// Helpful tells whether the current user voted the review helpful
+	Helpful *bool `json:"helpful,omitempty"`
+}
+
+type ReviewInput struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type ReviewService interface {
+	// FindAllByMovieId returns a page of the reviews of the movie, sorted by
+	// helpfulness or recency
+	FindAllByMovieId(ctx context.Context, movieId, userId string, page *paging.Paging) ([]Review, error)
+
+	// Save creates or replaces the review of the user's rating of the movie
+	Save(ctx context.Context, userId, movieId string, input ReviewInput) (*Review, error)
+
+	Delete(ctx context.Context, userId, movieId string) (*Review, error)
+
+	// SaveHelpfulVote records that the user found the review helpful.
+	// Voting twice has no effect, and users cannot vote for their own review.
+	SaveHelpfulVote(ctx context.Context, userId, reviewId string) (*Review, error)
+
+	DeleteHelpfulVote(ctx context.Context, userId, reviewId string) (*Review, error)
+}
+
+type neo4jReviewService struct {
+	driver neo4j.Driver
+}
+
+func NewReviewService(driver neo4j.Driver) ReviewService {
+	return &neo4jReviewService{driver: driver}
+}
+
+// reviewProjection projects the review bound to `review`, written by `author`
+// about `m`, for the user identified by $userId.
+// The rating of the author is left out when they hide it from the user.
+var reviewProjection = `review {
+	.id, .title, .body, .helpfulCount,
+	createdAt: toString(review.createdAt), updatedAt: toString(review.updatedAt),
+	rating: CASE WHEN ` + ratingsVisible("author", "userId") + `
+		THEN [ (author)-[r:RATED]->(m) | r.rating ][0] END,
+	user: author { id: author.userId, .name },
+	helpful: CASE WHEN $userId = '' THEN null
+		ELSE exists((:User {userId: $userId})-[:FOUND_HELPFUL]->(review)) END
+}`
+
+func (rs *neo4jReviewService) FindAllByMovieId(ctx context.Context, movieId, userId string, page *paging.Paging) (_ []Review, err error) {
+	ordering, found := reviewOrderings[page.Sort()]
+	if !found {
+		ordering = reviewOrderings["helpfulness"]
+	}
+	order := "DESC"
+	if strings.EqualFold(page.Order(), "asc") {
+		order = "ASC"
+	}
+
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH (author:User)-[:WROTE]->(review:Review)-[:REVIEWS]->(m:Movie {tmdbId: $movieId})
+			WITH author, review, m
+			ORDER BY %s
+			SKIP $skip
+			LIMIT $limit
+			RETURN %s AS review`, fmt.Sprintf(ordering, order), reviewProjection),
+			map[string]interface{}{
+				"movieId": movieId,
+				"userId":  userId,
+				"skip":    page.Skip(),
+				"limit":   page.Limit(),
+			})
+		if err != nil {
+			return nil, err
+		}
+		return collectReviews(ctx, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]Review), nil
+}
+
+func (rs *neo4jReviewService) Save(ctx context.Context, userId, movieId string, input ReviewInput) (_ *Review, err error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	return rs.writeReview(ctx, userId, `
+		MATCH (author:User {userId: $userId})-[:RATED]->(m:Movie {tmdbId: $movieId})
+		MERGE (author)-[:WROTE]->(review:Review)-[:REVIEWS]->(m)
+		ON CREATE SET review.id = randomUUID(), review.createdAt = datetime(),
+			review.helpfulCount = 0
+		SET review.title = $title, review.body = $body, review.updatedAt = datetime()
+		RETURN `+reviewProjection+` AS review`,
+		map[string]interface{}{"userId": userId, "movieId": movieId, "title": input.Title, "body": input.Body},
+		NewDomainError(404, "Rate the movie before reviewing it", map[string]interface{}{"movieId": movieId}))
+}
+
+func (rs *neo4jReviewService) Delete(ctx context.Context, userId, movieId string) (_ *Review, err error) {
+	return rs.writeReview(ctx, userId, `
+		MATCH (author:User {userId: $userId})-[:WROTE]->(review:Review)-[:REVIEWS]->(m:Movie {tmdbId: $movieId})
+		WITH author, review, m, `+reviewProjection+` AS deleted
+		DETACH DELETE review
+		RETURN deleted AS review`,
+		map[string]interface{}{"userId": userId, "movieId": movieId},
+		NewDomainError(404, "Review not found", map[string]interface{}{"movieId": movieId}))
+}
+
+func (rs *neo4jReviewService) SaveHelpfulVote(ctx context.Context, userId, reviewId string) (_ *Review, err error) {
+	return rs.writeReview(ctx, userId, `
+		MATCH (author:User)-[:WROTE]->(review:Review {id: $reviewId})-[:REVIEWS]->(m:Movie)
+		MATCH (voter:User {userId: $userId})
+		WHERE voter <> author
+		MERGE (voter)-[vote:FOUND_HELPFUL]->(review)
+		ON CREATE SET vote.at = datetime(), review.helpfulCount = review.helpfulCount + 1
+		RETURN `+reviewProjection+` AS review`,
+		map[string]interface{}{"userId": userId, "reviewId": reviewId},
+		NewDomainError(404, "Review not found, or written by the voter", map[string]interface{}{"reviewId": reviewId}))
+}
+
+func (rs *neo4jReviewService) DeleteHelpfulVote(ctx context.Context, userId, reviewId string) (_ *Review, err error) {
+	return rs.writeReview(ctx, userId, `
+		MATCH (author:User)-[:WROTE]->(review:Review {id: $reviewId})-[:REVIEWS]->(m:Movie)
+		OPTIONAL MATCH (:User {userId: $userId})-[vote:FOUND_HELPFUL]->(review)
+		FOREACH (_ IN CASE WHEN vote IS NULL THEN [] ELSE [1] END |
+			DELETE vote
+			SET review.helpfulCount = review.helpfulCount - 1)
+		RETURN `+reviewProjection+` AS review`,
+		map[string]interface{}{"userId": userId, "reviewId": reviewId},
+		NewDomainError(404, "Review not found", map[string]interface{}{"reviewId": reviewId}))
+}
+
+// writeReview runs a statement returning a single `review`, or notFound when
+// it returns nothing
+func (rs *neo4jReviewService) writeReview(ctx context.Context, userId string, cypher string, params map[string]interface{}, notFound error) (_ *Review, err error) {
+	session := rs.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(cypher, params)
+		if err != nil {
+			return nil, err
+		}
+		reviews, err := collectReviews(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(reviews) == 0 {
+			return nil, notFound
+		}
+		return &reviews[0], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Review), nil
+}
+
+func collectReviews(ctx context.Context, result neo4j.Result) ([]Review, error) {
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	reviews := make([]Review, len(records))
+	for i, record := range records {
+		if err := mapping.DecodeColumn(record, "review", &reviews[i]); err != nil {
+			return nil, err
+		}
+	}
+	return reviews, nil
+}
+
+func (ri ReviewInput) validate() error {
+	var v validation
+	v.required("title", ri.Title)
+	v.maxLength("title", ri.Title, maxReviewTitleLength)
+	if length := len([]rune(strings.TrimSpace(ri.Body))); length < minReviewBodyLength {
+		v.fail("body", fmt.Sprintf("must be at least %d characters long", minReviewBodyLength))
+	}
+	v.maxLength("body", ri.Body, maxReviewBodyLength)
+	v.decent("title", ri.Title)
+	v.decent("body", ri.Body)
+	return v.err("Invalid review")
+}
+
+// decent rejects values containing profanities
+func (v *validation) decent(field, value string) {
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !isWordRune(r)
+	})
+	for _, word := range words {
+		if profanities[word] {
+			v.fail(field, "must not contain profanity")
+			return
+		}
+	}
+}
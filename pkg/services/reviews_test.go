@@ -0,0 +1,46 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReviewInput(outer *testing.T) {
+	body := "A gripping story of loyalty and betrayal."
+	testCases := []struct {
+		name   string
+		input  ReviewInput
+		fields []string
+	}{
+		{name: "valid review", input: ReviewInput{Title: "A classic", Body: body}},
+		{name: "missing title and short body", input: ReviewInput{Body: "Great"}, fields: []string{"title", "body"}},
+		{name: "long title", input: ReviewInput{Title: strings.Repeat("a", 121), Body: body}, fields: []string{"title"}},
+		{name: "profanity", input: ReviewInput{Title: "Holy SHIT", Body: body + " Fucking great."}, fields: []string{"title", "body"}},
+		{name: "profanity within words is fine", input: ReviewInput{Title: "Scunthorpe", Body: body}},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.name, func(t *testing.T) {
+			err := testCase.input.validate()
+			if len(testCase.fields) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			domainErr, ok := err.(*DomainError)
+			if !ok || domainErr.StatusCode() != 422 {
+				t.Fatalf("expected a 422 DomainError, got %v", err)
+			}
+			if len(domainErr.details) != len(testCase.fields) {
+				t.Fatalf("expected errors on %v, got %v", testCase.fields, domainErr.details)
+			}
+			for _, field := range testCase.fields {
+				if _, found := domainErr.details[field]; !found {
+					t.Fatalf("expected errors on %v, got %v", testCase.fields, domainErr.details)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// RatingScale defines the ratings users may give: from Min to Max, in
+// increments of Step.
+// Ratings are always stored as floats on the RATED relationship.
+type RatingScale struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Step float64 `json:"step"`
+}
+
+// DefaultRatingScale rates movies from 1 to 5 stars, with half stars
+func DefaultRatingScale() RatingScale {
+	return RatingScale{Min: 1, Max: 5, Step: 0.5}
+}
+
+// stepTolerance absorbs the floating point error of ratings such as 0.1 steps
+const stepTolerance = 1e-9
+
+// Check reports whether the scale itself is usable
+func (s RatingScale) Check() error {
+	if math.IsNaN(s.Min) || math.IsNaN(s.Max) || s.Max <= s.Min {
+		return fmt.Errorf("rating scale maximum %v must be greater than its minimum %v", s.Max, s.Min)
+	}
+	if !(s.Step > 0) || s.Step > s.Max-s.Min {
+		return fmt.Errorf("rating scale step %v must be positive and at most %v", s.Step, s.Max-s.Min)
+	}
+	return nil
+}
+
+// validate returns a 422 DomainError when rating is out of the scale or
+// between two of its steps
+func (s RatingScale) validate(rating float64) error {
+	var v validation
+	switch {
+	case math.IsNaN(rating) || math.IsInf(rating, 0):
+		v.fail("rating", "must be a number")
+	case rating < s.Min-stepTolerance || rating > s.Max+stepTolerance:
+		v.fail("rating", fmt.Sprintf("must be between %v and %v", s.Min, s.Max))
+	case math.Abs(s.normalize(rating)-rating) > stepTolerance:
+		v.fail("rating", fmt.Sprintf("must be a multiple of %v", s.Step))
+	}
+	return v.err("Invalid rating")
+}
+
+// normalize rounds rating to the nearest step of the scale, within its bounds
+func (s RatingScale) normalize(rating float64) float64 {
+	steps := math.Round((rating - s.Min) / s.Step)
+	normalized := math.Min(math.Max(s.Min+steps*s.Step, s.Min), s.Max)
+	// keeps 1.5 from being stored as 1.5000000000000002 with 0.1 steps
+	return math.Round(normalized*1e9) / 1e9
+}
+
+// likedRatingShare is the share of the scale above which a rating means the
+// user liked the movie, i.e. 4 stars out of 5
+const likedRatingShare = 0.75
+
+// likedRating returns the lowest rating of the scale meaning the user liked
+// the movie
+func (s RatingScale) likedRating() float64 {
+	steps := math.Ceil((s.Max-s.Min)*likedRatingShare/s.Step - stepTolerance)
+	return math.Round((s.Min+steps*s.Step)*1e9) / 1e9
+}
+
+// RatingMigration reports the outcome of NormalizeRatings.
+// Quarantined counts the ratings moved aside because they are not numbers.
+type RatingMigration struct {
+	Movies      int64 `json:"movies"`
+	Ratings     int64 `json:"ratings"`
+	Changed     int64 `json:"changed"`
+	Quarantined int64 `json:"quarantined"`
+}
+
+type normalizedBatch struct {
+	Movies            int64    `neo4j:"movies"`
+	Ratings           int64    `neo4j:"ratings"`
+	Changed           int64    `neo4j:"changed"`
+	Quarantined       int64    `neo4j:"quarantined"`
+	QuarantinedMovies []string `neo4j:"quarantinedMovies"`
+	Last              *int64   `neo4j:"last"`
+}
+
+// normalizeRatingsBatch is the number of movies whose ratings are normalized
+// in a single transaction
+const normalizeRatingsBatch = 500
+
+// NormalizeRatings is a one-off migration storing every RATED.rating as a
+// float rounded to the nearest step of the scale and clamped to its bounds.
+// Ratings that are not numbers at all, null ones included, are quarantined:
+// their RATED relationship is replaced by an INVALID_RATING one with the same
+// properties, so that they can be fixed by hand without breaking the readers
+// of RATED.rating in the meantime. Their reviews are kept, without a rating.
+// Movies are processed in batches ordered by their internal id, and their
+// rating aggregates are dropped so that they are computed again on next use.
+// The migration is idempotent and can be resumed after a failure.
+func NormalizeRatings(ctx context.Context, driver neo4j.Driver, scale RatingScale) (_ *RatingMigration, err error) {
+	if err := scale.Check(); err != nil {
+		return nil, err
+	}
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	migration := &RatingMigration{}
+	after := int64(-1)
+	for {
+		result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+			result, err := tx.Run(`
+				MATCH (m:Movie)
+				WHERE id(m) > $after
+				WITH m
+				ORDER BY id(m)
+				LIMIT $batch
+				OPTIONAL MATCH (m)-[:HAS_RATING_AGGREGATE]->(aggregate:RatingAggregate)
+				OPTIONAL MATCH (m)-[:HAS_MONTHLY_RATINGS]->(month:RatingMonth)
+				DETACH DELETE aggregate, month
+				WITH DISTINCT m
+				CALL {
+					WITH m
+					MATCH (m)<-[r:RATED]-(u:User)
+					WITH m, u, r, r.rating AS original, toFloat(toString(r.rating)) AS rating
+					WITH m, u, r, original, rating,
+						CASE WHEN rating IS NULL THEN null
+							ELSE round(1e9 * CASE
+								WHEN $min + round((rating - $min) / $step) * $step < $min THEN $min
+								WHEN $min + round((rating - $min) / $step) * $step > $max THEN $max
+								ELSE $min + round((rating - $min) / $step) * $step END) / 1e9
+						END AS normalized
+					FOREACH (_ IN CASE WHEN normalized IS NULL THEN [] ELSE [1] END | SET r.rating = normalized)
+					FOREACH (_ IN CASE WHEN normalized IS NULL THEN [1] ELSE [] END |
+						MERGE (u)-[invalid:INVALID_RATING]->(m)
+						SET invalid = properties(r)
+						DELETE r)
+					RETURN count(*) AS ratings,
+						sum(CASE WHEN normalized IS NULL THEN 1 ELSE 0 END) AS quarantined,
+						sum(CASE WHEN normalized IS NOT NULL AND (original <> normalized OR toString(original) <> toString(normalized)) THEN 1 ELSE 0 END) AS changed
+				}
+				RETURN count(m) AS movies, max(id(m)) AS last,
+					sum(ratings) AS ratings, sum(changed) AS changed, sum(quarantined) AS quarantined,
+					[movie IN collect(CASE WHEN quarantined > 0 THEN m.tmdbId END) WHERE movie IS NOT NULL] AS quarantinedMovies`,
+				map[string]interface{}{
+					"after": after,
+					"batch": normalizeRatingsBatch,
+					"min":   scale.Min,
+					"max":   scale.Max,
+					"step":  scale.Step,
+				})
+			if err != nil {
+				return nil, err
+			}
+			record, err := result.Single()
+			if err != nil {
+				return nil, err
+			}
+			var batch normalizedBatch
+			if err := mapping.DecodeRecord(record, &batch); err != nil {
+				return nil, err
+			}
+			return &batch, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		batch := result.(*normalizedBatch)
+		if batch.Movies == 0 {
+			return migration, nil
+		}
+		migration.Movies += batch.Movies
+		migration.Ratings += batch.Ratings
+		migration.Changed += batch.Changed
+		migration.Quarantined += batch.Quarantined
+		if batch.Quarantined > 0 {
+			log.Printf("moved %d ratings that are not numbers to INVALID_RATING, on movies %v", batch.Quarantined, batch.QuarantinedMovies)
+		}
+		after = *batch.Last
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import "testing"
+
+func TestRatingScaleValidate(outer *testing.T) {
+	testCases := []struct {
+		name    string
+		scale   RatingScale
+		rating  float64
+		invalid bool
+	}{
+		{name: "whole star", scale: DefaultRatingScale(), rating: 4},
+		{name: "half star", scale: DefaultRatingScale(), rating: 3.5},
+		{name: "bounds", scale: DefaultRatingScale(), rating: 5},
+		{name: "below the scale", scale: DefaultRatingScale(), rating: 0.5, invalid: true},
+		{name: "above the scale", scale: DefaultRatingScale(), rating: 6, invalid: true},
+		{name: "between steps", scale: DefaultRatingScale(), rating: 3.25, invalid: true},
+		{name: "decimal steps", scale: RatingScale{Min: 0, Max: 1, Step: 0.1}, rating: 0.3},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.name, func(t *testing.T) {
+			err := testCase.scale.validate(testCase.rating)
+			if !testCase.invalid {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			domainError, ok := err.(*DomainError)
+			if !ok || domainError.StatusCode() != 422 || domainError.details["rating"] == nil {
+				t.Fatalf("expected a 422 error on rating, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRatingScaleNormalize(t *testing.T) {
+	scale := DefaultRatingScale()
+	for rating, expected := range map[float64]float64{0: 1, 2.2: 2, 2.3: 2.5, 4.75: 5, 10: 5} {
+		if normalized := scale.normalize(rating); normalized != expected {
+			t.Errorf("expected %v to be normalized to %v, got %v", rating, expected, normalized)
+		}
+	}
+	if normalized := (RatingScale{Min: 0, Max: 1, Step: 0.1}).normalize(0.3); normalized != 0.3 {
+		t.Errorf("expected 0.3 to stay 0.3, got %v", normalized)
+	}
+}
+
+func TestRatingScaleCheck(t *testing.T) {
+	for _, scale := range []RatingScale{{Min: 5, Max: 1, Step: 1}, {Min: 1, Max: 5, Step: 0}, {Min: 1, Max: 5, Step: 5}} {
+		if scale.Check() == nil {
+			t.Errorf("expected %+v to be rejected", scale)
+		}
+	}
+	if err := DefaultRatingScale().Check(); err != nil {
+		t.Errorf("expected the default scale to be valid, got %v", err)
+	}
+}
+
+func TestLikedRating(t *testing.T) {
+	for _, testCase := range []struct {
+		scale    RatingScale
+		expected float64
+	}{
+		{RatingScale{Min: 1, Max: 5, Step: 0.5}, 4},
+		{RatingScale{Min: 0.5, Max: 5, Step: 0.5}, 4},
+		{RatingScale{Min: 1, Max: 5, Step: 1}, 4},
+		{RatingScale{Min: 0, Max: 10, Step: 1}, 8},
+		{RatingScale{Min: 0, Max: 1, Step: 0.1}, 0.8},
+	} {
+		if liked := testCase.scale.likedRating(); liked != testCase.expected {
+			t.Errorf("expected %v to like from %v, got %v", testCase.scale, testCase.expected, liked)
+		}
+	}
+}
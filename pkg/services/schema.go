@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// uniqueConstraints lists the properties identifying nodes, which the
+// services rely on to reject duplicates of concurrent writes
+var uniqueConstraints = []struct {
+	name     string
+	label    string
+	property string
+}{
+	{"movieTmdbId", "Movie", "tmdbId"},
+	{"personTmdbId", "Person", "tmdbId"},
+	{"genreName", "Genre", "name"},
+	{"userEmail", "User", "email"},
+	{"trendingLeaseName", "TrendingLease", "name"},
+}
+
+// EnsureConstraints creates the uniqueness constraints of the database,
+// unless they or equivalent ones exist.
+// It fails when existing nodes break one of them.
+func EnsureConstraints(ctx context.Context, driver neo4j.Driver) (err error) {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	for _, constraint := range uniqueConstraints {
+		// schema statements cannot run in transaction functions
+		if err := consume(session.Run(fmt.Sprintf(
+			"CREATE CONSTRAINT %s IF NOT EXISTS ON (n:%s) ASSERT n.%s IS UNIQUE",
+			constraint.name, constraint.label, constraint.property), nil, transactionConfig(ctx)...)); err != nil {
+			return fmt.Errorf("could not create the uniqueness constraint of %s.%s: %w",
+				constraint.label, constraint.property, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// castMatchWeight scales down the relevance of movies found through the
+	// name of one of their actors or directors
+	castMatchWeight = 0.5
+	// maxCastHits bounds the people whose credits are searched for movies,
+	// so that common names do not walk thousands of credits
+	maxCastHits = 20
+
+	snippetLength = 160
+)
+
+// SearchOptions controls how each term of a search query is matched
+type SearchOptions struct {
+	// Fuzzy matches terms within a small edit distance, e.g. "godfater"
+	Fuzzy bool
+	// Prefix matches terms starting with the query terms, e.g. "godf"
+	Prefix bool
+}
+
+type MovieSearchHit struct {
+	Movie      Movie             `json:"movie"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+	// MatchedCast lists the actors and directors whose name matched the query
+	MatchedCast []string `json:"matchedCast,omitempty"`
+}
+
+type PersonSearchHit struct {
+	Person     Person            `json:"person"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type SearchResults struct {
+	Movies []MovieSearchHit  `json:"movies"`
+	People []PersonSearchHit `json:"people"`
+}
+
+type SearchService interface {
+	Search(ctx context.Context, query string, options SearchOptions, page *paging.Paging) (*SearchResults, error)
+}
+
+type neo4jSearchService struct {
+	driver  neo4j.Driver
+	indexes *FullTextIndexes
+}
+
+func NewSearchService(driver neo4j.Driver, indexes *FullTextIndexes) SearchService {
+	return &neo4jSearchService{driver: driver, indexes: indexes}
+}
+
+// Search finds the movies whose title, plot or cast names match the query and
+// the people whose name matches it.
+// Both lists are ordered by relevance and paginated with `skip` and `limit`.
+// Matches are highlighted with <mark> tags in the `highlights` of each hit.
+func (ss *neo4jSearchService) Search(ctx context.Context, query string, options SearchOptions, page *paging.Paging) (_ *SearchResults, err error) {
+	luceneQuery, terms := fullTextQuery(query, options)
+	if luceneQuery == "" {
+		return nil, NewDomainError(400, "Search query must contain at least one term", map[string]interface{}{
+			"q": query,
+		})
+	}
+	if err := ss.indexes.ensure(ctx); err != nil {
+		return nil, err
+	}
+
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		parameters := map[string]interface{}{
+			"query":       luceneQuery,
+			"castWeight":  castMatchWeight,
+			"maxCastHits": maxCastHits,
+			"skip":        page.Skip(),
+			"limit":       page.Limit(),
+		}
+		result, err := tx.Run(fmt.Sprintf(`
+			%s
+			WITH m, score, cast
+			ORDER BY score DESC
+			SKIP $skip
+			LIMIT $limit
+			RETURN %s AS movie, score, cast`,
+			movieSearchSubquery, movieProjection("m", page.Projection())),
+			parameters)
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		movies := make([]MovieSearchHit, len(records))
+		for i, record := range records {
+			var hit struct {
+				Movie Movie    `neo4j:"movie"`
+				Score float64  `neo4j:"score"`
+				Cast  []string `neo4j:"cast"`
+			}
+			if err := mapping.DecodeRecord(record, &hit); err != nil {
+				return nil, err
+			}
+			movies[i] = MovieSearchHit{
+				Movie:       hit.Movie,
+				Score:       hit.Score,
+				Highlights:  highlights(terms, options, map[string]string{"title": hit.Movie.Title, "plot": hit.Movie.Plot}),
+				MatchedCast: hit.Cast,
+			}
+		}
+
+		result, err = tx.Run(`
+			CALL db.index.fulltext.queryNodes('personSearch', $query) YIELD node AS p, score
+			RETURN p { .* } AS person, score
+			ORDER BY score DESC
+			SKIP $skip
+			LIMIT $limit`,
+			parameters)
+		if err != nil {
+			return nil, err
+		}
+		records, err = collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		people := make([]PersonSearchHit, len(records))
+		for i, record := range records {
+			var hit struct {
+				Person Person  `neo4j:"person"`
+				Score  float64 `neo4j:"score"`
+			}
+			if err := mapping.DecodeRecord(record, &hit); err != nil {
+				return nil, err
+			}
+			people[i] = PersonSearchHit{
+				Person:     hit.Person,
+				Score:      hit.Score,
+				Highlights: highlights(terms, options, map[string]string{"name": hit.Person.Name}),
+			}
+		}
+		return &SearchResults{Movies: movies, People: people}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.(*SearchResults), nil
+}
+
+// movieSearchSubquery binds `m` to each movie matching the full-text $query by
+// title, plot or cast name, along with its relevance `score` and the matching
+// `cast` names.
+// Only the $maxCastHits most relevant people are searched for movies.
+const movieSearchSubquery = `
+	CALL {
+		CALL db.index.fulltext.queryNodes('movieSearch', $query) YIELD node, score
+		RETURN node AS m, score, [] AS cast
+		UNION ALL
+		CALL db.index.fulltext.queryNodes('personSearch', $query) YIELD node, score
+		WITH node, score
+		ORDER BY score DESC
+		LIMIT $maxCastHits
+		MATCH (node)-[:ACTED_IN|DIRECTED]->(m:Movie)
+		RETURN m, score * $castWeight AS score, [node.name] AS cast
+	}
+	WITH m, sum(score) AS score, collect(cast) AS casts
+	WITH m, score, reduce(names = [], cast IN casts | names + cast) AS cast`
+
+// fullTextIndexAwaitSeconds bounds the time spent waiting for the full-text
+// indexes to come online
+const fullTextIndexAwaitSeconds = 300
+
+// fullTextIndexTimeout bounds the creation of the full-text indexes, waiting
+// for them included
+const fullTextIndexTimeout = fullTextIndexAwaitSeconds*time.Second + time.Minute
+
+// FullTextIndexes creates the full-text indexes backing searches the first time
+// they are needed, and waits until they are online.
+// A single instance is shared by the services querying them.
+type FullTextIndexes struct {
+	create func(ctx context.Context) error
+
+	mutex    sync.Mutex
+	online   bool
+	creation *indexCreation
+}
+
+// indexCreation is a running attempt at creating the indexes, whose err is
+// set once done is closed
+type indexCreation struct {
+	done chan struct{}
+	err  error
+}
+
+func NewFullTextIndexes(driver neo4j.Driver) *FullTextIndexes {
+	return &FullTextIndexes{create: func(ctx context.Context) error {
+		return createFullTextIndexes(ctx, driver)
+	}}
+}
+
+// ensure returns once the indexes are online, or when ctx is done.
+// The indexes are created by a single attempt at a time, running in the
+// background so that it outlives the requests waiting for it. A failed
+// attempt is reported to its waiters, and the next call starts another one.
+func (fi *FullTextIndexes) ensure(ctx context.Context) error {
+	fi.mutex.Lock()
+	if fi.online {
+		fi.mutex.Unlock()
+		return nil
+	}
+	creation := fi.creation
+	if creation == nil {
+		creation = &indexCreation{done: make(chan struct{})}
+		fi.creation = creation
+		go fi.run(creation)
+	}
+	fi.mutex.Unlock()
+
+	select {
+	case <-creation.done:
+		return creation.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (fi *FullTextIndexes) run(creation *indexCreation) {
+	ctx, cancel := context.WithTimeout(context.Background(), fullTextIndexTimeout)
+	defer cancel()
+	err := fi.create(ctx)
+
+	fi.mutex.Lock()
+	fi.online = err == nil
+	fi.creation = nil
+	fi.mutex.Unlock()
+	creation.err = err
+	close(creation.done)
+}
+
+func createFullTextIndexes(ctx context.Context, driver neo4j.Driver) (err error) {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+	for _, index := range []struct {
+		name      string
+		statement string
+	}{
+		{"movieSearch", "CREATE FULLTEXT INDEX movieSearch IF NOT EXISTS FOR (m:Movie) ON EACH [m.title, m.plot]"},
+		{"personSearch", "CREATE FULLTEXT INDEX personSearch IF NOT EXISTS FOR (p:Person) ON EACH [p.name]"},
+	} {
+		// schema statements cannot run in transaction functions
+		if err := consume(session.Run(index.statement, nil, transactionConfig(ctx)...)); err != nil {
+			return err
+		}
+		// newly created indexes are populated in the background, and reject
+		// queries until then
+		if err := consume(session.Run("CALL db.awaitIndex($name, $seconds)",
+			map[string]interface{}{"name": index.name, "seconds": fullTextIndexAwaitSeconds},
+			transactionConfig(ctx)...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func consume(result neo4j.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
+}
+
+// fullTextQuery converts user input into a Lucene query matching any of its
+// terms.
+// Only letters and digits make up terms, so that the query never contains
+// Lucene operators. It returns the query along with the lower-cased terms.
+func fullTextQuery(input string, options SearchOptions) (string, []string) {
+	terms := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !isWordRune(r)
+	})
+	clauses := make([]string, len(terms))
+	for i, term := range terms {
+		switch {
+		case options.Fuzzy && options.Prefix:
+			clauses[i] = fmt.Sprintf("(%s~ OR %s*)", term, term)
+		case options.Fuzzy:
+			clauses[i] = term + "~"
+		case options.Prefix:
+			clauses[i] = term + "*"
+		default:
+			clauses[i] = term
+		}
+	}
+	return strings.Join(clauses, " "), terms
+}
+
+// highlights returns a highlighted snippet of each of the fields containing
+// one of the terms
+func highlights(terms []string, options SearchOptions, fields map[string]string) map[string]string {
+	results := map[string]string{}
+	for name, text := range fields {
+		if snippet, ok := highlight(text, terms, options.Prefix || options.Fuzzy); ok {
+			results[name] = snippet
+		}
+	}
+	return results
+}
+
+// highlight wraps the words of text matching one of the terms in <mark> tags,
+// and trims text around the first match when it is long.
+// The rest of the text is HTML-escaped.
+// Words match a term when they are equal to it, or start with it when
+// prefix matching is enabled.
+func highlight(text string, terms []string, prefix bool) (string, bool) {
+	runes := []rune(text)
+	type span struct{ start, end int }
+	var matches []span
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		word := strings.ToLower(string(runes[start:end]))
+		for _, term := range terms {
+			if word == term || (prefix && strings.HasPrefix(word, term)) {
+				matches = append(matches, span{start, end})
+				break
+			}
+		}
+		start = end
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	from, to := 0, len(runes)
+	if len(runes) > snippetLength {
+		from = matches[0].start - snippetLength/4
+		if from < 0 {
+			from = 0
+		}
+		to = from + snippetLength
+		if to > len(runes) {
+			to = len(runes)
+		}
+	}
+	var builder strings.Builder
+	if from > 0 {
+		builder.WriteString("…")
+	}
+	position := from
+	for _, match := range matches {
+		if match.start < from || match.end > to {
+			continue
+		}
+		builder.WriteString(html.EscapeString(string(runes[position:match.start])))
+		builder.WriteString("<mark>")
+		builder.WriteString(html.EscapeString(string(runes[match.start:match.end])))
+		builder.WriteString("</mark>")
+		position = match.end
+	}
+	builder.WriteString(html.EscapeString(string(runes[position:to])))
+	if to < len(runes) {
+		builder.WriteString("…")
+	}
+	return builder.String(), true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFullTextQuery(outer *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		options  SearchOptions
+		expected string
+	}{
+		{name: "exact terms", input: "The Godfather", expected: "the godfather"},
+		{name: "strips Lucene operators", input: `godfather AND "part" -ii~`, expected: "godfather and part ii"},
+		{name: "fuzzy terms", input: "godfater", options: SearchOptions{Fuzzy: true}, expected: "godfater~"},
+		{name: "prefix terms", input: "god fath", options: SearchOptions{Prefix: true}, expected: "god* fath*"},
+		{name: "fuzzy prefix terms", input: "godf", options: SearchOptions{Fuzzy: true, Prefix: true}, expected: "(godf~ OR godf*)"},
+		{name: "no terms", input: " *?! ", expected: ""},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		outer.Run(testCase.name, func(t *testing.T) {
+			query, _ := fullTextQuery(testCase.input, testCase.options)
+			if query != testCase.expected {
+				t.Fatalf("expected %q, got %q", testCase.expected, query)
+			}
+		})
+	}
+}
+
+func TestHighlight(outer *testing.T) {
+	outer.Run("marks matching words", func(t *testing.T) {
+		snippet, ok := highlight("The Godfather: Part II", []string{"godfather"}, false)
+		if !ok || snippet != "The <mark>Godfather</mark>: Part II" {
+			t.Fatalf("unexpected snippet %q", snippet)
+		}
+	})
+
+	outer.Run("marks prefixes", func(t *testing.T) {
+		snippet, ok := highlight("The Godfather", []string{"god"}, true)
+		if !ok || snippet != "The <mark>Godfather</mark>" {
+			t.Fatalf("unexpected snippet %q", snippet)
+		}
+	})
+
+	outer.Run("escapes text", func(t *testing.T) {
+		snippet, _ := highlight("Tom & Jerry <3", []string{"jerry"}, false)
+		if snippet != "Tom &amp; <mark>Jerry</mark> &lt;3" {
+			t.Fatalf("unexpected snippet %q", snippet)
+		}
+	})
+
+	outer.Run("trims long text around the first match", func(t *testing.T) {
+		text := strings.Repeat("lorem ", 50) + "corleone" + strings.Repeat(" ipsum", 50)
+		snippet, _ := highlight(text, []string{"corleone"}, false)
+		if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") ||
+			!strings.Contains(snippet, "<mark>corleone</mark>") {
+			t.Fatalf("unexpected snippet %q", snippet)
+		}
+	})
+
+	outer.Run("reports missing matches", func(t *testing.T) {
+		if _, ok := highlight("The Godfather", []string{"heat"}, true); ok {
+			t.Fatal("expected no match")
+		}
+	})
+}
+
+func TestFullTextIndexesEnsure(outer *testing.T) {
+	outer.Run("creates the indexes once for concurrent callers", func(t *testing.T) {
+		var creations int32
+		release := make(chan struct{})
+		indexes := &FullTextIndexes{create: func(ctx context.Context) error {
+			atomic.AddInt32(&creations, 1)
+			<-release
+			return nil
+		}}
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			go func() {
+				errs <- indexes.ensure(context.Background())
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		for i := 0; i < 5; i++ {
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := indexes.ensure(context.Background())
+		if count := atomic.LoadInt32(&creations); err != nil || count != 1 {
+			t.Fatalf("expected a single creation, got %d (error %v)", count, err)
+		}
+	})
+
+	outer.Run("stops waiting when the context is done", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		indexes := &FullTextIndexes{create: func(ctx context.Context) error {
+			<-release
+			return nil
+		}}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := indexes.ensure(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected the deadline to be exceeded, got %v", err)
+		}
+	})
+
+	outer.Run("retries after a failed creation", func(t *testing.T) {
+		var creations int32
+		indexes := &FullTextIndexes{create: func(ctx context.Context) error {
+			if atomic.AddInt32(&creations, 1) == 1 {
+				return errors.New("unavailable")
+			}
+			return nil
+		}}
+		if err := indexes.ensure(context.Background()); err == nil {
+			t.Fatal("expected the first creation to fail")
+		}
+		if err := indexes.ensure(context.Background()); err != nil {
+			t.Fatalf("expected the second creation to succeed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// SimilarityWeights sets how much each kind of connection shared by two
+// movies contributes to their similarity score
+type SimilarityWeights struct {
+	// Actor is added for each actor of both movies
+	Actor float64
+	// Director is added for each director of both movies
+	Director float64
+	// Genre is added for each genre of both movies
+	Genre float64
+	// CoRating is multiplied by the logarithm of the number of users who
+	// rated both movies highly
+	CoRating float64
+}
+
+// maxCoRaters bounds the users who liked a movie whose other ratings are
+// walked to find co-rated movies, the latest raters being kept
+const maxCoRaters = 100
+
+func DefaultSimilarityWeights() SimilarityWeights {
+	return SimilarityWeights{Actor: 1, Director: 1.5, Genre: 0.5, CoRating: 1}
+}
+
+// Similarity explains the similarity score of a movie with another one
+type Similarity struct {
+	Actors    []Person `json:"actors"`
+	Directors []Person `json:"directors"`
+	Genres    []Genre  `json:"genres"`
+	// CoRatings counts the users who liked both movies, among the latest
+	// users who liked the compared movie
+	CoRatings int64 `json:"coRatings"`
+}
+
+// similarMoviesQuery binds `m` to each movie sharing an actor, a director, a
+// genre or a user who liked both with the movie identified by $id, along with
+// its `score` and `similarity` breakdown
+const similarMoviesQuery = `
+	MATCH (source:Movie {tmdbId: $id})
+	CALL {
+		WITH source
+		MATCH (source)<-[:ACTED_IN]-(a:Person)-[:ACTED_IN]->(m:Movie)
+		RETURN m, 'actor' AS kind, a { .tmdbId, .name } AS shared
+		UNION ALL
+		WITH source
+		MATCH (source)<-[:DIRECTED]-(d:Person)-[:DIRECTED]->(m:Movie)
+		RETURN m, 'director' AS kind, d { .tmdbId, .name } AS shared
+		UNION ALL
+		WITH source
+		MATCH (source)-[:IN_GENRE]->(g:Genre)<-[:IN_GENRE]-(m:Movie)
+		RETURN m, 'genre' AS kind, g { link: '/genres/' + g.name, .name } AS shared
+		UNION ALL
+		WITH source
+		MATCH (source)<-[r1:RATED]-(u:User)
+		WHERE r1.rating >= $likedRating
+		WITH u, r1.timestamp AS ratedAt
+		ORDER BY ratedAt DESC
+		LIMIT $maxCoRaters
+		MATCH (u)-[r2:RATED]->(m:Movie)
+		WHERE r2.rating >= $likedRating
+		RETURN m, 'rating' AS kind, null AS shared
+	}
+	WITH source, m, kind, shared
+	WHERE m <> source
+	WITH m,
+		collect(DISTINCT CASE kind WHEN 'actor' THEN shared END) AS actors,
+		collect(DISTINCT CASE kind WHEN 'director' THEN shared END) AS directors,
+		collect(DISTINCT CASE kind WHEN 'genre' THEN shared END) AS genres,
+		sum(CASE kind WHEN 'rating' THEN 1 ELSE 0 END) AS coRatings
+	WITH m,
+		{ actors: actors, directors: directors, genres: genres, coRatings: coRatings } AS similarity,
+		$actorWeight * size(actors) + $directorWeight * size(directors)
+			+ $genreWeight * size(genres) + $coRatingWeight * log(1 + coRatings) AS score`
+
+// findSimilarMovies returns a page of the movies similar to the one
+// identified by id, each with its score and similarity breakdown.
+// They are ordered by similarity, most similar first unless `order=asc`, or
+// by the movie property given as `sort`.
+func findSimilarMovies(ctx context.Context, tx neo4j.Transaction, id string, weights SimilarityWeights, scale RatingScale, page *paging.Paging) ([]Movie, error) {
+	ordering := "ORDER BY score DESC"
+	if strings.EqualFold(page.Order(), "asc") {
+		ordering = "ORDER BY score ASC"
+	}
+	if page.Sort() != "score" {
+		ordering = fmt.Sprintf("WHERE %s IS NOT NULL\n%s, score DESC", property("m", page.Sort()), orderBy("m", page))
+	}
+	result, err := tx.Run(fmt.Sprintf(`
+		%s
+		%s
+		SKIP $skip
+		LIMIT $limit
+		RETURN %s AS movie, toFloat(score) AS score, similarity`,
+		similarMoviesQuery, ordering, movieProjection("m", page.Projection())),
+		map[string]interface{}{
+			"id":             id,
+			"likedRating":    scale.likedRating(),
+			"maxCoRaters":    maxCoRaters,
+			"actorWeight":    weights.Actor,
+			"directorWeight": weights.Director,
+			"genreWeight":    weights.Genre,
+			"coRatingWeight": weights.CoRating,
+			"skip":           page.Skip(),
+			"limit":          page.Limit(),
+		})
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	movies := make([]Movie, len(records))
+	for i, record := range records {
+		var row struct {
+			Movie      Movie      `neo4j:"movie"`
+			Score      float64    `neo4j:"score"`
+			Similarity Similarity `neo4j:"similarity"`
+		}
+		if err := mapping.DecodeRecord(record, &row); err != nil {
+			return nil, err
+		}
+		score, similarity := row.Score, row.Similarity
+		movies[i] = row.Movie
+		movies[i].Score = &score
+		movies[i].Similarity = &similarity
+	}
+	return movies, nil
+}
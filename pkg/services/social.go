@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// PublicRatings are visible to every user
+	PublicRatings = "public"
+	// FollowersRatings are only visible to the followers of their author
+	FollowersRatings = "followers"
+
+	RatingActivity   = "rating"
+	FavoriteActivity = "favorite"
+	ReviewActivity   = "review"
+)
+
+// UserProfile is the public view of a user, with the number of their
+// followers and followed users.
+// Followed tells whether the current user follows them.
+type UserProfile struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Followers int64  `json:"followers"`
+	Following int64  `json:"following"`
+	Followed  *bool  `json:"followed,omitempty"`
+}
+
+// PrivacySettings control who sees the ratings of a user, their own ratings
+// being always visible to them
+type PrivacySettings struct {
+	RatingsVisibility string `json:"ratingsVisibility"`
+}
+
+// Activity is a rating, favorite or review of a movie by a user, at a given
+// time in seconds since the epoch
+type Activity struct {
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"`
+	User      RatingAuthor    `json:"user"`
+	Movie     Movie           `json:"movie"`
+	Rating    *float64        `json:"rating,omitempty"`
+	Review    *ActivityReview `json:"review,omitempty"`
+}
+
+// ActivityReview identifies the review of a review activity
+type ActivityReview struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type SocialService interface {
+	// Follow makes the user follow the target user, following twice has no
+	// effect
+	Follow(ctx context.Context, userId, targetId string) (*UserProfile, error)
+
+	Unfollow(ctx context.Context, userId, targetId string) (*UserProfile, error)
+
+	// FindAllFollowers returns a page of the users following the user, the
+	// latest followers first
+	FindAllFollowers(ctx context.Context, userId, viewerId string, page *paging.Paging) ([]UserProfile, error)
+
+	// FindAllFollowing returns a page of the users followed by the user, the
+	// latest followed first
+	FindAllFollowing(ctx context.Context, userId, viewerId string, page *paging.Paging) ([]UserProfile, error)
+
+	// FindFeed returns a page of the activity of the users followed by the
+	// user
+	FindFeed(ctx context.Context, userId string, page *paging.Paging) ([]Activity, error)
+
+	// FindAllActivityByUserId returns a page of the activity of the user, as
+	// visible to the viewer
+	FindAllActivityByUserId(ctx context.Context, userId, viewerId string, page *paging.Paging) ([]Activity, error)
+
+	FindPrivacySettings(ctx context.Context, userId string) (*PrivacySettings, error)
+
+	SavePrivacySettings(ctx context.Context, userId string, settings PrivacySettings) (*PrivacySettings, error)
+
+	// FindTasteMatch compares the ratings of the user with the other user's,
+	// over the movies they both rated
+	FindTasteMatch(ctx context.Context, userId, otherId string) (*TasteMatch, error)
+}
+
+type neo4jSocialService struct {
+	driver neo4j.Driver
+}
+
+func NewSocialService(driver neo4j.Driver) SocialService {
+	return &neo4jSocialService{driver: driver}
+}
+
+// ratingsVisibleTo tells whether the ratings of the author, with the given
+// ratingsVisibility, are visible to the viewer, who may follow the author.
+// ratingsVisible is its Cypher equivalent.
+func ratingsVisibleTo(ratingsVisibility, authorId, viewerId string, followed bool) bool {
+	return ratingsVisibility == "" || ratingsVisibility == PublicRatings || authorId == viewerId || followed
+}
+
+// ratingsVisible returns a Cypher predicate telling whether the ratings of
+// the user bound to variable are visible to the user identified by the
+// viewerParameter parameter, as decided by ratingsVisibleTo
+func ratingsVisible(variable, viewerParameter string) string {
+	return fmt.Sprintf(`(coalesce(%[1]s.ratingsVisibility, '%[3]s') = '%[3]s'
+		OR %[1]s.userId = $%[2]s
+		OR exists((:User {userId: $%[2]s})-[:FOLLOWS]->(%[1]s)))`,
+		variable, viewerParameter, PublicRatings)
+}
+
+// userProfileProjection projects the user bound to `target` for the user
+// identified by $viewerId
+const userProfileProjection = `target {
+	id: target.userId, .name,
+	followers: size((target)<-[:FOLLOWS]-(:User)),
+	following: size((target)-[:FOLLOWS]->(:User)),
+	followed: CASE WHEN $viewerId = '' THEN null
+		ELSE exists((:User {userId: $viewerId})-[:FOLLOWS]->(target)) END
+}`
+
+func (ss *neo4jSocialService) Follow(ctx context.Context, userId, targetId string) (*UserProfile, error) {
+	if userId == targetId {
+		return nil, NewDomainError(422, "Users cannot follow themselves", map[string]interface{}{"userId": targetId})
+	}
+	return ss.writeFollow(ctx, `
+		MATCH (u:User {userId: $viewerId})
+		MATCH (target:User {userId: $targetId})
+		MERGE (u)-[follow:FOLLOWS]->(target)
+		ON CREATE SET follow.createdAt = datetime()
+		RETURN `+userProfileProjection+` AS profile`,
+		userId, targetId)
+}
+
+func (ss *neo4jSocialService) Unfollow(ctx context.Context, userId, targetId string) (*UserProfile, error) {
+	return ss.writeFollow(ctx, `
+		MATCH (target:User {userId: $targetId})
+		OPTIONAL MATCH (:User {userId: $viewerId})-[follow:FOLLOWS]->(target)
+		DELETE follow
+		RETURN `+userProfileProjection+` AS profile`,
+		userId, targetId)
+}
+
+// writeFollow runs a statement returning the `profile` of the target user,
+// for the user identified by userId
+func (ss *neo4jSocialService) writeFollow(ctx context.Context, cypher string, userId, targetId string) (_ *UserProfile, err error) {
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(cypher, map[string]interface{}{"viewerId": userId, "targetId": targetId})
+		if err != nil {
+			return nil, err
+		}
+		profiles, err := collectProfiles(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(profiles) == 0 {
+			return nil, NewDomainError(404, "User not found", map[string]interface{}{"userId": targetId})
+		}
+		return &profiles[0], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*UserProfile), nil
+}
+
+func (ss *neo4jSocialService) FindAllFollowers(ctx context.Context, userId, viewerId string, page *paging.Paging) ([]UserProfile, error) {
+	return ss.findProfiles(ctx, "(:User {userId: $userId})<-[follow:FOLLOWS]-(target:User)", userId, viewerId, page)
+}
+
+func (ss *neo4jSocialService) FindAllFollowing(ctx context.Context, userId, viewerId string, page *paging.Paging) ([]UserProfile, error) {
+	return ss.findProfiles(ctx, "(:User {userId: $userId})-[follow:FOLLOWS]->(target:User)", userId, viewerId, page)
+}
+
+// findProfiles returns a page of the users bound to `target` by the pattern,
+// ordered by the creation of the `follow` relationship, latest first
+func (ss *neo4jSocialService) findProfiles(ctx context.Context, pattern string, userId, viewerId string, page *paging.Paging) (_ []UserProfile, err error) {
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+			MATCH %s
+			WITH target, follow
+			ORDER BY follow.createdAt DESC
+			SKIP $skip
+			LIMIT $limit
+			RETURN %s AS profile`, pattern, userProfileProjection),
+			map[string]interface{}{
+				"userId":   userId,
+				"viewerId": viewerId,
+				"skip":     page.Skip(),
+				"limit":    page.Limit(),
+			})
+		if err != nil {
+			return nil, err
+		}
+		return collectProfiles(ctx, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]UserProfile), nil
+}
+
+func collectProfiles(ctx context.Context, result neo4j.Result) ([]UserProfile, error) {
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]UserProfile, len(records))
+	for i, record := range records {
+		if err := mapping.DecodeColumn(record, "profile", &profiles[i]); err != nil {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
+
+// FindFeed lists the ratings of the followed users whatever their privacy
+// settings, which never hide ratings from followers
+func (ss *neo4jSocialService) FindFeed(ctx context.Context, userId string, page *paging.Paging) ([]Activity, error) {
+	return ss.findActivity(ctx, userId, "(:User {userId: $viewerId})-[:FOLLOWS]->(u:User)", nil, page,
+		func(neo4j.Transaction) (bool, error) {
+			return true, nil
+		})
+}
+
+func (ss *neo4jSocialService) FindAllActivityByUserId(ctx context.Context, userId, viewerId string, page *paging.Paging) ([]Activity, error) {
+	return ss.findActivity(ctx, viewerId, "(u:User {userId: $userId})", map[string]interface{}{"userId": userId}, page,
+		func(tx neo4j.Transaction) (bool, error) {
+			visible, err := findRatingsVisible(ctx, tx, userId, viewerId)
+			return visible != nil && *visible, err
+		})
+}
+
+// findRatingsVisible tells whether the ratings of the author are visible to
+// the viewer, nil when the author cannot be found
+func findRatingsVisible(ctx context.Context, tx neo4j.Transaction, authorId, viewerId string) (*bool, error) {
+	result, err := tx.Run(`
+		MATCH (author:User {userId: $authorId})
+		RETURN author.ratingsVisibility AS ratingsVisibility,
+			exists((:User {userId: $viewerId})-[:FOLLOWS]->(author)) AS followed`,
+		map[string]interface{}{"authorId": authorId, "viewerId": viewerId})
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	var author struct {
+		RatingsVisibility *string `neo4j:"ratingsVisibility"`
+		Followed          bool    `neo4j:"followed"`
+	}
+	if err := mapping.DecodeRecord(records[0], &author); err != nil {
+		return nil, err
+	}
+	var ratingsVisibility string
+	if author.RatingsVisibility != nil {
+		ratingsVisibility = *author.RatingsVisibility
+	}
+	visible := ratingsVisibleTo(ratingsVisibility, authorId, viewerId, author.Followed)
+	return &visible, nil
+}
+
+// findActivity returns a page of the ratings, favorites and reviews of the
+// users bound to `u` by the pattern, latest first unless the order is given.
+// Ratings are left out unless ratingsVisible tells that the viewer may see
+// them, and favorites without a `createdAt` datetime cannot be placed in time.
+// Each kind of activity is sorted and limited to the page on its own before
+// they are merged, so that only a few pages of activity are ever loaded.
+func (ss *neo4jSocialService) findActivity(ctx context.Context, viewerId string, pattern string, params map[string]interface{}, page *paging.Paging,
+	ratingsVisible func(tx neo4j.Transaction) (bool, error)) (_ []Activity, err error) {
+	order := "DESC"
+	if strings.EqualFold(page.Order(), "asc") {
+		order = "ASC"
+	}
+
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		visible, err := ratingsVisible(tx)
+		if err != nil {
+			return nil, err
+		}
+		parameters := map[string]interface{}{
+			"viewerId":       viewerId,
+			"ratingsVisible": visible,
+			"rating":         RatingActivity,
+			"favorite":       FavoriteActivity,
+			"review":         ReviewActivity,
+			"skip":           page.Skip(),
+			"limit":          page.Limit(),
+		}
+		for key, value := range params {
+			parameters[key] = value
+		}
+		result, err := tx.Run(fmt.Sprintf(`
+			CALL {
+				MATCH %[1]s
+				WHERE $ratingsVisible
+				MATCH (u)-[r:RATED]->(m:Movie)
+				RETURN u, $rating AS type, r.timestamp AS timestamp, m, r.rating AS rating, null AS review
+				ORDER BY timestamp %[2]s
+				LIMIT $skip + $limit
+				UNION ALL
+				MATCH %[1]s
+				MATCH (u)-[f:HAS_FAVORITE]->(m:Movie)
+				WHERE f.createdAt IS NOT NULL
+				RETURN u, $favorite AS type, f.createdAt.epochSeconds AS timestamp, m, null AS rating, null AS review
+				ORDER BY timestamp %[2]s
+				LIMIT $skip + $limit
+				UNION ALL
+				MATCH %[1]s
+				MATCH (u)-[:WROTE]->(review:Review)-[:REVIEWS]->(m:Movie)
+				RETURN u, $review AS type, review.createdAt.epochSeconds AS timestamp, m, null AS rating,
+					review { .id, .title } AS review
+				ORDER BY timestamp %[2]s
+				LIMIT $skip + $limit
+			}
+			WITH u, type, timestamp, m, rating, review
+			ORDER BY timestamp %[2]s
+			SKIP $skip
+			LIMIT $limit
+			RETURN type, timestamp, u { id: u.userId, .name } AS user, %[3]s AS movie, rating, review`,
+			pattern, order, movieProjection("m", page.Projection())),
+			parameters)
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		activity := make([]Activity, len(records))
+		movies := make([]Movie, len(records))
+		for i, record := range records {
+			if err := mapping.DecodeRecord(record, &activity[i]); err != nil {
+				return nil, err
+			}
+			movies[i] = activity[i].Movie
+		}
+		if err := enrichMovies(ctx, tx, viewerId, movies); err != nil {
+			return nil, err
+		}
+		for i := range movies {
+			activity[i].Movie = movies[i]
+		}
+		return activity, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]Activity), nil
+}
+
+func (ss *neo4jSocialService) FindPrivacySettings(ctx context.Context, userId string) (_ *PrivacySettings, err error) {
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		return findPrivacySettings(ctx, tx, `
+			MATCH (u:User {userId: $userId})
+			RETURN coalesce(u.ratingsVisibility, $public) AS ratingsVisibility`,
+			map[string]interface{}{"userId": userId, "public": PublicRatings})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*PrivacySettings), nil
+}
+
+func (ss *neo4jSocialService) SavePrivacySettings(ctx context.Context, userId string, settings PrivacySettings) (_ *PrivacySettings, err error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		return findPrivacySettings(ctx, tx, `
+			MATCH (u:User {userId: $userId})
+			SET u.ratingsVisibility = $ratingsVisibility
+			RETURN u.ratingsVisibility AS ratingsVisibility`,
+			map[string]interface{}{"userId": userId, "ratingsVisibility": settings.RatingsVisibility})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*PrivacySettings), nil
+}
+
+func findPrivacySettings(ctx context.Context, tx neo4j.Transaction, cypher string, params map[string]interface{}) (*PrivacySettings, error) {
+	result, err := tx.Run(cypher, params)
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, NewDomainError(404, "User not found", map[string]interface{}{"userId": params["userId"]})
+	}
+	var settings PrivacySettings
+	if err := mapping.DecodeRecord(records[0], &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (ps PrivacySettings) validate() error {
+	var v validation
+	if ps.RatingsVisibility != PublicRatings && ps.RatingsVisibility != FollowersRatings {
+		v.fail("ratingsVisibility", fmt.Sprintf("must be %s or %s", PublicRatings, FollowersRatings))
+	}
+	return v.err("Invalid privacy settings")
+}
@@ -0,0 +1,37 @@
+package services
+
+import "testing"
+
+func TestPrivacySettingsValidate(t *testing.T) {
+	for _, visibility := range []string{PublicRatings, FollowersRatings} {
+		if err := (PrivacySettings{RatingsVisibility: visibility}).validate(); err != nil {
+			t.Errorf("expected %q to be valid, got %v", visibility, err)
+		}
+	}
+	domainError, ok := PrivacySettings{RatingsVisibility: "friends"}.validate().(*DomainError)
+	if !ok || domainError.StatusCode() != 422 || domainError.details["ratingsVisibility"] == nil {
+		t.Errorf("expected a 422 error on ratingsVisibility, got %v", domainError)
+	}
+}
+
+func TestRatingsVisibleTo(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		visibility string
+		viewerId   string
+		followed   bool
+		expected   bool
+	}{
+		{name: "public ratings", visibility: PublicRatings, viewerId: "viewer", expected: true},
+		{name: "ratings without settings", visibility: "", viewerId: "viewer", expected: true},
+		{name: "anonymous viewer of public ratings", visibility: PublicRatings, viewerId: "", expected: true},
+		{name: "follower", visibility: FollowersRatings, viewerId: "viewer", followed: true, expected: true},
+		{name: "author", visibility: FollowersRatings, viewerId: "author", expected: true},
+		{name: "other user", visibility: FollowersRatings, viewerId: "viewer", expected: false},
+		{name: "anonymous viewer", visibility: FollowersRatings, viewerId: "", expected: false},
+	} {
+		if visible := ratingsVisibleTo(testCase.visibility, "author", testCase.viewerId, testCase.followed); visible != testCase.expected {
+			t.Errorf("%s: expected ratings to be visible %v, got %v", testCase.name, testCase.expected, visible)
+		}
+	}
+}
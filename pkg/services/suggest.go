@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	MovieSuggestion  = "movie"
+	PersonSuggestion = "person"
+	GenreSuggestion  = "genre"
+
+	DefaultSuggestionLimit = 8
+	MaxSuggestionLimit     = 20
+
+	// popularTitleCount is the number of most voted movies kept in the
+	// suggestion cache
+	popularTitleCount = 1000
+	suggestionTTL     = 15 * time.Minute
+	// suggestionLoadTimeout bounds the background loads of the cache
+	suggestionLoadTimeout = 30 * time.Second
+	// failed loads are retried after a delay doubling from
+	// suggestionMinRetryDelay up to suggestionMaxRetryDelay
+	suggestionMinRetryDelay = time.Second
+	suggestionMaxRetryDelay = 5 * time.Minute
+)
+
+// Suggestion is a typeahead hit.
+// Movies carry their release year and people the title of their best-known
+// film, so that homonyms can be told apart.
+type Suggestion struct {
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+	Label    string `json:"label"`
+	Link     string `json:"link"`
+	Poster   string `json:"poster,omitempty"`
+	Year     *int64 `json:"year,omitempty"`
+	KnownFor string `json:"knownFor,omitempty"`
+}
+
+type SuggestService interface {
+	Suggest(ctx context.Context, query string, limit int) ([]Suggestion, error)
+}
+
+type neo4jSuggestService struct {
+	genres  GenreService
+	movies  MovieService
+	driver  neo4j.Driver
+	indexes *FullTextIndexes
+	budget  time.Duration
+	cache   *suggestionCache
+}
+
+// NewSuggestService returns a SuggestService answering from an in-process
+// cache of genres and popular titles first, and spending at most budget on
+// the database to complete the suggestions
+func NewSuggestService(genres GenreService, movies MovieService, driver neo4j.Driver, indexes *FullTextIndexes, budget time.Duration) SuggestService {
+	service := &neo4jSuggestService{
+		genres:  genres,
+		movies:  movies,
+		driver:  driver,
+		indexes: indexes,
+		budget:  budget,
+	}
+	service.cache = &suggestionCache{ttl: suggestionTTL, load: service.loadCachedSuggestions}
+	service.cache.warm()
+	return service
+}
+
+// Suggest returns up to limit genres, movies and people whose name starts
+// with the query, or contains a word starting with it.
+// Cached genres and popular titles come first. When they are not enough,
+// the database is searched within the latency budget of the service; if the
+// budget runs out, the cached suggestions are returned on their own.
+// Requests never wait for the cache to load: until it does, they only get
+// the suggestions found within the budget.
+func (ss *neo4jSuggestService) Suggest(ctx context.Context, query string, limit int) (_ []Suggestion, err error) {
+	prefix := normalizeSuggestion(query)
+	if prefix == "" {
+		return []Suggestion{}, nil
+	}
+	if limit <= 0 {
+		limit = DefaultSuggestionLimit
+	}
+	if limit > MaxSuggestionLimit {
+		limit = MaxSuggestionLimit
+	}
+
+	ss.cache.warm()
+	suggestions := ss.cache.lookup(prefix, limit)
+	if len(suggestions) >= limit {
+		return suggestions, nil
+	}
+
+	budgetCtx, cancel := context.WithTimeout(ctx, ss.budget)
+	defer cancel()
+	found, err := ss.findSuggestions(budgetCtx, prefix, limit)
+	if err != nil {
+		if budgetCtx.Err() != nil && ctx.Err() == nil {
+			return suggestions, nil
+		}
+		return nil, err
+	}
+	return mergeSuggestions(suggestions, found, limit), nil
+}
+
+// findSuggestions searches the titles of all movies and the names of all
+// people for words starting with each of the terms of prefix.
+// Scores of different indexes are not comparable, so the hits of each index
+// are scored relative to its best hit before being merged.
+func (ss *neo4jSuggestService) findSuggestions(ctx context.Context, prefix string, limit int) (_ []Suggestion, err error) {
+	_, terms := fullTextQuery(prefix, SearchOptions{})
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if err := ss.indexes.ensure(ctx); err != nil {
+		return nil, err
+	}
+
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			CALL {
+				CALL db.index.fulltext.queryNodes('movieSearch', $movieQuery) YIELD node, score
+				WITH node, score LIMIT $limit
+				WITH collect({node: node, score: score}) AS hits
+				UNWIND hits AS hit
+				WITH hit.node AS node, hit.score / hits[0].score AS score
+				RETURN 'movie' AS type, node.tmdbId AS id, node.title AS label,
+					'/movies/' + node.tmdbId AS link, node.poster AS poster,
+					node.year AS year, null AS knownFor, score
+				UNION ALL
+				CALL db.index.fulltext.queryNodes('personSearch', $personQuery) YIELD node, score
+				WITH node, score LIMIT $limit
+				WITH collect({node: node, score: score}) AS hits
+				UNWIND hits AS hit
+				WITH hit.node AS node, hit.score / hits[0].score AS score
+				CALL {
+					WITH node
+					OPTIONAL MATCH (node)-[:ACTED_IN|DIRECTED]->(m:Movie)
+					WHERE m.imdbVotes IS NOT NULL
+					WITH m ORDER BY m.imdbVotes DESC LIMIT 1
+					RETURN m.title AS knownFor
+				}
+				RETURN 'person' AS type, node.tmdbId AS id, node.name AS label,
+					'/people/' + node.tmdbId AS link, node.poster AS poster,
+					null AS year, knownFor, score
+			}
+			RETURN type, id, label, link, poster, year, knownFor
+			ORDER BY score DESC, type
+			LIMIT $limit`,
+			map[string]interface{}{
+				"movieQuery":  prefixQuery("title", terms),
+				"personQuery": prefixQuery("name", terms),
+				"limit":       limit,
+			})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		suggestions := make([]Suggestion, len(records))
+		for i, record := range records {
+			if err := mapping.DecodeRecord(record, &suggestions[i]); err != nil {
+				return nil, err
+			}
+		}
+		return suggestions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]Suggestion), nil
+}
+
+// loadCachedSuggestions returns all genres, then the most voted movies
+func (ss *neo4jSuggestService) loadCachedSuggestions(ctx context.Context) ([]Suggestion, error) {
+	genres, err := ss.genres.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page := paging.NewPaging("", "imdbVotes", "DESC", 0, popularTitleCount).
+		WithProjection(paging.NewProjection([]string{"title", "poster", "year"}, nil))
+	movies, err := ss.movies.FindAll(ctx, "", page)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]Suggestion, 0, len(genres)+len(movies))
+	for _, genre := range genres {
+		link := genre.Link
+		if link == "" {
+			link = "/genres/" + genre.Name
+		}
+		suggestions = append(suggestions, Suggestion{
+			Type:   GenreSuggestion,
+			Id:     genre.Name,
+			Label:  genre.Name,
+			Link:   link,
+			Poster: genre.Poster,
+		})
+	}
+	for _, movie := range movies {
+		suggestions = append(suggestions, Suggestion{
+			Type:   MovieSuggestion,
+			Id:     movie.TmdbId,
+			Label:  movie.Title,
+			Link:   "/movies/" + movie.TmdbId,
+			Poster: movie.Poster,
+			Year:   movie.Year,
+		})
+	}
+	return suggestions, nil
+}
+
+// suggestionCache indexes suggestions by every suffix of their normalized
+// label starting at a word, so that prefix lookups are binary searches.
+// It is loaded in the background, a single load running at a time, and stale
+// caches keep answering until the next load succeeds.
+type suggestionCache struct {
+	ttl  time.Duration
+	load func(ctx context.Context) ([]Suggestion, error)
+
+	mutex    sync.RWMutex
+	entries  []suggestionEntry
+	loadedAt time.Time
+	loading  bool
+	// failures counts the consecutive failed loads, the next one being
+	// attempted from retryAt
+	failures int
+	retryAt  time.Time
+}
+
+type suggestionEntry struct {
+	key string
+	// rank is the position of the suggestion in the loaded list
+	rank int
+	// wordStart tells whether key starts after the first word of the label
+	wordStart  bool
+	suggestion Suggestion
+}
+
+// warm starts loading the cache in the background when it is empty or stale,
+// unless a load is already running or failed loads are backing off
+func (sc *suggestionCache) warm() {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	now := time.Now()
+	fresh := !sc.loadedAt.IsZero() && now.Sub(sc.loadedAt) <= sc.ttl
+	if fresh || sc.loading || now.Before(sc.retryAt) {
+		return
+	}
+	sc.loading = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), suggestionLoadTimeout)
+		defer cancel()
+		if err := sc.refresh(ctx); err != nil {
+			log.Printf("could not load suggestions: %v", err)
+		}
+	}()
+}
+
+// refresh loads the cache, scheduling the next attempt after a failure
+func (sc *suggestionCache) refresh(ctx context.Context) error {
+	suggestions, err := sc.load(ctx)
+	var entries []suggestionEntry
+	if err == nil {
+		entries = indexSuggestions(suggestions)
+	}
+
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.loading = false
+	if err != nil {
+		sc.failures++
+		sc.retryAt = time.Now().Add(suggestionRetryDelay(sc.failures))
+		return err
+	}
+	sc.failures = 0
+	sc.retryAt = time.Time{}
+	sc.entries = entries
+	sc.loadedAt = time.Now()
+	return nil
+}
+
+// suggestionRetryDelay returns the delay before retrying after the given
+// number of consecutive failed loads
+func suggestionRetryDelay(failures int) time.Duration {
+	delay := suggestionMinRetryDelay
+	for i := 1; i < failures && delay < suggestionMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > suggestionMaxRetryDelay {
+		return suggestionMaxRetryDelay
+	}
+	return delay
+}
+
+func indexSuggestions(suggestions []Suggestion) []suggestionEntry {
+	var entries []suggestionEntry
+	for rank, suggestion := range suggestions {
+		label := normalizeSuggestion(suggestion.Label)
+		for i := range label {
+			if i == 0 || label[i-1] == ' ' {
+				entries = append(entries, suggestionEntry{
+					key:        label[i:],
+					rank:       rank,
+					wordStart:  i > 0,
+					suggestion: suggestion,
+				})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// lookup returns up to limit suggestions matching the normalized prefix,
+// labels starting with it first, then in load order
+func (sc *suggestionCache) lookup(prefix string, limit int) []Suggestion {
+	sc.mutex.RLock()
+	entries := sc.entries
+	sc.mutex.RUnlock()
+
+	var matches []suggestionEntry
+	for i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].key >= prefix
+	}); i < len(entries) && strings.HasPrefix(entries[i].key, prefix); i++ {
+		matches = append(matches, entries[i])
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].wordStart != matches[j].wordStart {
+			return !matches[i].wordStart
+		}
+		return matches[i].rank < matches[j].rank
+	})
+
+	suggestions := make([]Suggestion, 0, limit)
+	for _, match := range matches {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = mergeSuggestions(suggestions, []Suggestion{match.suggestion}, limit)
+	}
+	return suggestions
+}
+
+// mergeSuggestions appends the additional suggestions not already present,
+// up to limit
+func mergeSuggestions(suggestions []Suggestion, additional []Suggestion, limit int) []Suggestion {
+	for _, candidate := range additional {
+		if len(suggestions) >= limit {
+			break
+		}
+		duplicate := false
+		for _, suggestion := range suggestions {
+			if suggestion.Type == candidate.Type && suggestion.Id == candidate.Id {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions
+}
+
+// normalizeSuggestion lower-cases text and reduces it to words separated by
+// single spaces
+func normalizeSuggestion(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	}), " ")
+}
+
+// prefixQuery returns a Lucene query requiring each of the terms to prefix a
+// word of field
+func prefixQuery(field string, terms []string) string {
+	clauses := make([]string, len(terms))
+	for i, term := range terms {
+		clauses[i] = fmt.Sprintf("+%s:%s*", field, term)
+	}
+	return strings.Join(clauses, " ")
+}
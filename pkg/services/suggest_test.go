@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuggestionCache(outer *testing.T) {
+	loads := 0
+	cache := &suggestionCache{ttl: time.Hour, load: func(ctx context.Context) ([]Suggestion, error) {
+		loads++
+		return []Suggestion{
+			{Type: GenreSuggestion, Id: "Drama", Label: "Drama"},
+			{Type: MovieSuggestion, Id: "238", Label: "The Godfather"},
+			{Type: MovieSuggestion, Id: "240", Label: "The Godfather: Part II"},
+			{Type: MovieSuggestion, Id: "9598", Label: "Godzilla"},
+		}, nil
+	}}
+	if err := cache.refresh(context.Background()); err != nil {
+		outer.Fatal(err)
+	}
+	ids := func(suggestions []Suggestion) []string {
+		result := make([]string, len(suggestions))
+		for i, suggestion := range suggestions {
+			result[i] = suggestion.Id
+		}
+		return result
+	}
+
+	outer.Run("matches labels and words by prefix", func(t *testing.T) {
+		actual := ids(cache.lookup("god", 10))
+		expected := []string{"9598", "238", "240"}
+		if len(actual) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+		for i := range expected {
+			if actual[i] != expected[i] {
+				t.Fatalf("expected %v, got %v", expected, actual)
+			}
+		}
+	})
+
+	outer.Run("matches across words", func(t *testing.T) {
+		actual := ids(cache.lookup(normalizeSuggestion("Godfather: part"), 10))
+		if len(actual) != 1 || actual[0] != "240" {
+			t.Fatalf("unexpected suggestions %v", actual)
+		}
+	})
+
+	outer.Run("limits suggestions", func(t *testing.T) {
+		if actual := cache.lookup("god", 2); len(actual) != 2 {
+			t.Fatalf("unexpected suggestions %v", ids(actual))
+		}
+	})
+
+	outer.Run("loads only once while fresh", func(t *testing.T) {
+		cache.warm()
+		if loads != 1 {
+			t.Fatalf("expected a single load, got %d", loads)
+		}
+	})
+}
+
+func TestSuggestionCacheWarm(outer *testing.T) {
+	outer.Run("runs a single load at a time", func(t *testing.T) {
+		started, release := make(chan struct{}, 10), make(chan struct{})
+		cache := &suggestionCache{ttl: time.Hour, load: func(ctx context.Context) ([]Suggestion, error) {
+			started <- struct{}{}
+			<-release
+			return []Suggestion{{Type: GenreSuggestion, Id: "Drama", Label: "Drama"}}, nil
+		}}
+		for i := 0; i < 5; i++ {
+			cache.warm()
+		}
+		<-started
+		if cached := cache.lookup("dra", 10); len(cached) != 0 {
+			t.Fatalf("expected nothing until loaded, got %v", cached)
+		}
+		close(release)
+		for deadline := time.Now().Add(time.Second); len(cache.lookup("dra", 10)) == 0; {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the cache to load")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if len(started) != 0 {
+			t.Fatalf("expected a single load, got %d more", len(started))
+		}
+	})
+
+	outer.Run("backs off after failures", func(t *testing.T) {
+		loads := 0
+		cache := &suggestionCache{ttl: time.Hour, load: func(ctx context.Context) ([]Suggestion, error) {
+			loads++
+			return nil, errors.New("unavailable")
+		}}
+		if err := cache.refresh(context.Background()); err == nil {
+			t.Fatal("expected the load to fail")
+		}
+		cache.warm()
+		if loads != 1 || cache.loading {
+			t.Fatalf("expected no load before %v, got %d loads", cache.retryAt, loads)
+		}
+	})
+}
+
+func TestSuggestionRetryDelay(t *testing.T) {
+	for failures, expected := range map[int]time.Duration{
+		1:   time.Second,
+		2:   2 * time.Second,
+		4:   8 * time.Second,
+		100: suggestionMaxRetryDelay,
+	} {
+		if delay := suggestionRetryDelay(failures); delay != expected {
+			t.Errorf("expected %v after %d failures, got %v", expected, failures, delay)
+		}
+	}
+}
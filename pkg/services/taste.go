@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// TasteMatched taste matches carry their coefficients and details
+	TasteMatched = "matched"
+	// InsufficientTasteData taste matches only tell how many movies both
+	// users rated, fewer than MinCoRated
+	InsufficientTasteData = "insufficient_data"
+
+	// minCoRated is the number of movies two users must both have rated to
+	// compare their tastes
+	minCoRated = 5
+	// maxTasteExamples bounds the agreements, disagreements and genres of a
+	// taste match
+	maxTasteExamples = 5
+	// minGenreCoRated is the number of co-rated movies of a genre needed to
+	// compare the tastes of two users in that genre
+	minGenreCoRated = 2
+)
+
+// CoRating is a movie rated by two users
+type CoRating struct {
+	Movie       Movie    `json:"movie"`
+	Rating      float64  `json:"rating"`
+	OtherRating float64  `json:"otherRating"`
+	Genres      []string `neo4j:"genres" json:"-"`
+}
+
+// GenreTaste compares the ratings two users gave to the movies of a genre.
+// Difference is the mean absolute difference of their ratings.
+type GenreTaste struct {
+	Genre           string  `json:"genre"`
+	Movies          int     `json:"movies"`
+	MeanRating      float64 `json:"meanRating"`
+	OtherMeanRating float64 `json:"otherMeanRating"`
+	Difference      float64 `json:"difference"`
+}
+
+// TasteMatch compares the ratings of the current user with another user's,
+// over the movies they both rated.
+// Pearson is undefined when either user gave all those movies the same
+// rating, Cosine is then the only coefficient.
+type TasteMatch struct {
+	Status        string       `json:"status"`
+	CoRated       int          `json:"coRated"`
+	MinCoRated    int          `json:"minCoRated"`
+	Pearson       *float64     `json:"pearson,omitempty"`
+	Cosine        *float64     `json:"cosine,omitempty"`
+	Agreements    []CoRating   `json:"agreements,omitempty"`
+	Disagreements []CoRating   `json:"disagreements,omitempty"`
+	Genres        []GenreTaste `json:"genres,omitempty"`
+}
+
+// FindTasteMatch compares the ratings of the user with the other user's.
+// If the other user cannot be found, a 404 DomainError is returned, and a 403
+// one when they hide their ratings from the user.
+func (ss *neo4jSocialService) FindTasteMatch(ctx context.Context, userId, otherId string) (_ *TasteMatch, err error) {
+	if userId == otherId {
+		return nil, NewDomainError(422, "Compare your taste with another user", map[string]interface{}{"userId": otherId})
+	}
+
+	session := ss.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		visible, err := findRatingsVisible(ctx, tx, otherId, userId)
+		if err != nil {
+			return nil, err
+		}
+		if visible == nil {
+			return nil, NewDomainError(404, "User not found", map[string]interface{}{"userId": otherId})
+		}
+		if !*visible {
+			return nil, NewDomainError(403, "The ratings of this user are only visible to their followers", map[string]interface{}{"userId": otherId})
+		}
+
+		result, err := tx.Run(`
+			MATCH (:User {userId: $userId})-[mine:RATED]->(m:Movie)<-[theirs:RATED]-(:User {userId: $otherId})
+			RETURN m { .tmdbId, .title, .poster } AS movie,
+				mine.rating AS rating, theirs.rating AS otherRating,
+				[ (m)-[:IN_GENRE]->(g:Genre) | g.name ] AS genres`,
+			map[string]interface{}{"userId": userId, "otherId": otherId})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		coRatings := make([]CoRating, len(records))
+		for i, record := range records {
+			if err := mapping.DecodeRecord(record, &coRatings[i]); err != nil {
+				return nil, err
+			}
+		}
+		return newTasteMatch(coRatings), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*TasteMatch), nil
+}
+
+// newTasteMatch computes the coefficients of the co-ratings, and picks the
+// closest ratings, the furthest apart ones and the genres differing most
+func newTasteMatch(coRatings []CoRating) *TasteMatch {
+	match := &TasteMatch{Status: InsufficientTasteData, CoRated: len(coRatings), MinCoRated: minCoRated}
+	if len(coRatings) < minCoRated {
+		return match
+	}
+	match.Status = TasteMatched
+
+	mine := make([]float64, len(coRatings))
+	theirs := make([]float64, len(coRatings))
+	for i, coRating := range coRatings {
+		mine[i], theirs[i] = coRating.Rating, coRating.OtherRating
+	}
+	match.Pearson = pearson(mine, theirs)
+	match.Cosine = cosine(mine, theirs)
+
+	distance := func(coRating CoRating) float64 {
+		return math.Abs(coRating.Rating - coRating.OtherRating)
+	}
+	sorted := append([]CoRating(nil), coRatings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if distance(sorted[i]) != distance(sorted[j]) {
+			return distance(sorted[i]) < distance(sorted[j])
+		}
+		// agreeing on loving a movie says more than agreeing on a shrug
+		return sorted[i].Rating+sorted[i].OtherRating > sorted[j].Rating+sorted[j].OtherRating
+	})
+	for _, coRating := range sorted {
+		if len(match.Agreements) == maxTasteExamples {
+			break
+		}
+		match.Agreements = append(match.Agreements, coRating)
+	}
+	for i := len(sorted) - 1; i >= 0 && len(match.Disagreements) < maxTasteExamples && distance(sorted[i]) > 0; i-- {
+		match.Disagreements = append(match.Disagreements, sorted[i])
+	}
+
+	tastes := map[string]*GenreTaste{}
+	for _, coRating := range coRatings {
+		for _, genre := range coRating.Genres {
+			taste, found := tastes[genre]
+			if !found {
+				taste = &GenreTaste{Genre: genre}
+				tastes[genre] = taste
+			}
+			taste.Movies++
+			taste.MeanRating += coRating.Rating
+			taste.OtherMeanRating += coRating.OtherRating
+			taste.Difference += distance(coRating)
+		}
+	}
+	for _, taste := range tastes {
+		if taste.Movies < minGenreCoRated {
+			continue
+		}
+		movies := float64(taste.Movies)
+		taste.MeanRating /= movies
+		taste.OtherMeanRating /= movies
+		taste.Difference /= movies
+		match.Genres = append(match.Genres, *taste)
+	}
+	sort.Slice(match.Genres, func(i, j int) bool {
+		if match.Genres[i].Difference != match.Genres[j].Difference {
+			return match.Genres[i].Difference > match.Genres[j].Difference
+		}
+		return match.Genres[i].Genre < match.Genres[j].Genre
+	})
+	if len(match.Genres) > maxTasteExamples {
+		match.Genres = match.Genres[:maxTasteExamples]
+	}
+	return match
+}
+
+// pearson returns the Pearson correlation coefficient of x and y, nil when
+// either has no variance
+func pearson(x, y []float64) *float64 {
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+	var covariance, varianceX, varianceY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+	if varianceX == 0 || varianceY == 0 {
+		return nil
+	}
+	coefficient := covariance / math.Sqrt(varianceX*varianceY)
+	return &coefficient
+}
+
+// cosine returns the cosine similarity of x and y, nil when either is null
+func cosine(x, y []float64) *float64 {
+	var dot, normX, normY float64
+	for i := range x {
+		dot += x[i] * y[i]
+		normX += x[i] * x[i]
+		normY += y[i] * y[i]
+	}
+	if normX == 0 || normY == 0 {
+		return nil
+	}
+	coefficient := dot / math.Sqrt(normX*normY)
+	return &coefficient
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func coRating(title string, rating, otherRating float64, genres ...string) CoRating {
+	return CoRating{Movie: Movie{Title: title}, Rating: rating, OtherRating: otherRating, Genres: genres}
+}
+
+func TestNewTasteMatchInsufficientData(t *testing.T) {
+	match := newTasteMatch([]CoRating{coRating("Heat", 5, 4), coRating("Alien", 3, 3)})
+	if match.Status != InsufficientTasteData || match.CoRated != 2 || match.MinCoRated != minCoRated {
+		t.Errorf("expected insufficient data over 2 movies, got %+v", match)
+	}
+	if match.Pearson != nil || match.Cosine != nil || match.Agreements != nil {
+		t.Errorf("expected no coefficients nor details, got %+v", match)
+	}
+}
+
+func TestNewTasteMatch(t *testing.T) {
+	match := newTasteMatch([]CoRating{
+		coRating("Heat", 5, 5, "Crime", "Drama"),
+		coRating("Alien", 4, 4, "Horror"),
+		coRating("Casino", 4, 2, "Crime", "Drama"),
+		coRating("Saw", 1, 5, "Horror"),
+		coRating("Up", 3, 3.5, "Animation"),
+		coRating("Jaws", 2, 2, "Horror"),
+	})
+	if match.Status != TasteMatched || match.CoRated != 6 {
+		t.Fatalf("expected a match over 6 movies, got %+v", match)
+	}
+	if match.Pearson == nil || match.Cosine == nil {
+		t.Fatalf("expected both coefficients, got %+v", match)
+	}
+	if len(match.Agreements) != maxTasteExamples || match.Agreements[0].Movie.Title != "Heat" || match.Agreements[2].Movie.Title != "Jaws" {
+		t.Errorf("expected identical ratings first, highest first, got %+v", match.Agreements)
+	}
+	if len(match.Disagreements) != 3 || match.Disagreements[0].Movie.Title != "Saw" || match.Disagreements[2].Movie.Title != "Up" {
+		t.Errorf("expected the 3 differing ratings, furthest first, got %+v", match.Disagreements)
+	}
+	if len(match.Genres) != 3 || match.Genres[0].Genre != "Horror" || match.Genres[0].Movies != 3 {
+		t.Fatalf("expected Horror, Crime and Drama, got %+v", match.Genres)
+	}
+	if horror := match.Genres[0]; math.Abs(horror.Difference-4.0/3) > 1e-9 || horror.OtherMeanRating != 11.0/3 {
+		t.Errorf("unexpected Horror taste %+v", horror)
+	}
+	if match.Genres[1].Genre != "Crime" || match.Genres[1].Difference != 1 {
+		t.Errorf("expected Crime to tie with Drama before it, got %+v", match.Genres)
+	}
+}
+
+func TestPearson(t *testing.T) {
+	if coefficient := pearson([]float64{1, 2, 3}, []float64{2, 4, 6}); coefficient == nil || math.Abs(*coefficient-1) > 1e-9 {
+		t.Errorf("expected 1, got %v", coefficient)
+	}
+	if coefficient := pearson([]float64{1, 2, 3}, []float64{3, 2, 1}); coefficient == nil || math.Abs(*coefficient+1) > 1e-9 {
+		t.Errorf("expected -1, got %v", coefficient)
+	}
+	if coefficient := pearson([]float64{4, 4, 4}, []float64{1, 2, 3}); coefficient != nil {
+		t.Errorf("expected no coefficient without variance, got %v", *coefficient)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if coefficient := cosine([]float64{4, 4, 4}, []float64{2, 2, 2}); coefficient == nil || math.Abs(*coefficient-1) > 1e-9 {
+		t.Errorf("expected 1, got %v", coefficient)
+	}
+	if coefficient := cosine([]float64{3, 4}, []float64{4, -3}); coefficient == nil || math.Abs(*coefficient) > 1e-9 {
+		t.Errorf("expected 0, got %v", coefficient)
+	}
+}
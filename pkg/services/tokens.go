@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/services/jwtutils"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const refreshTokenBytes = 32
+
+// TokenSettings configure the lifetime of access tokens, and of the refresh
+// tokens exchanged for new access tokens when they expire
+type TokenSettings struct {
+	AccessTokenTtl  time.Duration
+	RefreshTokenTtl time.Duration
+}
+
+func DefaultTokenSettings() TokenSettings {
+	return TokenSettings{AccessTokenTtl: 15 * time.Minute, RefreshTokenTtl: 30 * 24 * time.Hour}
+}
+
+// revokedToken is the ID of a revoked access token, denied until it expires
+type revokedToken struct {
+	Id        string
+	ExpiresAt time.Time
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token, the presented one being rotated out.
+// Presenting a rotated refresh token again means it leaked: its whole family,
+// i.e. the tokens descending from the same login, is revoked along with the
+// access tokens issued with them.
+func (as *neo4jAuthService) Refresh(ctx context.Context, refreshToken string) (_ *User, err error) {
+	session := as.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	// reuse is reported once the revocation of the family is committed
+	var reused bool
+	var revoked []revokedToken
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		reused, revoked = false, nil
+		result, err := tx.Run(`
+			MATCH (u:User)-[:HAS_REFRESH_TOKEN]->(t:RefreshToken {hash: $hash})
+			SET t._lock = true
+			REMOVE t._lock
+			RETURN u, t.family AS family,
+				t.rotatedAt IS NOT NULL AS rotated,
+				t.expiresAt <= datetime() AS expired`,
+			map[string]interface{}{"hash": hashRefreshToken(refreshToken)})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, NewDomainError(401, "Invalid refresh token", nil)
+		}
+		record := records[0]
+		family, _ := record.Get("family")
+		if rotated, _ := record.Get("rotated"); rotated == true {
+			reused = true
+			revoked, err = revokeTokenFamily(ctx, tx, family.(string))
+			return nil, err
+		}
+		if expired, _ := record.Get("expired"); expired == true {
+			return nil, NewDomainError(401, "Refresh token expired", nil)
+		}
+		if err := run(tx, `
+			MATCH (t:RefreshToken {hash: $hash})
+			SET t.rotatedAt = datetime()`,
+			map[string]interface{}{"hash": hashRefreshToken(refreshToken)}); err != nil {
+			return nil, err
+		}
+		node, _ := record.Get("u")
+		user, err := UserFromNode(node.(neo4j.Node))
+		if err != nil {
+			return nil, err
+		}
+		return as.issueTokens(tx, user, family.(string))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		as.denylist.add(revoked...)
+		return nil, NewDomainError(401, "Refresh token reused, log in again", nil)
+	}
+	return result.(*User), nil
+}
+
+// Logout revokes the access token, as well as the family of refresh tokens it
+// was issued with
+func (as *neo4jAuthService) Logout(ctx context.Context, bearer string) (err error) {
+	claims, err := as.parseAccessToken(bearer)
+	if err != nil {
+		return err
+	}
+	id := claims["jti"].(string)
+	expiration, ok := claims["exp"].(float64)
+	if !ok {
+		return NewDomainError(401, "Invalid token expiration", nil)
+	}
+	expiresAt := time.Unix(int64(expiration), 0)
+
+	session := as.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		if err := run(tx, `
+			MERGE (r:RevokedToken {id: $id})
+			SET r.expiresAt = $expiresAt`,
+			map[string]interface{}{"id": id, "expiresAt": expiresAt}); err != nil {
+			return nil, err
+		}
+		result, err := tx.Run(`
+			MATCH (t:RefreshToken {accessTokenId: $id})
+			RETURN t.family AS family`,
+			map[string]interface{}{"id": id})
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		revoked := []revokedToken{{Id: id, ExpiresAt: expiresAt}}
+		for _, record := range records {
+			family, _ := record.Get("family")
+			familyTokens, err := revokeTokenFamily(ctx, tx, family.(string))
+			if err != nil {
+				return nil, err
+			}
+			revoked = append(revoked, familyTokens...)
+		}
+		return revoked, nil
+	})
+	if err != nil {
+		return err
+	}
+	as.denylist.add(result.([]revokedToken)...)
+	return nil
+}
+
+// issueTokens signs a new access token for the user, and stores the hash of a
+// new refresh token of the family on the user.
+// A family gathers the refresh tokens rotated from the same login.
+// The expired refresh tokens of the user are deleted on the way.
+func (as *neo4jAuthService) issueTokens(tx neo4j.Transaction, user User, family string) (*User, error) {
+	id, err := newTokenId()
+	if err != nil {
+		return nil, err
+	}
+	token, err := jwtutils.Sign(user.UserId, id, as.tokens.AccessTokenTtl, userToClaims(user), as.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := run(tx, `
+		MATCH (u:User {userId: $userId})
+		CALL {
+			WITH u
+			MATCH (u)-[:HAS_REFRESH_TOKEN]->(expired:RefreshToken)
+			WHERE expired.expiresAt <= datetime()
+			DETACH DELETE expired
+		}
+		CREATE (u)-[:HAS_REFRESH_TOKEN]->(:RefreshToken {
+			hash: $hash,
+			family: $family,
+			createdAt: datetime(),
+			expiresAt: $expiresAt,
+			accessTokenId: $accessTokenId,
+			accessExpiresAt: $accessExpiresAt
+		})`,
+		map[string]interface{}{
+			"userId":          user.UserId,
+			"hash":            hashRefreshToken(refreshToken),
+			"family":          family,
+			"expiresAt":       now.Add(as.tokens.RefreshTokenTtl),
+			"accessTokenId":   id,
+			"accessExpiresAt": now.Add(as.tokens.AccessTokenTtl),
+		}); err != nil {
+		return nil, err
+	}
+	return userWithTokens(user, token, refreshToken), nil
+}
+
+// revokeTokenFamily deletes the refresh tokens of the family, and denies the
+// access tokens issued with them that have not expired yet
+func revokeTokenFamily(ctx context.Context, tx neo4j.Transaction, family string) ([]revokedToken, error) {
+	result, err := tx.Run(`
+		MATCH (t:RefreshToken {family: $family})
+		WITH collect(t) AS tokens,
+			collect(CASE WHEN t.accessExpiresAt > datetime()
+				THEN {id: t.accessTokenId, expiresAt: t.accessExpiresAt} END) AS access
+		FOREACH (t IN tokens | DETACH DELETE t)
+		WITH access
+		UNWIND access AS token
+		MERGE (r:RevokedToken {id: token.id})
+		SET r.expiresAt = token.expiresAt
+		RETURN r.id AS id, r.expiresAt AS expiresAt`,
+		map[string]interface{}{"family": family})
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	return revokedTokens(records), nil
+}
+
+func revokedTokens(records []*neo4j.Record) []revokedToken {
+	tokens := make([]revokedToken, 0, len(records))
+	for _, record := range records {
+		id, _ := record.Get("id")
+		expiresAt, _ := record.Get("expiresAt")
+		tokens = append(tokens, revokedToken{Id: id.(string), ExpiresAt: expiresAt.(time.Time)})
+	}
+	return tokens
+}
+
+const (
+	// revokedTokensTtl bounds the time a token revoked by another server is
+	// still accepted by this one
+	revokedTokensTtl         = 30 * time.Second
+	revokedTokensLoadTimeout = 10 * time.Second
+)
+
+// tokenDenylist holds the IDs of the revoked access tokens until they expire.
+// It is reloaded from the RevokedToken nodes in the background every ttl, so
+// that revocations survive restarts and reach every server, and is updated
+// right away by the revocations of this server.
+// A failed load is logged and retried after ttl, the tokens loaded before
+// being still denied.
+type tokenDenylist struct {
+	ttl  time.Duration
+	load func(ctx context.Context) ([]revokedToken, error)
+
+	mutex    sync.RWMutex
+	revoked  map[string]time.Time
+	loadedAt time.Time
+	loading  bool
+	retryAt  time.Time
+}
+
+func newTokenDenylist(load func(ctx context.Context) ([]revokedToken, error), ttl time.Duration) *tokenDenylist {
+	return &tokenDenylist{ttl: ttl, load: load, revoked: map[string]time.Time{}}
+}
+
+// contains returns whether the access token identified by id was revoked,
+// starting a reload of the denylist when it is stale
+func (td *tokenDenylist) contains(id string) bool {
+	td.mutex.RLock()
+	expiresAt, found := td.revoked[id]
+	stale := td.stale(time.Now())
+	td.mutex.RUnlock()
+	if stale {
+		td.warm()
+	}
+	return found && time.Now().Before(expiresAt)
+}
+
+// stale tells whether a reload is due and none is running, the mutex being
+// held
+func (td *tokenDenylist) stale(now time.Time) bool {
+	return now.Sub(td.loadedAt) > td.ttl && !td.loading && !now.Before(td.retryAt)
+}
+
+// warm starts reloading the denylist in the background when it is stale
+func (td *tokenDenylist) warm() {
+	td.mutex.Lock()
+	defer td.mutex.Unlock()
+	if !td.stale(time.Now()) {
+		return
+	}
+	td.loading = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), revokedTokensLoadTimeout)
+		defer cancel()
+		if err := td.refresh(ctx); err != nil {
+			log.Printf("could not load revoked tokens: %v", err)
+		}
+	}()
+}
+
+// refresh adds the loaded tokens to the denylist, scheduling the next attempt
+// after a failure
+func (td *tokenDenylist) refresh(ctx context.Context) error {
+	tokens, err := td.load(ctx)
+
+	td.mutex.Lock()
+	defer td.mutex.Unlock()
+	td.loading = false
+	if err != nil {
+		td.retryAt = time.Now().Add(td.ttl)
+		return err
+	}
+	td.retryAt = time.Time{}
+	td.loadedAt = time.Now()
+	td.addLocked(tokens)
+	return nil
+}
+
+func (td *tokenDenylist) add(tokens ...revokedToken) {
+	td.mutex.Lock()
+	defer td.mutex.Unlock()
+	td.addLocked(tokens)
+}
+
+// addLocked adds the tokens that have not expired and prunes the expired
+// ones, the mutex being held
+func (td *tokenDenylist) addLocked(tokens []revokedToken) {
+	now := time.Now()
+	for id, expiresAt := range td.revoked {
+		if !now.Before(expiresAt) {
+			delete(td.revoked, id)
+		}
+	}
+	for _, token := range tokens {
+		if now.Before(token.ExpiresAt) {
+			td.revoked[token.Id] = token.ExpiresAt
+		}
+	}
+}
+
+// loadRevokedTokens reads the revoked tokens that have not expired, deleting
+// the others
+func loadRevokedTokens(ctx context.Context, driver neo4j.Driver) (_ []revokedToken, err error) {
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		if err := run(tx, `
+			MATCH (r:RevokedToken)
+			WHERE r.expiresAt <= datetime()
+			DELETE r`, nil); err != nil {
+			return nil, err
+		}
+		result, err := tx.Run(`
+			MATCH (r:RevokedToken)
+			RETURN r.id AS id, r.expiresAt AS expiresAt`, nil)
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		return revokedTokens(records), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]revokedToken), nil
+}
+
+// newTokenId returns a random access token ID, or refresh token family ID
+func newTokenId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// newRefreshToken returns a random, opaque refresh token
+func newRefreshToken() (string, error) {
+	token := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashRefreshToken returns the hash under which refresh tokens are stored.
+// Refresh tokens are random enough for a fast, unsalted hash, which keeps them
+// searchable.
+func hashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/services/jwtutils"
+)
+
+func newTestAuthService() *neo4jAuthService {
+	return &neo4jAuthService{jwtSecret: "secret", tokens: DefaultTokenSettings(), denylist: newTestTokenDenylist(nil)}
+}
+
+// newTestTokenDenylist returns a denylist loaded from tokens, which is not
+// reloaded during tests
+func newTestTokenDenylist(tokens []revokedToken) *tokenDenylist {
+	denylist := newTokenDenylist(func(ctx context.Context) ([]revokedToken, error) {
+		return tokens, nil
+	}, time.Hour)
+	if err := denylist.refresh(context.Background()); err != nil {
+		panic(err)
+	}
+	return denylist
+}
+
+func TestExtractPrincipal(t *testing.T) {
+	as := newTestAuthService()
+	user := User{UserId: "u1", Roles: []string{AdminRole}}
+	token, err := jwtutils.Sign(user.UserId, "t1", time.Minute, userToClaims(user), as.jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := as.ExtractPrincipal(context.Background(), token)
+	if err != nil || principal.UserId != "u1" || !principal.HasRole(AdminRole) {
+		t.Fatalf("expected admin u1, got %+v, %v", principal, err)
+	}
+
+	as.denylist.add(revokedToken{Id: "t1", ExpiresAt: time.Now().Add(time.Minute)})
+	if _, err := as.ExtractUserId(context.Background(), token); !isUnauthorized(err) {
+		t.Errorf("expected a revoked token to be rejected with a 401 error, got %v", err)
+	}
+}
+
+func TestExtractUserIdRejectsInvalidTokens(t *testing.T) {
+	as := newTestAuthService()
+	user := User{UserId: "u1"}
+	expired, _ := jwtutils.Sign(user.UserId, "t1", -time.Minute, userToClaims(user), as.jwtSecret)
+	withoutId, _ := jwtutils.Sign(user.UserId, "", time.Minute, userToClaims(user), as.jwtSecret)
+	forged, _ := jwtutils.Sign(user.UserId, "t2", time.Minute, userToClaims(user), "forged")
+	for name, token := range map[string]string{"expired": expired, "without ID": withoutId, "forged": forged, "malformed": "token"} {
+		if _, err := as.ExtractUserId(context.Background(), token); !isUnauthorized(err) {
+			t.Errorf("expected a 401 error for the %s token, got %v", name, err)
+		}
+	}
+	if userId, err := as.ExtractUserId(context.Background(), ""); userId != "" || err != nil {
+		t.Errorf("expected anonymous requests to be accepted, got %q, %v", userId, err)
+	}
+}
+
+func TestTokenDenylist(outer *testing.T) {
+	outer.Run("denies revoked tokens until they expire", func(t *testing.T) {
+		denylist := newTestTokenDenylist(nil)
+		denylist.add(
+			revokedToken{Id: "live", ExpiresAt: time.Now().Add(time.Minute)},
+			revokedToken{Id: "expired", ExpiresAt: time.Now().Add(-time.Minute)},
+		)
+		if !denylist.contains("live") {
+			t.Errorf("expected the live token to be denied")
+		}
+		if _, found := denylist.revoked["expired"]; found {
+			t.Errorf("expected expired tokens not to be kept")
+		}
+		denylist.revoked["stale"] = time.Now().Add(-time.Second)
+		if denylist.contains("stale") {
+			t.Errorf("expected the stale token to be accepted")
+		}
+	})
+
+	outer.Run("denies the tokens revoked by other servers once reloaded", func(t *testing.T) {
+		var stored []revokedToken
+		denylist := newTokenDenylist(func(ctx context.Context) ([]revokedToken, error) {
+			return stored, nil
+		}, time.Hour)
+		denylist.add(revokedToken{Id: "local", ExpiresAt: time.Now().Add(time.Minute)})
+		stored = []revokedToken{{Id: "remote", ExpiresAt: time.Now().Add(time.Minute)}}
+		if err := denylist.refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if !denylist.contains("remote") || !denylist.contains("local") {
+			t.Errorf("expected both tokens to be denied, got %v", denylist.revoked)
+		}
+	})
+
+	outer.Run("keeps denying loaded tokens after a failed reload", func(t *testing.T) {
+		loads := 0
+		denylist := newTestTokenDenylist([]revokedToken{{Id: "revoked", ExpiresAt: time.Now().Add(time.Minute)}})
+		denylist.loadedAt = time.Time{}
+		denylist.load = func(ctx context.Context) ([]revokedToken, error) {
+			loads++
+			return nil, errors.New("unavailable")
+		}
+		if err := denylist.refresh(context.Background()); err == nil {
+			t.Fatal("expected the reload to fail")
+		}
+		if !denylist.contains("revoked") || denylist.contains("other") {
+			t.Errorf("expected the loaded tokens to still be denied, got %v", denylist.revoked)
+		}
+		if loads != 1 || denylist.loading {
+			t.Errorf("expected no reload before %v, got %d loads", denylist.retryAt, loads)
+		}
+	})
+}
+
+func TestRefreshTokens(t *testing.T) {
+	first, err := newRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := newRefreshToken()
+	if first == second || len(first) != 43 {
+		t.Errorf("expected distinct 256-bit tokens, got %q and %q", first, second)
+	}
+	if hash := hashRefreshToken(first); hash != hashRefreshToken(first) || len(hash) != 64 || hash == hashRefreshToken(second) {
+		t.Errorf("expected a stable SHA-256 hash, got %q", hash)
+	}
+}
+
+func isUnauthorized(err error) bool {
+	domainError, ok := err.(*DomainError)
+	return ok && domainError.StatusCode() == 401
+}
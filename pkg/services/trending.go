@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// TrendingSort orders movies by their trending score, most trending first
+	// unless the order is given
+	TrendingSort = "trending"
+
+	ratingActivityWeight   = 1.0
+	favoriteActivityWeight = 2.0
+
+	// trendingHalfLives bounds the activity taken into account, older
+	// activity weighing less than 0.4% of fresh activity
+	trendingHalfLives = 8
+
+	trendingRefreshTimeout = 2 * time.Minute
+
+	// trendingLeaseName identifies the TrendingLease node of the refreshes
+	trendingLeaseName = "trending"
+	// trendingLeaseSlack keeps the ticker of the instance holding the lease
+	// from firing just before the lease expires and skipping a refresh
+	trendingLeaseSlack = 5 * time.Second
+)
+
+// TrendingSettings configure the trending score of movies: the weight of an
+// activity halves every HalfLife, and scores are refreshed every
+// RefreshInterval
+type TrendingSettings struct {
+	HalfLife        time.Duration
+	RefreshInterval time.Duration
+}
+
+func DefaultTrendingSettings() TrendingSettings {
+	return TrendingSettings{HalfLife: 72 * time.Hour, RefreshInterval: 15 * time.Minute}
+}
+
+// decayRate returns the exponential decay rate of activity, per second
+func (ts TrendingSettings) decayRate() float64 {
+	return math.Ln2 / ts.HalfLife.Seconds()
+}
+
+// TrendingWorker periodically stores the trending score of movies under
+// their `trending` property, from the ratings and favorites of users.
+// Every rating counts ratingActivityWeight and every favorite
+// favoriteActivityWeight, decayed by their age.
+// Favorites count from their `createdAt` datetime, and are ignored without it.
+// Movies without recent activity have no trending score.
+//
+// Every instance of the server runs a worker, but only one of them refreshes
+// the scores per refresh interval: refreshes claim a lease stored on a
+// TrendingLease node, and are skipped while another instance holds it.
+type TrendingWorker struct {
+	driver   neo4j.Driver
+	settings TrendingSettings
+}
+
+func NewTrendingWorker(driver neo4j.Driver, settings TrendingSettings) *TrendingWorker {
+	return &TrendingWorker{driver: driver, settings: settings}
+}
+
+// Run refreshes the trending scores right away, then every refresh interval
+// until ctx is done.
+// Failed refreshes are logged, the previous scores being kept until the next
+// attempt.
+func (tw *TrendingWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(tw.settings.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		refreshCtx, cancel := context.WithTimeout(ctx, trendingRefreshTimeout)
+		if err := tw.Refresh(refreshCtx); err != nil {
+			log.Printf("could not refresh trending movies: %v", err)
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh replaces the trending scores of all movies in a single transaction,
+// unless another instance already did within the refresh interval.
+// The lease is claimed in the same transaction, so that the lease node stays
+// write-locked until the scores are replaced, and is released by a failed
+// refresh.
+func (tw *TrendingWorker) Refresh(ctx context.Context) (err error) {
+	session := tw.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	now := time.Now().Unix()
+	_, err = writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		claimed, err := tw.claimLease(ctx, tx, now)
+		if err != nil || !claimed {
+			return nil, err
+		}
+		if err := run(tx, `
+			MATCH (m:Movie)
+			WHERE m.trending IS NOT NULL
+			REMOVE m.trending`, nil); err != nil {
+			return nil, err
+		}
+		return nil, run(tx, fmt.Sprintf(`
+			CALL {
+				MATCH (m:Movie)<-[r:RATED]-(:User)
+				WHERE r.timestamp >= $since
+				RETURN m, $ratingWeight * exp(-$decay * ($now - r.timestamp)) AS activity
+				UNION ALL
+				MATCH (m:Movie)<-[f:HAS_FAVORITE]-(:User)
+				WHERE f.createdAt >= datetime({epochSeconds: $since})
+				RETURN m, $favoriteWeight * exp(-$decay * ($now - f.createdAt.epochSeconds)) AS activity
+			}
+			WITH m, sum(activity) AS trending
+			SET %s = trending`, property("m", TrendingSort)),
+			map[string]interface{}{
+				"now":            now,
+				"since":          now - int64(trendingHalfLives*tw.settings.HalfLife.Seconds()),
+				"decay":          tw.settings.decayRate(),
+				"ratingWeight":   ratingActivityWeight,
+				"favoriteWeight": favoriteActivityWeight,
+			})
+	})
+	return err
+}
+
+// claimLease write-locks the TrendingLease node and takes the lease when it
+// was last taken more than a refresh interval ago
+func (tw *TrendingWorker) claimLease(ctx context.Context, tx neo4j.Transaction, now int64) (bool, error) {
+	result, err := tx.Run(`
+		MERGE (lease:TrendingLease {name: $name})
+		SET lease._lock = true
+		REMOVE lease._lock
+		WITH lease
+		WHERE coalesce(lease.refreshedAt, 0) <= $now - $interval
+		SET lease.refreshedAt = $now
+		RETURN lease.refreshedAt AS refreshedAt`,
+		map[string]interface{}{
+			"name":     trendingLeaseName,
+			"now":      now,
+			"interval": int64((tw.settings.RefreshInterval - trendingLeaseSlack).Seconds()),
+		})
+	if err != nil {
+		return false, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return false, err
+	}
+	return len(records) > 0, nil
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrendingDecayRate(t *testing.T) {
+	settings := TrendingSettings{HalfLife: 48 * time.Hour}
+	for age, expected := range map[time.Duration]float64{
+		0:              1,
+		48 * time.Hour: 0.5,
+		96 * time.Hour: 0.25,
+	} {
+		if weight := math.Exp(-settings.decayRate() * age.Seconds()); math.Abs(weight-expected) > 1e-9 {
+			t.Errorf("expected activity %v old to weigh %v, got %v", age, expected, weight)
+		}
+	}
+	oldest := math.Exp(-settings.decayRate() * (trendingHalfLives * settings.HalfLife).Seconds())
+	if oldest > 0.004 {
+		t.Errorf("expected activity older than the window to be negligible, got %v", oldest)
+	}
+}
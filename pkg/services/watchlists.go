@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j-graphacademy/neoflix/pkg/ioutils"
+	"github.com/neo4j-graphacademy/neoflix/pkg/mapping"
+	"github.com/neo4j-graphacademy/neoflix/pkg/routes/paging"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// PrivateList is only visible to its owner
+	PrivateList = "private"
+	// UnlistedList is visible to anyone knowing its ID
+	UnlistedList = "unlisted"
+	// PublicList is visible to anyone, and listed on /api/lists
+	PublicList = "public"
+
+	maxDescriptionLength = 2000
+)
+
+// watchlistOrderings maps the supported orderings of list items to the
+// corresponding Cypher sort expressions
+var watchlistOrderings = map[string]string{
+	"position":   "r.position ASC",
+	"added":      "r.addedAt DESC",
+	"title":      "m.title ASC",
+	"released":   "m.released DESC",
+	"imdbRating": "m.imdbRating DESC",
+}
+
+type Watchlist struct {
+	Id          string       `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Visibility  string       `json:"visibility"`
+	Ordering    string       `json:"ordering"`
+	Owner       RatingAuthor `json:"owner"`
+	MovieCount  int64        `json:"movieCount"`
+	CreatedAt   string       `json:"createdAt"`
+	UpdatedAt   string       `json:"updatedAt"`
+	// Movies holds a page of the items of the list, in its ordering
+	Movies []WatchlistItem `json:"movies,omitempty"`
+}
+
+type WatchlistItem struct {
+	// Position is the 1-based position of the movie in the list
+	Position int64  `json:"position"`
+	AddedAt  string `json:"addedAt"`
+	Movie    Movie  `json:"movie"`
+}
+
+// WatchlistInput holds the editable properties of a list.
+// Visibility defaults to private, and Ordering to position.
+type WatchlistInput struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+	Ordering    string `json:"ordering"`
+}
+
+type WatchlistService interface {
+	// FindAllByUserId returns the lists owned by the user, most recently
+	// updated first
+	FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) ([]Watchlist, error)
+
+	// FindAllPublic returns the public lists of all users, most recently
+	// updated first
+	FindAllPublic(ctx context.Context, page *paging.Paging) ([]Watchlist, error)
+
+	// FindOneById returns the list with a page of its movies, flagged with the
+	// favorites and ratings of the viewer.
+	// Private lists are only found by their owner.
+	FindOneById(ctx context.Context, listId, viewerId string, page *paging.Paging) (*Watchlist, error)
+
+	Save(ctx context.Context, userId string, input WatchlistInput) (*Watchlist, error)
+
+	Update(ctx context.Context, userId, listId string, input WatchlistInput) (*Watchlist, error)
+
+	Delete(ctx context.Context, userId, listId string) (*Watchlist, error)
+
+	// AddMovie inserts the movie at the given 1-based position, or appends it
+	// when position is nil or past the end of the list.
+	// Movies already in the list are moved to the new position.
+	AddMovie(ctx context.Context, userId, listId, movieId string, position *int64, page *paging.Paging) (*Watchlist, error)
+
+	RemoveMovie(ctx context.Context, userId, listId, movieId string, page *paging.Paging) (*Watchlist, error)
+}
+
+type neo4jWatchlistService struct {
+	driver neo4j.Driver
+}
+
+func NewWatchlistService(driver neo4j.Driver) WatchlistService {
+	return &neo4jWatchlistService{driver: driver}
+}
+
+// watchlistProjection projects the list bound to `l`, owned by `owner`
+const watchlistProjection = `l {
+	.id, .title, .description, .visibility, .ordering,
+	createdAt: toString(l.createdAt), updatedAt: toString(l.updatedAt),
+	owner: owner { id: owner.userId, .name },
+	movieCount: size([ (l)-[:CONTAINS]->(:Movie) | 1 ])
+}`
+
+func (ws *neo4jWatchlistService) FindAllByUserId(ctx context.Context, userId string, page *paging.Paging) ([]Watchlist, error) {
+	return ws.findAllLists(ctx, "MATCH (owner:User {userId: $userId})-[:OWNS]->(l:Watchlist)",
+		map[string]interface{}{"userId": userId}, page)
+}
+
+func (ws *neo4jWatchlistService) FindAllPublic(ctx context.Context, page *paging.Paging) ([]Watchlist, error) {
+	return ws.findAllLists(ctx, "MATCH (owner:User)-[:OWNS]->(l:Watchlist {visibility: 'public'})",
+		map[string]interface{}{}, page)
+}
+
+func (ws *neo4jWatchlistService) findAllLists(ctx context.Context, match string, params map[string]interface{}, page *paging.Paging) (_ []Watchlist, err error) {
+	session := ws.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	results, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		params["skip"] = page.Skip()
+		params["limit"] = page.Limit()
+		result, err := tx.Run(fmt.Sprintf(`
+			%s
+			WITH owner, l
+			ORDER BY l.updatedAt DESC
+			SKIP $skip
+			LIMIT $limit
+			RETURN %s AS list`, match, watchlistProjection),
+			params)
+		if err != nil {
+			return nil, err
+		}
+		records, err := collect(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		lists := make([]Watchlist, len(records))
+		for i, record := range records {
+			if err := mapping.DecodeColumn(record, "list", &lists[i]); err != nil {
+				return nil, err
+			}
+		}
+		return lists, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results.([]Watchlist), nil
+}
+
+func (ws *neo4jWatchlistService) FindOneById(ctx context.Context, listId, viewerId string, page *paging.Paging) (_ *Watchlist, err error) {
+	session := ws.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := readTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		return findWatchlist(ctx, tx, listId, viewerId, page)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Watchlist), nil
+}
+
+func (ws *neo4jWatchlistService) Save(ctx context.Context, userId string, input WatchlistInput) (_ *Watchlist, err error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	session := ws.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			MATCH (u:User {userId: $userId})
+			CREATE (u)-[:OWNS]->(l:Watchlist {id: randomUUID(), createdAt: datetime()})
+			SET l += $properties, l.updatedAt = l.createdAt
+			RETURN l.id AS id`,
+			map[string]interface{}{"userId": userId, "properties": input.properties()})
+		if err != nil {
+			return nil, err
+		}
+		record, err := result.Single()
+		if err != nil {
+			return nil, NewDomainError(404, "User not found", map[string]interface{}{"userId": userId})
+		}
+		listId, _ := record.Get("id")
+		return findWatchlist(ctx, tx, listId.(string), userId, paging.NewPaging("", "", "", 0, 0))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Watchlist), nil
+}
+
+func (ws *neo4jWatchlistService) Update(ctx context.Context, userId, listId string, input WatchlistInput) (*Watchlist, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	return ws.updateList(ctx, userId, listId, paging.NewPaging("", "", "", 0, 0),
+		func(tx neo4j.Transaction) error {
+			return run(tx, `
+				MATCH (l:Watchlist {id: $listId})
+				SET l += $properties`,
+				map[string]interface{}{"listId": listId, "properties": input.properties()})
+		})
+}
+
+func (ws *neo4jWatchlistService) Delete(ctx context.Context, userId, listId string) (_ *Watchlist, err error) {
+	session := ws.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		list, err := findWatchlist(ctx, tx, listId, userId, paging.NewPaging("", "", "", 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		if list.Owner.Id != userId {
+			return nil, watchlistNotFound(listId)
+		}
+		return list, run(tx, `
+			MATCH (l:Watchlist {id: $listId})
+			DETACH DELETE l`,
+			map[string]interface{}{"listId": listId})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Watchlist), nil
+}
+
+func (ws *neo4jWatchlistService) AddMovie(ctx context.Context, userId, listId, movieId string, position *int64, page *paging.Paging) (*Watchlist, error) {
+	return ws.updateList(ctx, userId, listId, page, func(tx neo4j.Transaction) error {
+		result, err := tx.Run(`
+			MATCH (m:Movie {tmdbId: $movieId})
+			RETURN m.tmdbId AS tmdbId`,
+			map[string]interface{}{"movieId": movieId})
+		if err != nil {
+			return err
+		}
+		if _, err := result.Single(); err != nil {
+			return NewDomainError(404, "Movie not found", map[string]interface{}{"tmdbId": movieId})
+		}
+		if err := removeFromList(tx, listId, movieId); err != nil {
+			return err
+		}
+		target := int64(-1)
+		if position != nil {
+			target = *position
+		}
+		return run(tx, `
+			MATCH (l:Watchlist {id: $listId})
+			MATCH (m:Movie {tmdbId: $movieId})
+			WITH l, m, size([ (l)-[:CONTAINS]->(:Movie) | 1 ]) AS count
+			WITH l, m, CASE
+				WHEN $position < 1 OR $position > count THEN count + 1
+				ELSE $position
+			END AS position
+			CALL {
+				WITH l, position
+				MATCH (l)-[r:CONTAINS]->(:Movie)
+				WHERE r.position >= position
+				SET r.position = r.position + 1
+			}
+			CREATE (l)-[:CONTAINS {position: position, addedAt: datetime()}]->(m)`,
+			map[string]interface{}{"listId": listId, "movieId": movieId, "position": target})
+	})
+}
+
+func (ws *neo4jWatchlistService) RemoveMovie(ctx context.Context, userId, listId, movieId string, page *paging.Paging) (*Watchlist, error) {
+	return ws.updateList(ctx, userId, listId, page, func(tx neo4j.Transaction) error {
+		return removeFromList(tx, listId, movieId)
+	})
+}
+
+// updateList runs change on a list owned by the user, after locking it by
+// bumping its update time, and returns the updated list
+func (ws *neo4jWatchlistService) updateList(ctx context.Context, userId, listId string, page *paging.Paging, change func(tx neo4j.Transaction) error) (_ *Watchlist, err error) {
+	session := ws.driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		err = ioutils.DeferredClose(session, err)
+	}()
+
+	result, err := writeTransaction(ctx, session, func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			MATCH (:User {userId: $userId})-[:OWNS]->(l:Watchlist {id: $listId})
+			SET l.updatedAt = datetime()
+			RETURN l.id AS id`,
+			map[string]interface{}{"userId": userId, "listId": listId})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := result.Single(); err != nil {
+			return nil, watchlistNotFound(listId)
+		}
+		if err := change(tx); err != nil {
+			return nil, err
+		}
+		return findWatchlist(ctx, tx, listId, userId, page)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Watchlist), nil
+}
+
+// removeFromList removes the movie from the list if present, closing the gap
+// left in the positions of the other movies
+func removeFromList(tx neo4j.Transaction, listId, movieId string) error {
+	return run(tx, `
+		MATCH (l:Watchlist {id: $listId})-[removed:CONTAINS]->(:Movie {tmdbId: $movieId})
+		WITH l, removed, removed.position AS position
+		DELETE removed
+		WITH l, position
+		MATCH (l)-[r:CONTAINS]->(:Movie)
+		WHERE r.position > position
+		SET r.position = r.position - 1`,
+		map[string]interface{}{"listId": listId, "movieId": movieId})
+}
+
+// findWatchlist returns the list, if visible to the viewer, with a page of its
+// movies in the ordering of the list.
+// A zero limit skips loading the movies.
+func findWatchlist(ctx context.Context, tx neo4j.Transaction, listId, viewerId string, page *paging.Paging) (*Watchlist, error) {
+	result, err := tx.Run(fmt.Sprintf(`
+		MATCH (owner:User)-[:OWNS]->(l:Watchlist {id: $listId})
+		WHERE l.visibility <> 'private' OR owner.userId = $viewerId
+		RETURN %s AS list`, watchlistProjection),
+		map[string]interface{}{"listId": listId, "viewerId": viewerId})
+	if err != nil {
+		return nil, err
+	}
+	records, err := collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, watchlistNotFound(listId)
+	}
+	var list Watchlist
+	if err := mapping.DecodeColumn(records[0], "list", &list); err != nil {
+		return nil, err
+	}
+	if page.Limit() <= 0 {
+		return &list, nil
+	}
+
+	ordering, found := watchlistOrderings[list.Ordering]
+	if !found {
+		ordering = watchlistOrderings["position"]
+	}
+	result, err = tx.Run(fmt.Sprintf(`
+		MATCH (:Watchlist {id: $listId})-[r:CONTAINS]->(m:Movie)
+		WITH r, m
+		ORDER BY %s, r.position ASC
+		SKIP $skip
+		LIMIT $limit
+		RETURN r.position AS position, toString(r.addedAt) AS addedAt, %s AS movie`,
+		ordering, movieProjection("m", page.Projection())),
+		map[string]interface{}{"listId": listId, "skip": page.Skip(), "limit": page.Limit()})
+	if err != nil {
+		return nil, err
+	}
+	records, err = collect(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	list.Movies = make([]WatchlistItem, len(records))
+	movies := make([]Movie, len(records))
+	for i, record := range records {
+		if err := mapping.DecodeRecord(record, &list.Movies[i]); err != nil {
+			return nil, err
+		}
+		movies[i] = list.Movies[i].Movie
+	}
+	if err := enrichMovies(ctx, tx, viewerId, movies); err != nil {
+		return nil, err
+	}
+	for i := range movies {
+		list.Movies[i].Movie = movies[i]
+	}
+	return &list, nil
+}
+
+func watchlistNotFound(listId string) error {
+	return NewDomainError(404, "List not found", map[string]interface{}{"id": listId})
+}
+
+// run runs a statement whose results are not needed
+func run(tx neo4j.Transaction, cypher string, params map[string]interface{}) error {
+	result, err := tx.Run(cypher, params)
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
+}
+
+func (wi WatchlistInput) validate() error {
+	var v validation
+	v.required("title", wi.Title)
+	v.maxLength("title", wi.Title, maxNameLength)
+	v.maxLength("description", wi.Description, maxDescriptionLength)
+	switch wi.Visibility {
+	case "", PrivateList, UnlistedList, PublicList:
+	default:
+		v.fail("visibility", fmt.Sprintf("must be one of %s, %s or %s", PrivateList, UnlistedList, PublicList))
+	}
+	if _, found := watchlistOrderings[wi.Ordering]; wi.Ordering != "" && !found {
+		v.fail("ordering", "must be one of added, imdbRating, position, released or title")
+	}
+	return v.err("Invalid list")
+}
+
+func (wi WatchlistInput) properties() map[string]interface{} {
+	visibility, ordering := wi.Visibility, wi.Ordering
+	if visibility == "" {
+		visibility = PrivateList
+	}
+	if ordering == "" {
+		ordering = "position"
+	}
+	return map[string]interface{}{
+		"title":       wi.Title,
+		"description": optionalString(wi.Description),
+		"visibility":  visibility,
+		"ordering":    ordering,
+	}
+}
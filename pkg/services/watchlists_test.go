@@ -0,0 +1,30 @@
+package services
+
+import "testing"
+
+func TestWatchlistInput(outer *testing.T) {
+	outer.Run("defaults visibility and ordering", func(t *testing.T) {
+		input := WatchlistInput{Title: "Halloween"}
+		if err := input.validate(); err != nil {
+			t.Fatal(err)
+		}
+		properties := input.properties()
+		if properties["visibility"] != PrivateList || properties["ordering"] != "position" {
+			t.Fatalf("unexpected properties %v", properties)
+		}
+	})
+
+	outer.Run("rejects unknown visibility and ordering", func(t *testing.T) {
+		err := WatchlistInput{Title: "Halloween", Visibility: "friends", Ordering: "random"}.validate()
+		domainErr, ok := err.(*DomainError)
+		if !ok || domainErr.StatusCode() != 422 {
+			t.Fatalf("expected a 422 DomainError, got %v", err)
+		}
+		if _, found := domainErr.details["visibility"]; !found {
+			t.Fatalf("expected a visibility error, got %v", domainErr.details)
+		}
+		if _, found := domainErr.details["ordering"]; !found {
+			t.Fatalf("expected an ordering error, got %v", domainErr.details)
+		}
+	})
+}
@@ -0,0 +1,8 @@
+// Package public embeds the pre-built Neoflix frontend so that the server
+// binary can run from any working directory.
+package public
+
+import "embed"
+
+//go:embed index.html favicon.ico css img js
+var Assets embed.FS